@@ -0,0 +1,60 @@
+// Package api defines what every framework app must put on the wire
+// identically: the baseline response headers, and typed bodies for the
+// hottest, simplest responses. The light, health and root endpoints are meant
+// to measure framework overhead, and a struct encodes without the per-request
+// map allocation that would otherwise dominate their GC profile.
+//
+// Fields are declared in alphabetical JSON-key order so the encoded bodies
+// are byte-identical to the map-based responses they replace.
+package api
+
+import "net/http"
+
+// SetBaselineHeaders sets the headers every framework sends on every
+// response, so header overhead is equal across implementations: responses
+// are dynamic and must not be cached, JSON must not be MIME-sniffed, and
+// X-Framework names the backend that served the request. Responses carrying
+// an ETag relax the caching rule through SetETag.
+func SetBaselineHeaders(h http.Header, framework string) {
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("X-Framework", framework)
+}
+
+// SetETag tags a response with etag and lets caches store it on condition
+// that they revalidate before every reuse. no-cache replaces the baseline
+// no-store, so a browser or proxy holding the body sends If-None-Match and
+// is answered 304 instead of receiving the body again.
+func SetETag(h http.Header, etag string) {
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "no-cache")
+}
+
+// RootResponse is the body of GET /.
+type RootResponse struct {
+	Framework     string `json:"framework"`
+	Service       string `json:"service"`
+	Status        string `json:"status"`
+	UptimeSeconds int    `json:"uptime_seconds"`
+	Version       string `json:"version"`
+}
+
+// HealthResponse is the body of GET /api/v1/health.
+type HealthResponse struct {
+	Framework     string `json:"framework"`
+	Gomaxprocs    int    `json:"gomaxprocs"`
+	NumCPU        int    `json:"num_cpu"`
+	Status        string `json:"status"`
+	Timestamp     int64  `json:"timestamp"`
+	UptimeMs      int64  `json:"uptime_ms"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+}
+
+// LightAnalyticsResponse is the body of GET /api/v1/weather/analytics/light.
+type LightAnalyticsResponse struct {
+	ElapsedMs int64  `json:"elapsed_ms"`
+	Endpoint  string `json:"endpoint"`
+	Framework string `json:"framework"`
+	Padding   string `json:"padding,omitempty"`
+	Result    int64  `json:"result"`
+}
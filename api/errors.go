@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ErrorResponse is the body of every error response, shaped the same by
+// every framework so one client can parse errors from any backend. Code is
+// a stable machine-readable identifier and Message the human-readable
+// explanation. Field names the request field at fault, when there is one,
+// and Details carries anything else an endpoint reports, such as the
+// offending row of a bulk insert.
+type ErrorResponse struct {
+	Code    string      `json:"code"`
+	Details interface{} `json:"details,omitempty"`
+	Field   string      `json:"field,omitempty"`
+	Message string      `json:"message"`
+}
+
+// Codes for errors more specific than their status.
+const (
+	// CodeInvalidField is a request field that failed validation; Field
+	// names it
+	CodeInvalidField = "invalid_field"
+
+	// CodeNotAcceptable is an Accept header ruling out every format the
+	// endpoint offers; Details lists them
+	CodeNotAcceptable = "not_acceptable"
+)
+
+// CodeFor returns the default code for status: its reason phrase in snake
+// case, such as "not_found" for 404, and "client_closed_request" for the
+// nonstandard 499.
+func CodeFor(status int) string {
+	if status == 499 {
+		return "client_closed_request"
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+// ForStatus returns e with Code defaulted to CodeFor(status) when unset.
+func (e ErrorResponse) ForStatus(status int) ErrorResponse {
+	if e.Code == "" {
+		e.Code = CodeFor(status)
+	}
+	return e
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Route is one method and path pair registered with a framework's router.
+type Route struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// RoutesResponse is the body of GET /api/v1/routes. Prefix is the
+// API_PREFIX every path in Routes starts with, when one is set.
+type RoutesResponse struct {
+	Count     int     `json:"count"`
+	Framework string  `json:"framework"`
+	Prefix    string  `json:"prefix,omitempty"`
+	Routes    []Route `json:"routes"`
+}
+
+// pathParam matches a path parameter in any router's syntax: gin and
+// goframe's :id, chi, mux and fasthttp's {id}, and iris's typed {id:uint64}.
+var pathParam = regexp.MustCompile(`:([A-Za-z_]\w*)|\{([A-Za-z_]\w*)(?::[^}]*)?\}`)
+
+// AnyMethod stands for a path registered for every method, which routers
+// such as chi's Handle and gin's Any expand into one route per method.
+const AnyMethod = "*"
+
+// standardMethods are the methods those routers expand a catch-all into.
+var standardMethods = []string{
+	http.MethodConnect, http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions,
+	http.MethodPatch, http.MethodPost, http.MethodPut, http.MethodTrace,
+}
+
+// NewRoutesResponse rewrites path parameters to the {name} form, folds a
+// path registered for every standard method into a single AnyMethod route,
+// drops duplicates and sorts by path then method, so two frameworks exposing
+// the same API produce identical route lists whatever their router syntax.
+func NewRoutesResponse(framework string, routes []Route) RoutesResponse {
+	methods := make(map[string]map[string]bool)
+	for _, r := range routes {
+		path := pathParam.ReplaceAllString(r.Path, "{$1$2}")
+		if methods[path] == nil {
+			methods[path] = make(map[string]bool)
+		}
+		methods[path][strings.ToUpper(r.Method)] = true
+	}
+
+	var normalized []Route
+	for path, set := range methods {
+		if coversAll(set) {
+			set = map[string]bool{AnyMethod: true}
+		}
+		for method := range set {
+			normalized = append(normalized, Route{Method: method, Path: path})
+		}
+	}
+
+	sort.Slice(normalized, func(i, j int) bool {
+		if normalized[i].Path != normalized[j].Path {
+			return normalized[i].Path < normalized[j].Path
+		}
+		return normalized[i].Method < normalized[j].Method
+	})
+	return RoutesResponse{Count: len(normalized), Framework: framework, Routes: normalized}
+}
+
+func coversAll(set map[string]bool) bool {
+	for _, method := range standardMethods {
+		if !set[method] {
+			return false
+		}
+	}
+	return true
+}
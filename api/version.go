@@ -0,0 +1,52 @@
+package api
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, injected at link time:
+//
+//	go build -ldflags "-X carbon-bench/api.Commit=$(git rev-parse HEAD) -X carbon-bench/api.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// When Commit isn't injected, the VCS revision the go command stamps into
+// local builds is used instead.
+var (
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// VersionResponse is the body of GET /api/v1/version, tying benchmark
+// results to the binary that produced them.
+type VersionResponse struct {
+	BuildTime string `json:"build_time"`
+	Commit    string `json:"commit"`
+	Framework string `json:"framework"`
+	GoVersion string `json:"go_version"`
+}
+
+// NewVersionResponse returns the build metadata for framework.
+func NewVersionResponse(framework string) VersionResponse {
+	return VersionResponse{
+		BuildTime: BuildTime,
+		Commit:    commit(),
+		Framework: framework,
+		GoVersion: runtime.Version(),
+	}
+}
+
+func commit() string {
+	if Commit != "unknown" {
+		return Commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Commit
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return Commit
+}
@@ -0,0 +1,136 @@
+// Package apitest holds the behaviour checks every framework app must pass
+// identically, so a route or header the apps share is added to the tests
+// once rather than in each app. Each app supplies only a handler built the
+// way its main builds one.
+package apitest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Route is one request sent by the shared checks.
+type Route struct {
+	Method, Target, Body string
+}
+
+// Routes are the original endpoints every app serves, with parameters small
+// enough to answer in milliseconds.
+var Routes = []Route{
+	{http.MethodGet, "/", ""},
+	{http.MethodGet, "/api/v1/health", ""},
+	{http.MethodGet, "/api/v1/weather/analytics/heavy?size=1000&iterations=1", ""},
+	{http.MethodGet, "/api/v1/weather/analytics/light", ""},
+	{http.MethodGet, "/api/v1/weather/analytics/medium?size=10", ""},
+	{http.MethodGet, "/api/v1/weather/external?delay_ms=0", ""},
+	{http.MethodGet, "/api/v1/weather/fetch?city=Colombo", ""},
+	{http.MethodGet, "/api/v1/db/users", ""},
+	{http.MethodPost, "/api/v1/db/users", `{"name":"Ada","email":"ada@example.com"}`},
+}
+
+// Serve sends one request through handler and returns the recorded
+// response. A non-empty body is sent as JSON.
+func Serve(handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// CheckBaselineHeaders sends every one of Routes through handler and checks
+// that each was routed and carries the api.SetBaselineHeaders headers, with
+// X-Framework naming framework. A response with an ETag must carry the
+// api.SetETag Cache-Control instead.
+func CheckBaselineHeaders(t *testing.T, handler http.Handler, framework string) {
+	t.Helper()
+	for _, route := range Routes {
+		w := Serve(handler, route.Method, route.Target, route.Body)
+		if w.Code == http.StatusNotFound || w.Code == http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: status %d, route not registered", route.Method, route.Target, w.Code)
+		}
+		want := map[string]string{
+			"Cache-Control":          "no-store",
+			"X-Content-Type-Options": "nosniff",
+			"X-Framework":            framework,
+		}
+		if w.Header().Get("ETag") != "" {
+			want["Cache-Control"] = "no-cache"
+		}
+		for header, value := range want {
+			if got := w.Header().Get(header); got != value {
+				t.Errorf("%s %s (status %d): %s = %q, want %q", route.Method, route.Target, w.Code, header, got, value)
+			}
+		}
+	}
+}
+
+// CheckConditional checks that the heavy and medium results carry a weak
+// ETag that caches may store and revalidate, and that a request naming that
+// ETag is answered 304 with the headers the 200 varies on.
+func CheckConditional(t *testing.T, handler http.Handler) {
+	t.Helper()
+	for _, target := range []string{
+		"/api/v1/weather/analytics/heavy?size=1000&iterations=1",
+		"/api/v1/weather/analytics/medium?size=10",
+	} {
+		w := Serve(handler, http.MethodGet, target, "")
+		etag := w.Header().Get("ETag")
+		if w.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+			t.Errorf("GET %s: status %d, ETag %q; want 200 with a weak ETag", target, w.Code, etag)
+			continue
+		}
+
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("GET %s If-None-Match %s: status %d, %d body bytes; want a bodyless 304", target, etag, w.Code, w.Body.Len())
+		}
+		for header, value := range map[string]string{
+			"Cache-Control": "no-cache",
+			"ETag":          etag,
+			"Vary":          "Accept",
+		} {
+			if got := w.Header().Get(header); got != value {
+				t.Errorf("GET %s 304: %s = %q, want %q", target, header, got, value)
+			}
+		}
+	}
+}
+
+// CheckUnrouted checks that an unknown path answers 404 and a known path
+// requested with another method 405, both in the shared error envelope.
+func CheckUnrouted(t *testing.T, handler http.Handler) {
+	t.Helper()
+	w := Serve(handler, http.MethodGet, "/api/v1/no-such-route", "")
+	CheckError(t, w, http.StatusNotFound, "not_found")
+	w = Serve(handler, http.MethodDelete, "/api/v1/health", "")
+	CheckError(t, w, http.StatusMethodNotAllowed, "method_not_allowed")
+}
+
+// CheckError checks that w is a JSON api.ErrorResponse with the given status
+// and code and a non-empty message.
+func CheckError(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if w.Code != status {
+		t.Errorf("status %d, want %d", w.Code, status)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("status %d: Content-Type %q, want application/json", w.Code, ct)
+	}
+	var body struct{ Code, Message string }
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Errorf("status %d: %v in %q", w.Code, err, w.Body)
+		return
+	}
+	if body.Code != code || body.Message == "" {
+		t.Errorf("status %d: body %+v, want code %s", w.Code, body, code)
+	}
+}
@@ -0,0 +1,49 @@
+// Package carbon estimates the energy use and emissions attributable to a
+// span of CPU work, so the benchmark apps can report carbon alongside timing.
+package carbon
+
+const (
+	// DefaultWattsPerCore is the assumed average power draw of one fully
+	// busy core, in watts.
+	DefaultWattsPerCore = 10.0
+
+	// DefaultGridIntensity is the assumed grid carbon intensity in gCO2/kWh
+	// (roughly the global average).
+	DefaultGridIntensity = 475.0
+
+	joulesPerKWh = 3.6e6
+)
+
+var (
+	wattsPerCore                          = DefaultWattsPerCore
+	gridIntensity CarbonIntensityProvider = Static(DefaultGridIntensity)
+)
+
+// Configure sets the power model and a static grid intensity used by
+// EstimateCO2. Non-positive values leave the corresponding setting
+// unchanged. Call it, and SetProvider, before any estimate is made.
+func Configure(watts, intensity float64) {
+	if watts > 0 {
+		wattsPerCore = watts
+	}
+	if intensity > 0 {
+		gridIntensity = Static(intensity)
+	}
+}
+
+// SetProvider replaces the grid intensity set by Configure with p, for
+// example an HTTP provider of live grid data. It asks p for a value once,
+// so a provider that fetches starts doing so before the first estimate.
+func SetProvider(p CarbonIntensityProvider) {
+	gridIntensity = p
+	p.Intensity()
+}
+
+// EstimateCO2 converts CPU seconds into energy (joules) and emissions
+// (grams of CO2) using the configured watts-per-core and the provider's
+// current grid intensity.
+func EstimateCO2(cpuSeconds float64) (joules, grams float64) {
+	joules = cpuSeconds * wattsPerCore
+	grams = joules / joulesPerKWh * gridIntensity.Intensity()
+	return joules, grams
+}
@@ -0,0 +1,64 @@
+package carbon
+
+import (
+	"math"
+	"testing"
+)
+
+// configure sets the model for one test and restores the defaults after it.
+func configure(t *testing.T, watts, intensity float64) {
+	t.Cleanup(func() {
+		wattsPerCore = DefaultWattsPerCore
+		gridIntensity = Static(DefaultGridIntensity)
+	})
+	Configure(watts, intensity)
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-12*math.Max(1, math.Abs(b))
+}
+
+func TestEstimateCO2(t *testing.T) {
+	tests := []struct {
+		name       string
+		watts      float64
+		intensity  float64
+		cpuSeconds float64
+		joules     float64
+		grams      float64
+	}{
+		{"zero CPU", 10, 475, 0, 0, 0},
+		{"one second at the defaults", 10, 475, 1, 10, 10 / 3.6e6 * 475},
+		// 10 W for an hour is 0.01 kWh, at 475 gCO2/kWh 4.75 g
+		{"one core-hour", 10, 475, 3600, 36000, 4.75},
+		// 36 W for 100 s is 1 Wh, at 1000 gCO2/kWh 1 g
+		{"one watt-hour", 36, 1000, 100, 3600, 1},
+		{"fraction of a second", 20, 200, 0.25, 5, 5 / 3.6e6 * 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configure(t, tt.watts, tt.intensity)
+			joules, grams := EstimateCO2(tt.cpuSeconds)
+			if !approxEqual(joules, tt.joules) || !approxEqual(grams, tt.grams) {
+				t.Errorf("EstimateCO2(%g) = %g J, %g g; want %g J, %g g", tt.cpuSeconds, joules, grams, tt.joules, tt.grams)
+			}
+		})
+	}
+}
+
+func TestConfigureIgnoresNonPositive(t *testing.T) {
+	configure(t, 20, 100)
+	Configure(0, -1)
+	joules, grams := EstimateCO2(3600)
+	if !approxEqual(joules, 72000) || !approxEqual(grams, 2) {
+		t.Errorf("EstimateCO2(3600) = %g J, %g g; want 72000 J, 2 g", joules, grams)
+	}
+}
+
+func TestSetProviderReplacesStaticIntensity(t *testing.T) {
+	configure(t, 10, 475)
+	SetProvider(Static(950))
+	if _, grams := EstimateCO2(3600); !approxEqual(grams, 9.5) {
+		t.Errorf("grams = %g, want 9.5", grams)
+	}
+}
@@ -0,0 +1,18 @@
+package carbon
+
+import "syscall"
+
+// PerThreadCPU reports that ThreadCPUSeconds covers only the calling thread,
+// so work spread over several threads must be measured on each of them.
+const PerThreadCPU = true
+
+// ThreadCPUSeconds returns the user+system CPU time consumed by the calling
+// OS thread. Callers should hold runtime.LockOSThread for the span they
+// measure so the goroutine doesn't migrate between readings.
+func ThreadCPUSeconds() float64 {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_THREAD, &usage); err != nil {
+		return 0
+	}
+	return float64(usage.Utime.Nano()+usage.Stime.Nano()) / 1e9
+}
@@ -0,0 +1,13 @@
+//go:build !unix && !windows
+
+package carbon
+
+// PerThreadCPU is false: there is no CPU clock to read per thread here.
+const PerThreadCPU = false
+
+// ThreadCPUSeconds always returns 0 on platforms without a CPU time API
+// Go's syscall package exposes, such as wasip1 and plan9, so CPU-based
+// estimates read zero there rather than the build failing.
+func ThreadCPUSeconds() float64 {
+	return 0
+}
@@ -0,0 +1,19 @@
+//go:build unix && !linux
+
+package carbon
+
+import "syscall"
+
+// PerThreadCPU reports that ThreadCPUSeconds is already process-wide here, so
+// readings from several threads must not be added together.
+const PerThreadCPU = false
+
+// ThreadCPUSeconds falls back to process-wide CPU time on platforms without
+// per-thread rusage, so concurrent work is included in the reading.
+func ThreadCPUSeconds() float64 {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return float64(usage.Utime.Nano()+usage.Stime.Nano()) / 1e9
+}
@@ -0,0 +1,27 @@
+package carbon
+
+import "syscall"
+
+// PerThreadCPU reports that ThreadCPUSeconds is already process-wide here, so
+// readings from several threads must not be added together.
+const PerThreadCPU = false
+
+// ThreadCPUSeconds falls back to process-wide CPU time from
+// GetProcessTimes, as Windows has no rusage, so concurrent work is included
+// in the reading.
+func ThreadCPUSeconds() float64 {
+	var creation, exit, kernel, user syscall.Filetime
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0
+	}
+	if err := syscall.GetProcessTimes(process, &creation, &exit, &kernel, &user); err != nil {
+		return 0
+	}
+	return float64(filetimeTicks(kernel)+filetimeTicks(user)) / 1e7
+}
+
+// filetimeTicks returns a FILETIME duration in its 100ns ticks.
+func filetimeTicks(ft syscall.Filetime) uint64 {
+	return uint64(ft.HighDateTime)<<32 | uint64(ft.LowDateTime)
+}
@@ -0,0 +1,162 @@
+package carbon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultIntensityURL is Electricity Maps' latest-intensity endpoint.
+	DefaultIntensityURL = "https://api.electricitymap.org/v3/carbon-intensity/latest"
+
+	// DefaultIntensityTTL is how long a fetched intensity is used before it
+	// is fetched again. Grid data updates hourly at most.
+	DefaultIntensityTTL = 5 * time.Minute
+
+	// intensityTimeout bounds one fetch.
+	intensityTimeout = 5 * time.Second
+
+	// retryAfter bounds how long the fallback is used after a failed fetch
+	// before trying again.
+	retryAfter = 30 * time.Second
+
+	// maxIntensityBody bounds how much of a response is read.
+	maxIntensityBody = 1 << 20
+)
+
+// CarbonIntensityProvider supplies the grid carbon intensity, in gCO2/kWh,
+// that EstimateCO2 applies. Intensity is called on every estimate, so it
+// must return promptly and never wait on the network.
+type CarbonIntensityProvider interface {
+	Intensity() float64
+}
+
+// Static is a fixed grid intensity in gCO2/kWh.
+type Static float64
+
+func (s Static) Intensity() float64 { return float64(s) }
+
+// HTTP reads live grid intensity for one zone from an Electricity Maps-style
+// API: GET URL?zone=Zone, with Token in the auth-token header when set,
+// answering JSON with a numeric carbonIntensity field. A fetched value is
+// used for TTL; once it expires Intensity keeps returning it while one
+// background fetch replaces it. Until the first fetch succeeds, and for a
+// while after any fetch fails, Intensity returns Fallback.
+type HTTP struct {
+	URL      string
+	Zone     string
+	Token    string
+	TTL      time.Duration
+	Fallback float64
+	Client   *http.Client
+
+	mu         sync.Mutex
+	value      float64
+	live       bool
+	expires    time.Time
+	refreshing bool
+}
+
+// NewHTTP returns an HTTP provider with its own client.
+func NewHTTP(url, zone, token string, ttl time.Duration, fallback float64) *HTTP {
+	return &HTTP{
+		URL:      url,
+		Zone:     zone,
+		Token:    token,
+		TTL:      ttl,
+		Fallback: fallback,
+		Client:   &http.Client{Timeout: intensityTimeout},
+	}
+}
+
+// Intensity returns the cached intensity, or Fallback when there is none,
+// and starts a fetch in the background when the cache has expired.
+func (h *HTTP) Intensity() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.refreshing && !time.Now().Before(h.expires) {
+		h.refreshing = true
+		go h.refresh()
+	}
+	if h.live {
+		return h.value
+	}
+	return h.Fallback
+}
+
+// refresh fetches the intensity and caches the outcome, logging only when
+// the provider switches between live data and the fallback.
+func (h *HTTP) refresh() {
+	value, err := h.fetch(context.Background())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.refreshing = false
+
+	if err != nil {
+		if h.live || h.expires.IsZero() {
+			log.Printf("⚠️  Grid intensity for %s unavailable, using %g gCO2/kWh: %v", h.Zone, h.Fallback, err)
+		}
+		h.live = false
+		h.expires = time.Now().Add(min(h.TTL, retryAfter))
+		return
+	}
+	if !h.live {
+		log.Printf("✓ Grid intensity for %s: %g gCO2/kWh", h.Zone, value)
+	}
+	h.value, h.live = value, true
+	h.expires = time.Now().Add(h.TTL)
+}
+
+// fetch performs one request against the API.
+func (h *HTTP) fetch(ctx context.Context) (float64, error) {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return 0, err
+	}
+	q := u.Query()
+	q.Set("zone", h.Zone)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if h.Token != "" {
+		req.Header.Set("auth-token", h.Token)
+	}
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxIntensityBody))
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("intensity API returned %s", resp.Status)
+	}
+
+	var payload struct {
+		CarbonIntensity *float64 `json:"carbonIntensity"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return 0, fmt.Errorf("intensity API returned invalid JSON: %w", err)
+	}
+	if payload.CarbonIntensity == nil || *payload.CarbonIntensity < 0 {
+		return 0, fmt.Errorf("intensity API returned no carbonIntensity")
+	}
+	return *payload.CarbonIntensity, nil
+}
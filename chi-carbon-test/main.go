@@ -1,25 +1,115 @@
 package main
 
 import (
-	"crypto/sha256"
+	"context"
 	"database/sql"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
+	"carbon-bench/api"
+	"carbon-bench/carbon"
+	"carbon-bench/clientstats"
+	"carbon-bench/compute"
+	"carbon-bench/computepb"
+	"carbon-bench/config"
+	"carbon-bench/csvstream"
+	"carbon-bench/diskio"
+	"carbon-bench/faultinject"
+	"carbon-bench/health"
+	"carbon-bench/idempotency"
+	"carbon-bench/inflight"
+	"carbon-bench/jsonenc"
+	"carbon-bench/jwtauth"
+	"carbon-bench/memdb"
+	"carbon-bench/ndjson"
+	"carbon-bench/negotiate"
+	"carbon-bench/padding"
+	"carbon-bench/params"
+	"carbon-bench/ratelimit"
+	"carbon-bench/selfcheck"
+	"carbon-bench/sse"
+	"carbon-bench/statslog"
+	"carbon-bench/store"
+	"carbon-bench/tracing"
+	"carbon-bench/trailer"
+	"carbon-bench/unixsock"
+	"carbon-bench/weather"
+	"carbon-bench/wsecho"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 var (
 	startTime time.Time
 	db        *sql.DB
+	dbReady   bool
+	// dialect is the SQL syntax of the connected database
+	dialect = store.Postgres
+	// dbDriver names the connected database for tracing; the in-memory
+	// fallback speaks the Postgres dialect but is SQLite underneath
+	dbDriver = config.DriverSQLite
+
+	// Prepared once at startup unless DB_PREPARED_STATEMENTS=false
+	selectUsersStmt *sql.Stmt
+	insertUserStmt  *sql.Stmt
+	encoder         jsonenc.Encoder
+
+	// dbLatency is DB_SIMULATED_LATENCY_MS, slept by getUsers and createUser
+	dbLatency time.Duration
+
+	// weatherUpstream is set when WEATHER_UPSTREAM_URL is configured
+	weatherUpstream *weather.Upstream
+	weatherFetcher  *weather.OpenMeteo
+
+	wsServer *wsecho.Server
+
+	// requests counts in-flight requests and their peak for /api/v1/metrics
+	requests inflight.Gauge
+
+	// apiPrefix is API_PREFIX, the path every route is served under
+	apiPrefix string
+
+	// enableTrailers is ENABLE_TRAILERS: streams end with timing trailers
+	enableTrailers bool
+
+	// filler pads analytics responses by pad_bytes, up to MAX_PAD_BYTES
+	filler *padding.Filler
+
+	// healthDegradedAfter is HEALTH_DEGRADED_MS, the dependency latency
+	// /api/v1/health/detailed reports as degraded
+	healthDegradedAfter = health.DefaultDegradedAfter
+
+	// computeCache is set when ENABLE_COMPUTE_CACHE=true
+	computeCache *compute.Cache
+	// heavySem bounds concurrent heavy computations
+	heavySem *compute.Semaphore
+	// jobQueue runs /compute/async jobs on ASYNC_WORKERS workers
+	jobQueue *compute.JobQueue
+	// clientCounter is set unless ENABLE_CLIENT_STATS=false
+	clientCounter *clientstats.Counter
+	trustProxy    bool
+
+	// authVerifier is set when JWT_SECRET is, and mints and checks tokens
+	authVerifier *jwtauth.Verifier
 )
 
 type User struct {
@@ -29,271 +119,1921 @@ type User struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
-type ComputeResult struct {
-	ResultHash string `json:"result_hash"`
-	TotalSum   int64  `json:"total_sum"`
-	MatrixSize int    `json:"matrix_size"`
-	Iterations int    `json:"iterations"`
-	ElapsedMs  int64  `json:"elapsed_ms"`
+// createdUser is the createUser response: the user's fields plus whether the
+// prepared-statement path served the insert.
+type createdUser struct {
+	User
+	Prepared             bool  `json:"prepared"`
+	SimulatedDBLatencyMs int64 `json:"simulated_db_latency_ms,omitempty"`
+	Retries              int   `json:"retries,omitempty"`
+}
+
+// fetchedUser is the getUser response: the user's fields plus how many
+// times a transient DB error was retried.
+type fetchedUser struct {
+	User
+	Retries int `json:"retries,omitempty"`
+}
+
+// computeJobStatus is the getComputeJob response.
+type computeJobStatus struct {
+	Endpoint  string `json:"endpoint"`
+	Framework string `json:"framework"`
+	compute.Job
 }
 
 func main() {
 	startTime = time.Now()
 
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	config.SetMaxProcs(cfg.MaxProcs)
+
+	// Response JSON encoder (JSON_ENCODER=stdlib|jsoniter)
+	encoder, err = jsonenc.New(cfg.JSONEncoder)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	log.Printf("✓ JSON encoder: %s", encoder.Name())
+
+	// Distributed tracing, only when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.OTLPEndpoint, "chi-carbon-test")
+	if err != nil {
+		log.Fatalf("Tracing setup failed: %v", err)
+	}
+	if tracing.Enabled() {
+		log.Printf("✓ Tracing: exporting to %s", cfg.OTLPEndpoint)
+	}
+
 	// Initialize database
-	initDB()
-	defer db.Close()
+	initDB(cfg.DB)
+
+	// Resource snapshots in the log every STATS_INTERVAL_SECONDS
+	stopStats := statslog.Start(cfg.StatsInterval, db)
+	if cfg.StatsInterval > 0 {
+		log.Printf("✓ Stats logging every %s", cfg.StatsInterval)
+	}
+
+	heavySem = compute.NewSemaphore(cfg.MaxConcurrentHeavy, cfg.HeavyQueueTimeout)
+	jobQueue = compute.NewJobQueue(cfg.AsyncWorkers, cfg.AsyncQueueDepth)
+
+	if cfg.ComputeCache {
+		computeCache = compute.NewCache(cfg.ComputeCacheSize, cfg.ComputeCacheTTL)
+		log.Printf("✓ Compute cache: %d entries, TTL %s", cfg.ComputeCacheSize, cfg.ComputeCacheTTL)
+	}
+
+	// Weather upstreams: Open-Meteo for fetch, optional real upstream for external
+	weatherFetcher = weather.NewOpenMeteo(cfg.WeatherCacheTTL, cfg.WeatherUpstreamTimeout)
+	if cfg.WeatherUpstreamURL != "" {
+		weatherUpstream = weather.NewUpstream(cfg.WeatherUpstreamURL, cfg.WeatherUpstreamTimeout)
+		log.Printf("✓ Weather upstream: %s (timeout %s)", cfg.WeatherUpstreamURL, cfg.WeatherUpstreamTimeout)
+	}
+	if tracing.Enabled() {
+		weatherFetcher.Client.Transport = tracing.Transport(http.DefaultTransport)
+		if weatherUpstream != nil {
+			weatherUpstream.Client.Transport = tracing.Transport(http.DefaultTransport)
+		}
+	}
+
+	// WebSocket echo server for the streaming workload
+	wsServer = wsecho.New(cfg.WSMaxConnections)
+
+	healthDegradedAfter = cfg.HealthDegradedAfter
+
+	// Filler for pad_bytes, generated once up to MAX_PAD_BYTES
+	filler = padding.New(cfg.MaxPadBytes)
+
+	enableTrailers = cfg.Trailers
+	if enableTrailers {
+		log.Printf("✓ Stream trailers: %s", trailer.Declaration)
+	}
+
+	// Carbon estimation model
+	carbon.Configure(cfg.CPUWattsPerCore, cfg.GridIntensity.Static)
+	if cfg.GridIntensity.Live() {
+		carbon.SetProvider(cfg.GridIntensity.Provider())
+		log.Printf("✓ Grid intensity: %s", cfg.GridIntensity)
+	}
+
+	router := newRouter(cfg)
+
+	// Cleartext HTTP/2 is opt-in; clients that don't upgrade still get HTTP/1.1
+	var handler http.Handler = router
+	protocol := "HTTP/1.1"
+	switch {
+	case cfg.TLS.Enabled:
+		// net/http negotiates HTTP/2 over TLS by itself
+		protocol = "TLS, HTTP/1.1 + h2"
+	case cfg.EnableH2C:
+		handler = h2c.NewHandler(handler, &http2.Server{})
+		protocol = "HTTP/1.1 + h2c"
+	}
+
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Handler: handler,
+	}
+	cfg.ServerTimeouts.Apply(srv)
+	log.Printf("✓ Server timeouts: %s", cfg.ServerTimeouts)
+
+	// LISTEN_UNIX_SOCKET replaces the TCP port entirely
+	network, address := "tcp", srv.Addr
+	var ln net.Listener
+	if cfg.UnixSocket != "" {
+		var err error
+		if ln, err = unixsock.Listen(cfg.UnixSocket); err != nil {
+			log.Fatalf("Failed to listen on %s: %v", cfg.UnixSocket, err)
+		}
+		network, address = "unix", cfg.UnixSocket
+	}
+
+	go func() {
+		log.Printf("🚀 Chi server starting on %s %s (%s)", network, address, protocol)
+		var err error
+		switch {
+		case ln != nil:
+			err = srv.Serve(ln)
+		case cfg.TLS.Enabled:
+			err = srv.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		default:
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	// SELF_CHECK requests every GET endpoint against the live server and
+	// exits nonzero if any of them doesn't answer 200
+	if cfg.SelfCheck {
+		go func() {
+			target := selfcheck.Target{Network: network, Address: address, TLS: cfg.TLS.Enabled, Prefix: apiPrefix}
+			if cfg.Auth.Required {
+				token, err := authVerifier.Mint(jwtauth.DefaultSubject, time.Hour)
+				if err != nil {
+					log.Fatalf("Self-check token: %v", err)
+				}
+				target.Token = token.AccessToken
+			}
+			if err := selfcheck.Run(target); err != nil {
+				log.Printf("✗ Self-check failed: %v", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	shutdown(quit, cfg, srv, shutdownTracing, stopStats)
+}
+
+// shutdown waits for SIGINT/SIGTERM on quit, then stops srv accepting and
+// drains in-flight requests, flushes metrics and traces, and only then closes
+// the DB so nothing recorded by the last requests is lost.
+func shutdown(quit <-chan os.Signal, cfg config.Config, srv *http.Server, shutdownTracing func(context.Context) error, stopStats func()) {
+	<-quit
+
+	log.Printf("Shutting down server (timeout %s)...", cfg.ShutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("⚠️  Server shutdown warning: %v", err)
+	}
+	// Abandon async jobs nobody can poll for any more
+	jobQueue.Close()
+	if cfg.PushgatewayURL != "" {
+		err := push.New(cfg.PushgatewayURL, "chi-carbon-test").
+			Gatherer(prometheus.DefaultGatherer).
+			PushContext(ctx)
+		if err != nil {
+			log.Printf("⚠️  Metrics push warning: %v", err)
+		} else {
+			log.Printf("✓ Metrics pushed to %s", cfg.PushgatewayURL)
+		}
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("⚠️  Tracing shutdown warning: %v", err)
+	}
+
+	// Stop logging snapshots before the pool they read is closed
+	stopStats()
 
-	r := chi.NewRouter()
+	if db != nil {
+		if err := db.Close(); err != nil {
+			log.Printf("⚠️  Database close warning: %v", err)
+		}
+	}
+	log.Println("✓ Server stopped")
+}
+
+// newRouter builds the router: the middleware stack cfg asks for, then every
+// route under API_PREFIX.
+func newRouter(cfg config.Config) *chi.Mux {
+	router := chi.NewRouter()
+	trustProxy = cfg.TrustProxy
+	authVerifier = cfg.Auth.Verifier()
+	if cfg.NoMiddleware {
+		log.Println("⚠️  No middleware: routes only, without logging, recovery, metrics or body limits")
+	} else {
+		useMiddleware(router, cfg)
+	}
+
+	// Unknown paths and wrong methods answer in the error envelope too,
+	// rather than chi's plain-text 404 and empty 405
+	router.NotFound(notFound)
+	router.MethodNotAllowed(methodNotAllowed)
+
+	// Every route hangs off API_PREFIX, empty unless instances share a
+	// reverse proxy that routes by path
+	routes := func(r chi.Router) {
+		// Root endpoint
+		r.Get("/", rootHandler)
+
+		// Liveness and readiness probes
+		r.Get("/api/v1/health", healthHandler)
+		r.Get("/api/v1/health/detailed", healthDetailedHandler)
+		r.Get("/api/v1/version", versionHandler)
+		r.Get("/api/v1/routes", routesHandler(router))
+		r.Get("/api/v1/ready", readyHandler)
+
+		// Test tokens for REQUIRE_AUTH=true, whenever JWT_SECRET is set
+		if authVerifier != nil {
+			r.Get(jwtauth.TokenPath, authToken)
+		}
+
+		// Process resource metrics
+		r.Get("/api/v1/metrics", metricsHandler)
+
+		// Request counts per client IP
+		r.Get("/api/v1/clients", clientsHandler)
+
+		// Prometheus scrape endpoint
+		r.Handle("/metrics", promhttp.Handler())
+
+		// Analytics endpoints
+		r.Group(func(r chi.Router) {
+			r.Use(timeoutMiddleware(cfg.RequestTimeout))
+			r.Get("/api/v1/weather/analytics/heavy", analyticsHeavy)
+			r.Post("/api/v1/weather/analytics/heavy", analyticsHeavy)
+			r.Get("/api/v1/weather/analytics/light", analyticsLight)
+			r.Get("/api/v1/weather/analytics/medium", analyticsMedium)
+			r.Get("/api/v1/weather/analytics/memory", analyticsMemory)
+			r.Get("/api/v1/weather/analytics/fanout", analyticsFanout)
+			r.Post("/api/v1/weather/analytics/batch", analyticsBatch)
+			r.Get("/api/v1/weather/analytics/stream", analyticsStream)
+			r.Get("/api/v1/analytics/compare", analyticsCompare)
+		})
+
+		// Heavy compute timing summary; DELETE reads and resets it
+		r.Get("/api/v1/compute/stats", computeStats)
+		r.Delete("/api/v1/compute/stats", resetComputeStats)
 
-	// Middleware
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
+		// I/O endpoints
+		r.Get("/api/v1/weather/external", weatherExternal)
+		r.Get("/api/v1/weather/fetch", weatherFetch)
+		r.Get("/api/v1/io/file", fileIO)
 
-	// Root endpoint
-	r.Get("/", rootHandler)
+		// Serialization endpoints
+		r.Get("/api/v1/bench/json", benchJSON)
 
-	// Health check
-	r.Get("/api/v1/health", healthHandler)
+		// Database endpoints, under the request timeout so a query is
+		// cancelled along with its request; creates honour Idempotency-Key
+		// so a client's retries don't insert duplicates
+		idempotent := idempotencyMiddleware(idempotency.New(cfg.IdempotencyTTL))
+		r.Group(func(r chi.Router) {
+			r.Use(timeoutMiddleware(cfg.RequestTimeout))
+			r.Get("/api/v1/db/users", getUsers)
+			r.With(idempotent).Post("/api/v1/db/users", createUser)
+			r.Post("/api/v1/db/users/bulk", bulkCreateUsers)
+			r.Get("/api/v1/db/users/{id}", getUser)
+			r.Put("/api/v1/db/users/{id}", updateUser)
+			r.Delete("/api/v1/db/users/{id}", deleteUser)
+			r.Get("/api/v1/db/stress", dbStress)
+			r.Get("/api/v1/db/acquire", dbAcquire)
+			r.Get("/api/v1/db/stats", dbStats)
+		})
+		r.Get("/api/v1/compute/stream-json", streamUsers)
 
-	// Analytics endpoints
-	r.Get("/api/v1/weather/analytics/heavy", analyticsHeavy)
-	r.Get("/api/v1/weather/analytics/light", analyticsLight)
-	r.Get("/api/v1/weather/analytics/medium", analyticsMedium)
+		// Asynchronous compute: submit a job, then poll for its result
+		r.Post("/api/v1/compute/async", submitComputeJob)
+		r.Get("/api/v1/compute/async/{id}", getComputeJob)
 
-	// I/O endpoints
-	r.Get("/api/v1/weather/external", weatherExternal)
-	r.Get("/api/v1/weather/fetch", weatherFetch)
+		// Streaming endpoints
+		r.Handle("/api/v1/ws", wsServer)
+	}
+	apiPrefix = cfg.APIPrefix
+	if apiPrefix == "" {
+		routes(router)
+	} else {
+		router.Route(apiPrefix, routes)
+		log.Printf("✓ API prefix: %s", apiPrefix)
+	}
+
+	// Live profiling, off unless ENABLE_PPROF=true. It stays outside
+	// API_PREFIX: net/http/pprof finds profiles under /debug/pprof/ only.
+	if cfg.EnablePprof {
+		router.Mount("/debug", middleware.Profiler())
+		log.Println("✓ pprof enabled at /debug/pprof/")
+	}
 
-	// Database endpoints
-	r.Get("/api/v1/db/users", getUsers)
-	r.Post("/api/v1/db/users", createUser)
+	return router
+}
 
-	log.Println("🚀 Chi server starting on :8000")
-	http.ListenAndServe(":8000", r)
+// useMiddleware installs the global middleware stack on router, in the
+// order requests pass through it. NO_MIDDLEWARE=true skips it.
+func useMiddleware(router *chi.Mux, cfg config.Config) {
+	router.Use(inflightMiddleware)
+	if cfg.BenchmarkMode {
+		log.Println("✓ Benchmark mode: request logging disabled")
+	} else {
+		router.Use(middleware.Logger)
+	}
+	router.Use(recoverMiddleware)
+	router.Use(headersMiddleware)
+	router.Use(prometheusMiddleware)
+	if cfg.ClientStats {
+		clientCounter = clientstats.New()
+		router.Use(clientStatsMiddleware(clientCounter, cfg.TrustProxy))
+	}
+	if cfg.CORS.Enabled() {
+		router.Use(corsMiddleware(cfg.CORS))
+		log.Printf("✓ CORS enabled for %s", strings.Join(cfg.CORS.AllowedOrigins, ", "))
+	}
+	if tracing.Enabled() {
+		router.Use(tracingMiddleware)
+	}
+	if cfg.CPUAccounting {
+		router.Use(cpuTimeMiddleware)
+	}
+	if cfg.GoroutineTracking {
+		router.Use(goroutineMiddleware)
+	}
+	if limiter := ratelimit.New(cfg.RateLimitRPS, cfg.RateLimitBurst); limiter != nil {
+		router.Use(rateLimitMiddleware(limiter))
+	}
+	if cfg.Auth.Required {
+		router.Use(authMiddleware(authVerifier))
+		log.Println("✓ Authentication required: HS256 bearer tokens signed with JWT_SECRET")
+	}
+	if injector := faultinject.New(cfg.FaultRate, cfg.FaultLatency, cfg.FaultLatencyRate, cfg.FaultSeed); injector != nil {
+		router.Use(faultMiddleware(injector))
+		log.Printf("⚠️  Fault injection enabled: FAULT_RATE=%g, FAULT_LATENCY_MS=%d, FAULT_LATENCY_RATE=%g", cfg.FaultRate, cfg.FaultLatency.Milliseconds(), cfg.FaultLatencyRate)
+	}
+	if cfg.StrictParams {
+		router.Use(strictParamsMiddleware)
+		log.Println("✓ Strict query parameters: unknown or malformed ones answer 400")
+	}
+	router.Use(middleware.RequestSize(cfg.MaxBodyBytes))
 }
 
-func initDB() {
-	dbHost := getEnv("DB_HOST", "localhost")
-	dbPort := getEnv("DB_PORT", "5432")
-	dbName := getEnv("DB_NAME", "mydb")
-	dbUser := getEnv("DB_USER", "postgres")
-	dbPassword := getEnv("DB_PASSWORD", "1234")
+func initDB(cfg config.DBConfig) {
+	dbLatency = cfg.SimulatedLatency
+	if dbLatency > 0 {
+		log.Printf("✓ Simulated DB latency: %s", dbLatency)
+	}
+
+	if cfg.Driver == config.DriverSQLite {
+		openSQLite()
+	} else {
+		openServer(cfg)
+		if !dbReady && cfg.FallbackSQLite {
+			log.Printf("⚠️  %s unavailable, falling back to in-memory SQLite", cfg.Driver)
+			openSQLite()
+		}
+	}
+
+	if dbReady && cfg.PreparedStatements {
+		if err := prepareStatements(); err != nil {
+			log.Printf("⚠️  Prepared statement warning: %v", err)
+		} else {
+			log.Println("✓ Prepared statements ready")
+		}
+	}
+}
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
+// openServer connects to the PostgreSQL or MySQL server named by cfg.
+func openServer(cfg config.DBConfig) {
+	dialect = store.DialectFor(cfg.Driver)
+	dbDriver = cfg.Driver
 
 	var err error
-	db, err = sql.Open("postgres", connStr)
+	db, err = sql.Open(dialect.Name, cfg.DSN())
 	if err != nil {
 		log.Printf("⚠️  Database connection warning: %v", err)
 		return
 	}
 
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(2)
-	db.SetConnMaxLifetime(30 * time.Second)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	log.Printf("✓ DB pool: max_open=%d max_idle=%d max_lifetime=%s", cfg.MaxOpenConns, cfg.MaxIdleConns, cfg.ConnMaxLifetime)
 
 	if err = db.Ping(); err != nil {
 		log.Printf("⚠️  Database ping warning: %v", err)
 	} else {
-		log.Println("✓ Database connected")
+		dbReady = true
+		log.Printf("✓ Database connected (%s)", cfg.Driver)
+	}
+
+	if dbReady && cfg.AutoMigrate {
+		created, err := dialect.MigrateUsers(context.Background(), db)
+		switch {
+		case err != nil:
+			log.Printf("⚠️  Migration warning: %v", err)
+		case created:
+			log.Println("✓ Migration: created users table")
+		default:
+			log.Println("✓ Migration: users table already present")
+		}
+	}
+}
+
+// openSQLite replaces db with a fresh in-memory SQLite database that already
+// holds the users table.
+func openSQLite() {
+	if db != nil {
+		db.Close()
 	}
+
+	var err error
+	dialect = store.Postgres
+	dbDriver = config.DriverSQLite
+	db, err = memdb.Open()
+	if err != nil {
+		log.Printf("⚠️  SQLite warning: %v", err)
+		db = nil
+		return
+	}
+	dbReady = true
+	log.Println("✓ Using in-memory SQLite database")
+}
+
+// prepareStatements parses the user queries once so handlers skip the
+// per-request parse on the server side. On failure the handlers fall back
+// to unprepared queries.
+func prepareStatements() error {
+	selectStmt, err := db.Prepare(dialect.SelectUsers)
+	if err != nil {
+		return err
+	}
+	insertStmt, err := db.Prepare(dialect.InsertUser)
+	if err != nil {
+		selectStmt.Close()
+		return err
+	}
+
+	selectUsersStmt, insertUserStmt = selectStmt, insertStmt
+	return nil
 }
 
 func rootHandler(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"service":        "Weather Analytics Service",
-		"framework":      "Chi",
-		"version":        "1.0.0",
-		"status":         "running",
-		"uptime_seconds": int(time.Since(startTime).Seconds()),
+	respondJSON(w, r, http.StatusOK, api.RootResponse{
+		Service:       "Weather Analytics Service",
+		Framework:     "Chi",
+		Version:       "1.0.0",
+		Status:        "running",
+		UptimeSeconds: int(time.Since(startTime).Seconds()),
 	})
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	uptimeMs := time.Since(startTime).Milliseconds()
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"status":         "healthy",
-		"framework":      "chi",
-		"uptime_seconds": uptimeMs / 1000,
-		"uptime_ms":      uptimeMs,
-		"timestamp":      time.Now().UnixMilli(),
+	respondJSON(w, r, http.StatusOK, api.HealthResponse{
+		Status:        "healthy",
+		Framework:     "chi",
+		Gomaxprocs:    runtime.GOMAXPROCS(0),
+		NumCPU:        runtime.NumCPU(),
+		UptimeSeconds: uptimeMs / 1000,
+		UptimeMs:      uptimeMs,
+		Timestamp:     time.Now().UnixMilli(),
 	})
 }
 
-func analyticsHeavy(w http.ResponseWriter, r *http.Request) {
-	size := parseIntParam(r, "size", 5000)
-	iterations := parseIntParam(r, "iterations", 5)
+// healthDetailedHandler pings the database and, when configured, the weather
+// upstream, reporting each one's latency and an overall status. It answers
+// 503 when the database is down.
+func healthDetailedHandler(w http.ResponseWriter, r *http.Request) {
+	probes := []health.Probe{health.Database(db, dbReady)}
+	if weatherUpstream != nil {
+		probes = append(probes, health.Upstream(weatherUpstream))
+	}
 
-	result := heavyCompute(size, iterations)
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"endpoint":    "heavy_analytics",
-		"framework":   "chi",
-		"result_hash": result.ResultHash,
-		"total_sum":   result.TotalSum,
-		"matrix_size": result.MatrixSize,
-		"iterations":  result.Iterations,
-		"elapsed_ms":  result.ElapsedMs,
-	})
+	report := health.Run(r.Context(), healthDegradedAfter, probes...)
+	report.Framework = "chi"
+	respondJSON(w, r, report.HTTPStatus(), report)
 }
 
-func analyticsLight(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
+// versionHandler reports the build metadata so benchmark results can be
+// traced back to the exact binary.
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, r, http.StatusOK, api.NewVersionResponse("chi"))
+}
 
-	var result int64
-	for i := 0; i < 1000; i++ {
-		result += int64(i * i)
+// authToken mints a token signed with JWT_SECRET for the sub query
+// parameter, valid for ttl_seconds, so load generators can authenticate when
+// REQUIRE_AUTH=true. It is a benchmark helper: anyone who can reach it can
+// get a token.
+func authToken(w http.ResponseWriter, r *http.Request) {
+	ttl, err := clampedIntParam(r, "ttl_seconds", jwtauth.DefaultTTLSeconds, 1, jwtauth.MaxTTLSeconds)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
 	}
-
-	elapsedMs := time.Since(start).Milliseconds()
-
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"endpoint":   "light_analytics",
-		"framework":  "chi",
-		"result":     result,
-		"elapsed_ms": elapsedMs,
-	})
+	subject := r.URL.Query().Get("sub")
+	if subject == "" {
+		subject = jwtauth.DefaultSubject
+	}
+	token, err := authVerifier.Mint(subject, time.Duration(ttl)*time.Second)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	respondJSON(w, r, http.StatusOK, token)
 }
 
-func analyticsMedium(w http.ResponseWriter, r *http.Request) {
-	size := parseIntParam(r, "size", 2000)
-	iterations := parseIntParam(r, "iterations", 3)
+// routesHandler lists every method and path registered on the router, so the
+// harness can check that all frameworks expose the same API before a run.
+func routesHandler(router chi.Routes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var routes []api.Route
+		err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			routes = append(routes, api.Route{Method: method, Path: route})
+			return nil
+		})
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+			return
+		}
+		respondJSON(w, r, http.StatusOK, routesResponse(routes))
+	}
+}
 
-	result := heavyCompute(size, iterations)
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"endpoint":    "medium_analytics",
-		"framework":   "chi",
-		"result_hash": result.ResultHash,
-		"total_sum":   result.TotalSum,
-		"matrix_size": result.MatrixSize,
-		"iterations":  result.Iterations,
-		"elapsed_ms":  result.ElapsedMs,
-	})
+// routesResponse lists routes under the API prefix they are served at.
+func routesResponse(routes []api.Route) api.RoutesResponse {
+	resp := api.NewRoutesResponse("chi", routes)
+	resp.Prefix = apiPrefix
+	return resp
 }
 
-func weatherExternal(w http.ResponseWriter, r *http.Request) {
-	delayMs := parseIntParam(r, "delay_ms", 100)
-	start := time.Now()
+// readyHandler is the readiness probe. Unlike healthHandler, which only
+// reports that the process is alive, it fails while the database can't be
+// reached so orchestrators stop routing traffic to this instance.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
 
-	time.Sleep(time.Duration(delayMs) * time.Millisecond)
+	if db == nil {
+		respondJSON(w, r, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":            "not_ready",
+			"framework":         "chi",
+			"failed_dependency": "database",
+			"error":             "database not initialized",
+		})
+		return
+	}
 
-	weatherData := map[string]interface{}{
-		"temperature": 25.5,
-		"humidity":    65,
-		"wind_speed":  12.3,
-		"conditions":  "Partly Cloudy",
-		"location":    "Colombo, LK",
+	ctx, span := tracing.StartDB(ctx, dbDriver, "PING", "")
+	err := db.PingContext(ctx)
+	tracing.End(span, err)
+	if err != nil {
+		respondJSON(w, r, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":            "not_ready",
+			"framework":         "chi",
+			"failed_dependency": "database",
+			"error":             err.Error(),
+		})
+		return
 	}
 
-	elapsedMs := time.Since(start).Milliseconds()
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":       "ready",
+		"framework":    "chi",
+		"dependencies": map[string]interface{}{"database": "ok"},
+	})
+}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"endpoint":           "external_api",
-		"framework":          "chi",
-		"data":               weatherData,
-		"simulated_delay_ms": delayMs,
-		"elapsed_ms":         elapsedMs,
+// metricsHandler reports process-level resource usage so throughput can be
+// correlated with memory pressure and CPU time without an external agent.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	var usage syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &usage)
+	userSeconds := float64(usage.Utime.Nano()) / 1e9
+	systemSeconds := float64(usage.Stime.Nano()) / 1e9
+
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"framework":        "chi",
+		"uptime_seconds":   int(time.Since(startTime).Seconds()),
+		"goroutines":       runtime.NumGoroutine(),
+		"gomaxprocs":       runtime.GOMAXPROCS(0),
+		"num_cpu":          runtime.NumCPU(),
+		"websockets":       wsServer.Active(),
+		"current_inflight": requests.Current(),
+		"peak_inflight":    requests.Peak(),
+		"heavy": map[string]interface{}{
+			"in_flight": heavySem.InFlight(),
+			"limit":     heavySem.Limit(),
+		},
+		"memory": map[string]interface{}{
+			"alloc_bytes":       mem.Alloc,
+			"total_alloc_bytes": mem.TotalAlloc,
+			"sys_bytes":         mem.Sys,
+			"num_gc":            mem.NumGC,
+			"pause_total_ns":    mem.PauseTotalNs,
+		},
+		"cpu": map[string]interface{}{
+			"user_seconds":   userSeconds,
+			"system_seconds": systemSeconds,
+			"total_seconds":  userSeconds + systemSeconds,
+		},
 	})
 }
 
-func weatherFetch(w http.ResponseWriter, r *http.Request) {
-	city := r.URL.Query().Get("city")
-	if city == "" {
-		city = "Colombo"
+// clientsHandler lists the clients that sent the most requests, to check
+// how a load generator spreads its traffic.
+func clientsHandler(w http.ResponseWriter, r *http.Request) {
+	if clientCounter == nil {
+		writeError(w, r, http.StatusServiceUnavailable, api.ErrorResponse{Message: "client accounting disabled"})
+		return
 	}
-	start := time.Now()
-
-	weatherData := map[string]interface{}{
-		"temperature": 28.0,
-		"windspeed":   10.5,
-		"weathercode": 1,
-		"note":        "Mock data",
+	limit, err := clampedIntParam(r, "limit", 10, 1, clientstats.MaxTop)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
 	}
 
-	elapsedMs := time.Since(start).Milliseconds()
-
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"endpoint":   "weather_fetch",
-		"framework":  "chi",
-		"city":       city,
-		"data":       weatherData,
-		"elapsed_ms": elapsedMs,
+	top := clientCounter.Top(limit)
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":           "clients",
+		"framework":          "chi",
+		"trust_proxy":        trustProxy,
+		"total_clients":      top.TotalClients,
+		"total_requests":     top.TotalRequests,
+		"untracked_requests": top.Untracked,
+		"clients":            top.Clients,
 	})
 }
 
-func getUsers(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query("SELECT id, name, email, created_at FROM users")
+func analyticsHeavy(w http.ResponseWriter, r *http.Request) {
+	p, err := heavyParams(r)
 	if err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		respondBodyError(w, r, err)
 		return
 	}
-	defer rows.Close()
-
-	var users []User
-	for rows.Next() {
-		var u User
-		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
-			continue
-		}
-		users = append(users, u)
+	warmupRuns, err := clampedIntParam(r, "warmup", 0, 0, compute.MaxWarmup)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	pad, err := padParam(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
 	}
 
-	respondJSON(w, http.StatusOK, users)
-}
+	// A client already holding the result for these parameters needs
+	// nothing recomputed or resent
+	if etag, ok := compute.KnownETag(p); ok && notModified(w, r, etag) {
+		return
+	}
 
-func createUser(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-		Name  string `json:"name"`
-		Email string `json:"email"`
+	warmup, err := boundedWarmup(r.Context(), p, warmupRuns)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(w, r, status, api.ErrorResponse{Message: message})
+		return
+	}
+	measurement := compute.StartHeapMeasurement(boolParam(r, "gc"))
+	result, hit, err := computeCache.Compute(r.Context(), p, heavySem)
+	heapDelta := measurement.Done()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(w, r, status, api.ErrorResponse{Message: message})
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if notModified(w, r, compute.ETag(result.ResultHash)) {
 		return
 	}
+	resp := map[string]interface{}{
+		"endpoint":    "heavy_analytics",
+		"framework":   "chi",
+		"result_hash": result.ResultHash,
+		"total_sum":   result.TotalSum,
+		"matrix_size": result.MatrixSize,
+		"iterations":  result.Iterations,
+		"kernel":      result.Kernel,
+		"elapsed_ms":  result.ElapsedMs,
 
-	var user User
-	err := db.QueryRow(
-		"INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id, name, email, created_at",
-		input.Name, input.Email,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+		"estimated_joules":    result.EstimatedJoules,
+		"estimated_co2_grams": result.EstimatedCO2Grams,
+	}
+	if result.Allocations != nil {
+		resp["allocations"] = result.Allocations
+	}
+	msg := computepb.FromResult("heavy_analytics", "chi", result, pad)
+	if computeCache != nil {
+		resp["cache"] = compute.CacheStatus(hit)
+		msg.Cache = compute.CacheStatus(hit)
+	}
+	if heapDelta != nil {
+		resp["heap"] = heapDelta
+	}
+	if warmup != nil {
+		resp["warmup"] = warmup
+	}
+	if pad != "" {
+		resp["padding"] = pad
+	}
+	respondCompute(w, r, resp, msg)
+}
 
+func analyticsLight(w http.ResponseWriter, r *http.Request) {
+	pad, err := padParam(r)
 	if err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, user)
-}
-
-func heavyCompute(size, iterations int) ComputeResult {
 	start := time.Now()
 
-	a := make([]int, size)
-	for i := 0; i < size; i++ {
-		a[i] = i
+	var result int64
+	for i := 0; i < 1000; i++ {
+		result += int64(i * i)
 	}
 
-	var total int64
-	for iteration := 0; iteration < iterations; iteration++ {
-		for _, x := range a {
-			total += int64(x*x) % int64(size+1)
-		}
-	}
+	elapsedMs := time.Since(start).Milliseconds()
 
-	hash := sha256.Sum256([]byte(fmt.Sprintf("%d", total)))
-	hashStr := hex.EncodeToString(hash[:])
+	respondJSON(w, r, http.StatusOK, api.LightAnalyticsResponse{
+		Endpoint:  "light_analytics",
+		Framework: "chi",
+		Result:    result,
+		ElapsedMs: elapsedMs,
+		Padding:   pad,
+	})
+}
+
+func analyticsMedium(w http.ResponseWriter, r *http.Request) {
+	p, err := computeParams(r, 2000, 3)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	warmupRuns, err := clampedIntParam(r, "warmup", 0, 0, compute.MaxWarmup)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	pad, err := padParam(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// A client already holding the result for these parameters needs
+	// nothing recomputed or resent
+	if etag, ok := compute.KnownETag(p); ok && notModified(w, r, etag) {
+		return
+	}
+
+	warmup, err := boundedWarmup(r.Context(), p, warmupRuns)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(w, r, status, api.ErrorResponse{Message: message})
+		return
+	}
+	measurement := compute.StartHeapMeasurement(boolParam(r, "gc"))
+	result, hit, err := computeCache.Compute(r.Context(), p, heavySem)
+	heapDelta := measurement.Done()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(w, r, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	if notModified(w, r, compute.ETag(result.ResultHash)) {
+		return
+	}
+	resp := map[string]interface{}{
+		"endpoint":    "medium_analytics",
+		"framework":   "chi",
+		"result_hash": result.ResultHash,
+		"total_sum":   result.TotalSum,
+		"matrix_size": result.MatrixSize,
+		"iterations":  result.Iterations,
+		"kernel":      result.Kernel,
+		"elapsed_ms":  result.ElapsedMs,
+
+		"estimated_joules":    result.EstimatedJoules,
+		"estimated_co2_grams": result.EstimatedCO2Grams,
+	}
+	if result.Allocations != nil {
+		resp["allocations"] = result.Allocations
+	}
+	msg := computepb.FromResult("medium_analytics", "chi", result, pad)
+	if computeCache != nil {
+		resp["cache"] = compute.CacheStatus(hit)
+		msg.Cache = compute.CacheStatus(hit)
+	}
+	if heapDelta != nil {
+		resp["heap"] = heapDelta
+	}
+	if warmup != nil {
+		resp["warmup"] = warmup
+	}
+	if pad != "" {
+		resp["padding"] = pad
+	}
+	respondCompute(w, r, resp, msg)
+}
+
+// analyticsMemory allocates many short-lived objects to keep the garbage
+// collector busy, reporting the allocations and GC pauses incurred.
+func analyticsMemory(w http.ResponseWriter, r *http.Request) {
+	p, err := memoryParams(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	measurement := compute.StartHeapMeasurement(boolParam(r, "gc"))
+	result, err := compute.MemoryPressure(r.Context(), p)
+	heapDelta := measurement.Done()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(w, r, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	resp := map[string]interface{}{
+		"endpoint":    "memory_analytics",
+		"framework":   "chi",
+		"objects":     result.Objects,
+		"object_size": result.ObjectSize,
+		"checksum":    result.Checksum,
+		"elapsed_ms":  result.ElapsedMs,
+		"allocations": result.Allocations,
+	}
+	if heapDelta != nil {
+		resp["heap"] = heapDelta
+	}
+	respondJSON(w, r, http.StatusOK, resp)
+}
+
+// analyticsFanout starts many goroutines that each do almost no work, to
+// measure goroutine creation and scheduling overhead as its own workload.
+func analyticsFanout(w http.ResponseWriter, r *http.Request) {
+	tasks, err := clampedIntParam(r, "tasks", 1000, 1, compute.MaxFanoutTasks)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	result, err := compute.Fanout(r.Context(), tasks)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(w, r, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":        "fanout_analytics",
+		"framework":       "chi",
+		"tasks":           result.Tasks,
+		"sum":             result.Sum,
+		"elapsed_ms":      result.ElapsedMs,
+		"ns_per_task":     result.NsPerTask,
+		"peak_goroutines": result.PeakGoroutines,
+	})
+}
+
+// analyticsCompare runs every compute kernel at the same size and
+// iterations and reports their timings relative to each other, to check
+// that each kernel scales with its complexity.
+func analyticsCompare(w http.ResponseWriter, r *http.Request) {
+	size, err := clampedIntParam(r, "size", 200, 1, compute.MaxMatmulSize)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	iterations, err := clampedIntParam(r, "iterations", 5, 1, compute.MaxIterations)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := compute.CheckCompare(size, iterations); err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	release, err := heavySem.Acquire(r.Context())
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(w, r, status, api.ErrorResponse{Message: message})
+		return
+	}
+	comparison, err := compute.Compare(r.Context(), size, iterations)
+	release()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(w, r, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":   "compare_analytics",
+		"framework":  "chi",
+		"size":       comparison.Size,
+		"iterations": comparison.Iterations,
+		"total_ops":  comparison.TotalOps,
+		"kernels":    comparison.Kernels,
+	})
+}
+
+// computeStats summarizes the wall times of every heavy computation this
+// process has run since startup or the last reset.
+func computeStats(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":  "compute_stats",
+		"framework": "chi",
+		"heavy":     compute.HeavyStats(),
+	})
+}
+
+// resetComputeStats returns the summary and clears it, so one call closes a
+// benchmark phase and starts the next.
+func resetComputeStats(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":  "compute_stats",
+		"framework": "chi",
+		"heavy":     compute.ResetHeavyStats(),
+		"reset":     true,
+	})
+}
+
+// analyticsBatch runs a list of compute jobs with bounded concurrency and
+// returns their results in request order.
+func analyticsBatch(w http.ResponseWriter, r *http.Request) {
+	var jobs []compute.Params
+	if err := json.NewDecoder(r.Body).Decode(&jobs); err != nil {
+		respondBodyError(w, r, err)
+		return
+	}
+
+	if len(jobs) == 0 || len(jobs) > compute.MaxBatchJobs {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{
+			Message: fmt.Sprintf("batch must contain between 1 and %d jobs", compute.MaxBatchJobs),
+		})
+		return
+	}
+
+	for i := range jobs {
+		if err := jobs[i].Validate(); err != nil {
+			writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error(), Details: map[string]int{"index": i}})
+			return
+		}
+	}
+
+	results, err := compute.RunBatch(r.Context(), jobs, runtime.NumCPU(), heavySem)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(w, r, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(w, r, http.StatusOK, results)
+}
+
+// submitComputeJob queues a heavy job, read the same way as analyticsHeavy's,
+// and answers 202 with its ID at once instead of holding the connection
+// until it's computed. A full queue answers 429.
+func submitComputeJob(w http.ResponseWriter, r *http.Request) {
+	p, err := heavyParams(r)
+	if err != nil {
+		respondBodyError(w, r, err)
+		return
+	}
+
+	job, err := jobQueue.Submit(p)
+	if err != nil {
+		w.Header().Set("Retry-After", "1")
+		writeError(w, r, http.StatusTooManyRequests, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	statusURL := apiPrefix + "/api/v1/compute/async/" + job.ID
+	w.Header().Set("Location", statusURL)
+	respondJSON(w, r, http.StatusAccepted, map[string]interface{}{
+		"endpoint":   "async_compute",
+		"framework":  "chi",
+		"job_id":     job.ID,
+		"status":     job.Status,
+		"status_url": statusURL,
+	})
+}
+
+// getComputeJob reports a queued job's status, with its result once done.
+func getComputeJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := jobQueue.Get(chi.URLParam(r, "id"))
+	if !ok {
+		writeError(w, r, http.StatusNotFound, api.ErrorResponse{Message: "job not found"})
+		return
+	}
+	respondJSON(w, r, http.StatusOK, computeJobStatus{Endpoint: "async_compute_status", Framework: "chi", Job: job})
+}
+
+// analyticsStream runs the heavy workload and streams an SSE "progress" event
+// after every iteration, finishing with a "result" event carrying the
+// ComputeResult.
+func analyticsStream(w http.ResponseWriter, r *http.Request) {
+	p, err := computeParams(r, 5000, 5)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// Queue before the stream starts, so a busy server still answers 503
+	release, err := heavySem.Acquire(r.Context())
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(w, r, status, api.ErrorResponse{Message: message})
+		return
+	}
+	defer release()
+
+	sse.SetHeaders(w)
+	if enableTrailers {
+		defer trailer.Declare(w).Finish()
+	}
+	w.WriteHeader(http.StatusOK)
+
+	result, err := compute.HeavyComputeWithProgress(r.Context(), p, func(progress compute.Progress) {
+		sse.WriteEvent(w, "progress", progress)
+	})
+	if err != nil {
+		// A cancelled context means the client went away; nobody is listening
+		if !errors.Is(err, context.Canceled) {
+			sse.WriteEvent(w, "error", api.ErrorResponse{Message: "compute timeout"}.ForStatus(http.StatusServiceUnavailable))
+		}
+		return
+	}
+
+	sse.WriteEvent(w, "result", result)
+}
+
+func weatherExternal(w http.ResponseWriter, r *http.Request) {
+	if weatherUpstream != nil {
+		weatherExternalUpstream(w, r)
+		return
+	}
+
+	delayMs := parseIntParam(r, "delay_ms", 100)
+	start := time.Now()
+
+	// Stop waiting as soon as the client goes away, so abandoned requests
+	// don't hold a goroutine for the full delay
+	timer := time.NewTimer(time.Duration(delayMs) * time.Millisecond)
+	select {
+	case <-r.Context().Done():
+		timer.Stop()
+		writeError(w, r, compute.StatusClientClosedRequest, api.ErrorResponse{Message: "client closed request"})
+		return
+	case <-timer.C:
+	}
+
+	weatherData := map[string]interface{}{
+		"temperature": 25.5,
+		"humidity":    65,
+		"wind_speed":  12.3,
+		"conditions":  "Partly Cloudy",
+		"location":    "Colombo, LK",
+	}
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":           "external_api",
+		"framework":          "chi",
+		"data":               weatherData,
+		"simulated_delay_ms": delayMs,
+		"elapsed_ms":         elapsedMs,
+	})
+}
+
+// weatherExternalUpstream proxies the configured upstream's JSON through,
+// exercising a real HTTP client instead of the simulated delay.
+func weatherExternalUpstream(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	data, err := weatherUpstream.Fetch(r.Context())
+	if err != nil {
+		writeError(w, r, http.StatusBadGateway, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":     "external_api",
+		"framework":    "chi",
+		"data":         data,
+		"upstream_url": weatherUpstream.URL,
+		"elapsed_ms":   elapsedMs,
+	})
+}
+
+func weatherFetch(w http.ResponseWriter, r *http.Request) {
+	if list := r.URL.Query().Get("cities"); list != "" {
+		weatherFetchMany(w, r, list)
+		return
+	}
+
+	city := r.URL.Query().Get("city")
+	if city == "" {
+		city = "Colombo"
+	}
+	start := time.Now()
+
+	weatherData, source, coalesced := weatherFetcher.Fetch(r.Context(), city)
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":   "weather_fetch",
+		"framework":  "chi",
+		"city":       city,
+		"data":       weatherData,
+		"source":     source,
+		"coalesced":  coalesced,
+		"elapsed_ms": elapsedMs,
+	})
+}
+
+// weatherFetchMany looks up every city of a comma-separated list
+// concurrently, at most parallelism at a time, and reports each city next to
+// the total elapsed time.
+func weatherFetchMany(w http.ResponseWriter, r *http.Request, list string) {
+	cities, err := weather.ParseCities(list)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	parallelism, err := clampedIntParam(r, "parallelism", weather.DefaultParallelism, 1, weather.MaxCities)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	start := time.Now()
+
+	reports := weatherFetcher.FetchMany(r.Context(), cities, parallelism)
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":    "weather_fetch",
+		"framework":   "chi",
+		"cities":      reports,
+		"count":       len(reports),
+		"parallelism": parallelism,
+		"elapsed_ms":  elapsedMs,
+	})
+}
+
+// fileIO writes a temp file, fsyncs it and reads it back, the disk
+// counterpart to the HTTP and database I/O endpoints.
+func fileIO(w http.ResponseWriter, r *http.Request) {
+	size, err := clampedIntParam(r, "bytes", diskio.DefaultBytes, 1, diskio.MaxBytes)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	result, err := diskio.RoundTrip(r.Context(), size)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":      "file_io",
+		"framework":     "chi",
+		"bytes_written": result.BytesWritten,
+		"bytes_read":    result.BytesRead,
+		"sha256":        result.SHA256,
+		"elapsed_ms":    result.ElapsedMs,
+		"write_us":      result.WriteUs,
+		"fsync_us":      result.FsyncUs,
+		"read_us":       result.ReadUs,
+	})
+}
+
+// benchJSON builds a nested payload of the requested depth and width and
+// serializes it with the configured encoder, reporting the encoded size and
+// marshalling time. Only the summary is sent, so the measurement is pure
+// serialization with no network write of the payload itself.
+func benchJSON(w http.ResponseWriter, r *http.Request) {
+	depth, err := clampedIntParam(r, "depth", 3, 0, jsonenc.MaxPayloadDepth)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	width, err := clampedIntParam(r, "width", 10, 1, jsonenc.MaxPayloadWidth)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	shape := r.URL.Query().Get("shape")
+	if shape == "" {
+		shape = jsonenc.ShapeStruct
+	}
+
+	payload, nodes, err := jsonenc.BuildPayload(depth, width, shape)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	result, err := jsonenc.MarshalTimed(encoder, payload)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":   "json_bench",
+		"framework":  "chi",
+		"encoder":    encoder.Name(),
+		"shape":      shape,
+		"depth":      depth,
+		"width":      width,
+		"nodes":      nodes,
+		"bytes":      result.Bytes,
+		"marshal_us": result.MarshalUs,
+	})
+}
+
+func getUsers(w http.ResponseWriter, r *http.Request) {
+	if !requireDB(w, r) {
+		return
+	}
+
+	limit, err := clampedIntParam(r, "limit", 100, 1, 1000)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	offset, err := clampedIntParam(r, "offset", 0, 0, math.MaxInt32)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	repeat, err := clampedIntParam(r, "repeat", 1, 1, store.MaxQueryRepeat)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// The extra runs go first, so the page served is the last one read
+	start := time.Now()
+	var repeatLatencyMs int64
+	retries, err := store.Retry(r.Context(), func() (err error) {
+		repeatLatencyMs, err = repeatUsers(r.Context(), limit, offset, repeat-1)
+		return err
+	})
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	if csvstream.Requested(r.URL.Query().Get("format"), r.Header.Get("Accept")) {
+		exportUsersCSV(w, r, limit, offset)
+		return
+	}
+
+	var users []User
+	var latencyMs int64
+	listRetries, err := store.Retry(r.Context(), func() (err error) {
+		users, latencyMs, err = listUsers(r.Context(), limit, offset)
+		return err
+	})
+	retries += listRetries
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	queryMs := float64(time.Since(start).Nanoseconds()) / 1e6
+	latencyMs += repeatLatencyMs
+
+	resp := map[string]interface{}{
+		"users":    users,
+		"count":    len(users),
+		"limit":    limit,
+		"offset":   offset,
+		"prepared": selectUsersStmt != nil,
+	}
+	if latencyMs > 0 {
+		resp["simulated_db_latency_ms"] = latencyMs
+	}
+	if repeat > 1 {
+		resp["repeat"] = repeat
+		resp["query_ms"] = queryMs
+	}
+	if retries > 0 {
+		resp["retries"] = retries
+	}
+	respondJSON(w, r, http.StatusOK, resp)
+}
+
+// listUsers reads one page of users, holding the connection for the
+// simulated DB latency before consuming the rows.
+func listUsers(ctx context.Context, limit, offset int) (users []User, latencyMs int64, err error) {
+	ctx, span := tracing.StartDB(ctx, dbDriver, "SELECT", dialect.SelectUsers)
+	defer func() { tracing.End(span, err) }()
+
+	var rows *sql.Rows
+	if selectUsersStmt != nil {
+		rows, err = selectUsersStmt.QueryContext(ctx, limit, offset)
+	} else {
+		rows, err = db.QueryContext(ctx, dialect.SelectUsers, limit, offset)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	latencyMs = simulateDBLatency()
+	users = make([]User, 0, limit)
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			continue
+		}
+		users = append(users, u)
+	}
+	return users, latencyMs, rows.Err()
+}
+
+// repeatUsers reads the same page of users times more and discards it, so
+// ?repeat= can weight a request towards the database. It returns the
+// simulated DB latency slept across the runs.
+func repeatUsers(ctx context.Context, limit, offset, times int) (latencyMs int64, err error) {
+	for i := 0; i < times; i++ {
+		_, ms, err := listUsers(ctx, limit, offset)
+		if err != nil {
+			return latencyMs, err
+		}
+		latencyMs += ms
+	}
+	return latencyMs, nil
+}
+
+// exportUsersCSV streams one page of users as a CSV attachment, writing
+// each row as it is read instead of collecting the page first. The status
+// line goes out before the rows, so a query failing mid-stream leaves the
+// export truncated.
+func exportUsersCSV(w http.ResponseWriter, r *http.Request, limit, offset int) {
+	ctx, span := tracing.StartDB(r.Context(), dbDriver, "SELECT", dialect.SelectUsers)
+	var rows *sql.Rows
+	var err error
+	if selectUsersStmt != nil {
+		rows, err = selectUsersStmt.QueryContext(ctx, limit, offset)
+	} else {
+		rows, err = db.QueryContext(ctx, dialect.SelectUsers, limit, offset)
+	}
+	if err != nil {
+		tracing.End(span, err)
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	simulateDBLatency()
+	csvstream.SetHeaders(w.Header(), csvstream.UsersFilename)
+	w.WriteHeader(http.StatusOK)
+	out, err := csvstream.NewUsers(w)
+	if err != nil {
+		tracing.End(span, err)
+		return
+	}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			continue
+		}
+		if err := out.Write(u.ID, u.Name, u.Email, u.CreatedAt); err != nil {
+			// The client went away; stop reading rows nobody will receive
+			tracing.End(span, err)
+			return
+		}
+	}
+	tracing.End(span, rows.Err())
+	out.Flush()
+}
+
+// dbStress runs the store.Stress workload: concurrent INSERT/SELECT
+// transactions issued from inside one request, so DB contention can be
+// measured apart from HTTP concurrency. Nothing it writes is committed.
+func dbStress(w http.ResponseWriter, r *http.Request) {
+	if !requireDB(w, r) {
+		return
+	}
+
+	workers, err := clampedIntParam(r, "workers", 4, 1, store.MaxStressWorkers)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ops, err := clampedIntParam(r, "ops", 100, 1, store.MaxStressOps)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	result, err := store.Stress(r.Context(), db, dialect, workers, ops)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(w, r, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":       "db_stress",
+		"framework":      "chi",
+		"workers":        result.Workers,
+		"ops_per_worker": result.OpsPerWorker,
+		"operations":     result.Operations,
+		"errors":         result.Errors,
+		"elapsed_ms":     result.ElapsedMs,
+		"ops_per_second": result.OpsPerSecond,
+		"avg_op_us":      result.AvgOpUs,
+		"max_op_us":      result.MaxOpUs,
+	})
+}
+
+// dbAcquire times n sequential connection checkouts from the pool with no
+// query on them, separating connection-management overhead from query
+// execution.
+func dbAcquire(w http.ResponseWriter, r *http.Request) {
+	if !requireDB(w, r) {
+		return
+	}
+
+	n, err := clampedIntParam(r, "n", 100, 1, store.MaxAcquires)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
 
-	elapsedMs := time.Since(start).Milliseconds()
+	result, err := store.Acquire(r.Context(), db, n)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(w, r, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":         "db_acquire",
+		"framework":        "chi",
+		"acquisitions":     result.Acquisitions,
+		"elapsed_ms":       result.ElapsedMs,
+		"min_us":           result.MinUs,
+		"mean_us":          result.MeanUs,
+		"p50_us":           result.P50Us,
+		"p90_us":           result.P90Us,
+		"p99_us":           result.P99Us,
+		"max_us":           result.MaxUs,
+		"wait_count":       result.WaitCount,
+		"wait_duration_ms": result.WaitDurationMs,
+	})
+}
+
+// streamUsers writes users as NDJSON while reading them, so the response is
+// never held in memory as a whole. Compare it with getUsers to weigh
+// streaming against buffered serialization.
+func streamUsers(w http.ResponseWriter, r *http.Request) {
+	if !requireDB(w, r) {
+		return
+	}
+
+	limit, err := clampedIntParam(r, "limit", 1000, 1, 100000)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	offset, err := clampedIntParam(r, "offset", 0, 0, math.MaxInt32)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	flushEvery, err := clampedIntParam(r, "flush_every", 100, 1, 10000)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	ctx, span := tracing.StartDB(r.Context(), dbDriver, "SELECT", dialect.SelectUsers)
+	rows, err := db.QueryContext(ctx, dialect.SelectUsers, limit, offset)
+	if err != nil {
+		tracing.End(span, err)
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	out := ndjson.NewWriter(w, flushEvery)
+	if enableTrailers {
+		defer trailer.Declare(w).Finish()
+	}
+	w.WriteHeader(http.StatusOK)
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			continue
+		}
+		if err := out.Write(u); err != nil {
+			// The client went away; stop reading rows nobody will receive
+			tracing.End(span, err)
+			return
+		}
+	}
+
+	// The status line is gone, so a failure mid-stream becomes a final line
+	err = rows.Err()
+	tracing.End(span, err)
+	if err != nil {
+		out.Write(api.ErrorResponse{Message: err.Error()}.ForStatus(http.StatusInternalServerError))
+	}
+	out.Flush()
+}
+
+// simulateDBLatency sleeps for DB_SIMULATED_LATENCY_MS and returns the delay
+// in milliseconds. Callers invoke it before consuming their result so the
+// pooled connection stays checked out for the whole delay.
+func simulateDBLatency() int64 {
+	if dbLatency <= 0 {
+		return 0
+	}
+	time.Sleep(dbLatency)
+	return dbLatency.Milliseconds()
+}
+
+// getUser fetches one user by primary key, answering 404 when no row matches.
+func getUser(w http.ResponseWriter, r *http.Request) {
+	if !requireDB(w, r) {
+		return
+	}
+
+	id, err := params.ID("user id", chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	var user User
+	retries, err := store.Retry(r.Context(), func() (err error) {
+		user, err = findUser(r.Context(), id)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, r, http.StatusNotFound, api.ErrorResponse{Message: "user not found"})
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(w, r, http.StatusOK, fetchedUser{User: user, Retries: retries})
+}
+
+// findUser fetches one user by primary key; sql.ErrNoRows means no match.
+func findUser(ctx context.Context, id int64) (user User, err error) {
+	ctx, span := tracing.StartDB(ctx, dbDriver, "SELECT", dialect.SelectUser)
+	err = db.QueryRowContext(ctx, dialect.SelectUser, id).Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		tracing.End(span, nil)
+	} else {
+		tracing.End(span, err)
+	}
+	return user, err
+}
+
+func createUser(w http.ResponseWriter, r *http.Request) {
+	if !requireDB(w, r) {
+		return
+	}
+
+	var input store.NewUser
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondBodyError(w, r, err)
+		return
+	}
+	if ferr := input.Validate(); ferr != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Code: api.CodeInvalidField, Field: ferr.Field, Message: ferr.Message})
+		return
+	}
+
+	var user User
+	var latencyMs int64
+	retries, err := store.RetryWrite(r.Context(), func() (err error) {
+		user, latencyMs, err = insertUser(r.Context(), input)
+		return err
+	})
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(w, r, http.StatusCreated, createdUser{User: user, Prepared: insertUserStmt != nil, SimulatedDBLatencyMs: latencyMs, Retries: retries})
+}
+
+// insertUser inserts one user and returns the created row, holding the
+// connection for the simulated DB latency before reading it.
+func insertUser(ctx context.Context, input store.NewUser) (user User, latencyMs int64, err error) {
+	ctx, span := tracing.StartDB(ctx, dbDriver, "INSERT", dialect.InsertUser)
+	defer func() { tracing.End(span, err) }()
+
+	if !dialect.Returning {
+		return insertUserReadBack(ctx, input)
+	}
+
+	var row *sql.Row
+	if insertUserStmt != nil {
+		row = insertUserStmt.QueryRowContext(ctx, input.Name, input.Email)
+	} else {
+		row = db.QueryRowContext(ctx, dialect.InsertUser, input.Name, input.Email)
+	}
+	latencyMs = simulateDBLatency()
+	err = row.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+	return user, latencyMs, err
+}
+
+// insertUserReadBack is insertUser for a dialect without RETURNING: the row
+// is read back by its generated id, in the same transaction so the pair
+// holds one connection like the single statement does.
+func insertUserReadBack(ctx context.Context, input store.NewUser) (user User, latencyMs int64, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return user, 0, err
+	}
+	defer tx.Rollback()
+
+	var result sql.Result
+	if insertUserStmt != nil {
+		result, err = tx.StmtContext(ctx, insertUserStmt).ExecContext(ctx, input.Name, input.Email)
+	} else {
+		result, err = tx.ExecContext(ctx, dialect.InsertUser, input.Name, input.Email)
+	}
+	if err != nil {
+		return user, 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return user, 0, err
+	}
+
+	latencyMs = simulateDBLatency()
+	err = tx.QueryRowContext(ctx, dialect.SelectUser, id).Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+	if err != nil {
+		return user, latencyMs, err
+	}
+	return user, latencyMs, tx.Commit()
+}
+
+// updateUser replaces a user's name and email, answering 404 when no row
+// matches the id.
+func updateUser(w http.ResponseWriter, r *http.Request) {
+	if !requireDB(w, r) {
+		return
+	}
+
+	id, err := params.ID("user id", chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	var input store.NewUser
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondBodyError(w, r, err)
+		return
+	}
+	if ferr := input.Validate(); ferr != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Code: api.CodeInvalidField, Field: ferr.Field, Message: ferr.Message})
+		return
+	}
+
+	var n int64
+	retries, err := store.RetryWrite(r.Context(), func() (err error) {
+		n, err = execUser(r.Context(), "UPDATE", dialect.UpdateUser, input.Name, input.Email, id)
+		return err
+	})
+	if err != nil {
+		if _, ok := store.ConstraintViolation(err, []store.NewUser{input}); ok {
+			writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	if n == 0 {
+		writeError(w, r, http.StatusNotFound, api.ErrorResponse{Message: "user not found"})
+		return
+	}
+
+	resp := map[string]interface{}{"id": id, "name": input.Name, "email": input.Email}
+	if retries > 0 {
+		resp["retries"] = retries
+	}
+	respondJSON(w, r, http.StatusOK, resp)
+}
 
-	return ComputeResult{
-		ResultHash: hashStr,
-		TotalSum:   total,
-		MatrixSize: size,
-		Iterations: iterations,
-		ElapsedMs:  elapsedMs,
+// deleteUser removes a user, answering 204 on success and 404 when no row
+// matches the id.
+func deleteUser(w http.ResponseWriter, r *http.Request) {
+	if !requireDB(w, r) {
+		return
+	}
+
+	id, err := params.ID("user id", chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// A 204 has no body, so retries made here go unreported
+	var n int64
+	_, err = store.RetryWrite(r.Context(), func() (err error) {
+		n, err = execUser(r.Context(), "DELETE", dialect.DeleteUser, id)
+		return err
+	})
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	if n == 0 {
+		writeError(w, r, http.StatusNotFound, api.ErrorResponse{Message: "user not found"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// execUser runs a single-row write and returns the number of rows it
+// affected, so callers can tell a missing user from a successful write.
+func execUser(ctx context.Context, operation, query string, args ...interface{}) (n int64, err error) {
+	ctx, span := tracing.StartDB(ctx, dbDriver, operation, query)
+	defer func() { tracing.End(span, err) }()
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// bulkCreateUsers inserts up to store.MaxBulkUsers users with a single
+// multi-row statement, rolling back the whole batch on any constraint
+// violation.
+func bulkCreateUsers(w http.ResponseWriter, r *http.Request) {
+	if !requireDB(w, r) {
+		return
+	}
+
+	var input []store.NewUser
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondBodyError(w, r, err)
+		return
+	}
+
+	if len(input) == 0 || len(input) > store.MaxBulkUsers {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{
+			Message: fmt.Sprintf("bulk insert must contain between 1 and %d users", store.MaxBulkUsers),
+		})
+		return
+	}
+
+	for i, u := range input {
+		if ferr := u.Validate(); ferr != nil {
+			writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Code: api.CodeInvalidField, Field: ferr.Field, Message: ferr.Message, Details: map[string]int{"index": i}})
+			return
+		}
+	}
+
+	if i := store.DuplicateEmailIndex(input); i >= 0 {
+		writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: "duplicate email in request", Details: map[string]int{"index": i}})
+		return
+	}
+
+	var users []User
+	retries, err := store.RetryWrite(r.Context(), func() (err error) {
+		users, err = insertUsers(r.Context(), input)
+		return err
+	})
+	if err != nil {
+		if index, ok := store.ConstraintViolation(err, input); ok {
+			resp := api.ErrorResponse{Message: err.Error()}
+			if index >= 0 {
+				resp.Details = map[string]int{"index": index}
+			}
+			writeError(w, r, http.StatusBadRequest, resp)
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	resp := map[string]interface{}{
+		"users": users,
+		"count": len(users),
+	}
+	if retries > 0 {
+		resp["retries"] = retries
+	}
+	respondJSON(w, r, http.StatusCreated, resp)
+}
+
+// dbStats reports the connection pool's state and cumulative counters, to
+// show whether requests are waiting on the pool during the DB benchmark.
+func dbStats(w http.ResponseWriter, r *http.Request) {
+	if !requireDB(w, r) {
+		return
+	}
+	respondJSON(w, r, http.StatusOK, map[string]interface{}{
+		"endpoint":  "db_stats",
+		"framework": "chi",
+		"pool":      store.Pool(db),
+	})
+}
+
+// insertUsers runs the bulk INSERT inside a transaction and returns the
+// created rows, read back by email where the dialect lacks RETURNING. Any
+// error rolls the transaction back.
+func insertUsers(ctx context.Context, input []store.NewUser) (users []User, err error) {
+	query, args := dialect.BulkInsertUsers(input)
+	ctx, span := tracing.StartDB(ctx, dbDriver, "INSERT", query)
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var rows *sql.Rows
+	if dialect.Returning {
+		rows, err = tx.QueryContext(ctx, query, args...)
+	} else if _, err = tx.ExecContext(ctx, query, args...); err == nil {
+		query, args = dialect.SelectInsertedUsers(input)
+		rows, err = tx.QueryContext(ctx, query, args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users = make([]User, 0, len(input))
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// respondBodyError answers a request whose body couldn't be read: 413 when it
+// exceeded MAX_BODY_BYTES, 400 for malformed or invalid JSON.
+func respondBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, r, http.StatusRequestEntityTooLarge, api.ErrorResponse{
+			Message: fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit),
+		})
+		return
+	}
+	writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+}
+
+// requireDB writes a 503 and returns false when the database never connected.
+func requireDB(w http.ResponseWriter, r *http.Request) bool {
+	if !dbReady {
+		writeError(w, r, http.StatusServiceUnavailable, api.ErrorResponse{Message: "database unavailable"})
+		return false
 	}
+	return true
 }
 
 func parseIntParam(r *http.Request, param string, defaultValue int) int {
@@ -305,15 +2045,156 @@ func parseIntParam(r *http.Request, param string, defaultValue int) int {
 	return defaultValue
 }
 
-func respondJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(data)
+// computeParams reads kernel, size, iterations, goroutines and seed from the
+// query string, applying the endpoint's defaults and the kernel's bounds.
+func computeParams(r *http.Request, defaultSize, defaultIterations int) (compute.Params, error) {
+	kernel, err := compute.ParseKernel(r.URL.Query().Get("kernel"))
+	if err != nil {
+		return compute.Params{}, err
+	}
+
+	size, err := clampedIntParam(r, "size", kernel.DefaultSize(defaultSize), 1, kernel.MaxSize())
+	if err != nil {
+		return compute.Params{}, err
+	}
+	iterations, err := clampedIntParam(r, "iterations", defaultIterations, 1, compute.MaxIterations)
+	if err != nil {
+		return compute.Params{}, err
+	}
+	goroutines, err := clampedIntParam(r, "goroutines", 1, 1, compute.MaxGoroutines)
+	if err != nil {
+		return compute.Params{}, err
+	}
+	seed, err := clampedIntParam(r, "seed", 0, 0, compute.MaxSeed)
+	if err != nil {
+		return compute.Params{}, err
+	}
+
+	return compute.Params{Kernel: kernel, Size: size, Iterations: iterations, Goroutines: goroutines, Seed: seed}, nil
+}
+
+// memoryParams reads the object count and size for analyticsMemory.
+func memoryParams(r *http.Request) (compute.MemoryParams, error) {
+	objects, err := clampedIntParam(r, "objects", 10000, 1, compute.MaxMemoryObjects)
+	if err != nil {
+		return compute.MemoryParams{}, err
+	}
+	size, err := clampedIntParam(r, "size", 1024, 1, compute.MaxMemoryObjectSize)
+	if err != nil {
+		return compute.MemoryParams{}, err
+	}
+
+	p := compute.MemoryParams{Objects: objects, Size: size}
+	if err := p.Validate(); err != nil {
+		return compute.MemoryParams{}, err
+	}
+	return p, nil
+}
+
+// boundedWarmup runs compute.Warmup under its own heavySem slot, so warm-up
+// runs count against MAX_CONCURRENT_HEAVY like the measured one.
+func boundedWarmup(ctx context.Context, p compute.Params, n int) (*compute.WarmupInfo, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	release, err := heavySem.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return compute.Warmup(ctx, p, n)
+}
+
+// heavyParams reads the heavy job from a JSON body on POST requests sent as
+// application/json, and from the query string otherwise. Both paths share
+// the defaults and bounds.
+func heavyParams(r *http.Request) (compute.Params, error) {
+	if r.Method != http.MethodPost || !params.IsJSON(r.Header.Get("Content-Type")) {
+		return computeParams(r, 5000, 5)
+	}
+	return compute.DecodeParams(r.Body, compute.Params{Size: 5000, Iterations: 5})
+}
+
+// clampedIntParam is parseIntParam with bounds: out-of-range values are
+// rejected rather than handed to the workload.
+func clampedIntParam(r *http.Request, param string, defaultValue, minValue, maxValue int) (int, error) {
+	return params.ClampedInt(param, r.URL.Query().Get(param), defaultValue, minValue, maxValue)
+}
+
+// padParam reads pad_bytes and returns that much filler for the response's
+// padding field, or "" for none.
+func padParam(r *http.Request) (string, error) {
+	n, err := clampedIntParam(r, "pad_bytes", 0, 0, filler.Max())
+	if err != nil {
+		return "", err
+	}
+	return filler.Pad(n), nil
+}
+
+// boolParam reads a boolean query flag; see params.Bool.
+func boolParam(r *http.Request, param string) bool {
+	return params.Bool(r.URL.Query().Get(param))
+}
+
+// notModified tags the response with etag and, when the request's
+// If-None-Match already names that tag, answers 304 with no body and returns
+// true. The 304 varies on Accept like the response it stands in for.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	api.SetETag(w.Header(), etag)
+	if !compute.MatchesETag(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// writeError sends an error in the api.ErrorResponse envelope shared by
+// every framework, its code defaulting to one derived from status.
+func writeError(w http.ResponseWriter, r *http.Request, status int, e api.ErrorResponse) {
+	respondJSON(w, r, status, e.ForStatus(status))
+}
+
+// notFound answers a path no route serves.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotFound, api.ErrorResponse{Message: "not found"})
 }
 
-func getEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// methodNotAllowed answers a routed path requested with a method it doesn't
+// serve.
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusMethodNotAllowed, api.ErrorResponse{Message: "method not allowed"})
+}
+
+// respondJSON sends data as JSON, or as MessagePack when the Accept header
+// prefers it, and answers 406 to an Accept that rules out both. It buffers
+// the encoded body so it goes out with a Content-Length rather than chunked
+// framing; streaming endpoints write directly instead. A body that fails to
+// marshal is answered with a 500 in the error envelope, which always
+// marshals.
+func respondJSON(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	mediaType, ok := negotiate.Select(r.Header.Get("Accept"), negotiate.Formats...)
+	if !ok {
+		status, mediaType, data = http.StatusNotAcceptable, negotiate.JSON, negotiate.NotAcceptable()
+	}
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", mediaType)
+	if err := jsonenc.Write(w, status, negotiate.Encoder(mediaType, encoder), data); err != nil {
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+	}
+}
+
+// respondCompute sends a heavy or medium result like respondJSON, or as its
+// protobuf message msg when the Accept header prefers Protocol Buffers.
+func respondCompute(w http.ResponseWriter, r *http.Request, resp map[string]interface{}, msg *computepb.ComputeResult) {
+	mediaType, _ := negotiate.Select(r.Header.Get("Accept"), negotiate.ComputeFormats...)
+	if mediaType != negotiate.Protobuf {
+		respondJSON(w, r, http.StatusOK, resp)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", mediaType)
+	if err := jsonenc.Write(w, http.StatusOK, negotiate.Proto, msg); err != nil {
+		writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
 	}
-	return fallback
 }
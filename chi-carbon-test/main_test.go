@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"carbon-bench/api"
+	"carbon-bench/apitest"
+	"carbon-bench/compute"
+	"carbon-bench/config"
+	"carbon-bench/jsonenc"
+	"carbon-bench/negotiate"
+	"carbon-bench/padding"
+	"carbon-bench/weather"
+	"github.com/go-chi/chi/v5"
+)
+
+// unreachableDB names a PostgreSQL server on a port nothing listens on, so
+// initDB's ping fails at once.
+var unreachableDB = config.DBConfig{
+	Driver: config.DriverPostgres,
+	URL:    "postgres://postgres@127.0.0.1:1/mydb?sslmode=disable&connect_timeout=2",
+}
+
+func TestMain(m *testing.M) {
+	var err error
+	if encoder, err = jsonenc.New(jsonenc.Stdlib); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// resetDB closes whatever initDB opened and forgets it, so each test starts
+// from a server that never connected.
+func resetDB(t *testing.T) {
+	t.Cleanup(func() {
+		if db != nil {
+			db.Close()
+		}
+		db, dbReady, selectUsersStmt, insertUserStmt = nil, false, nil, nil
+	})
+}
+
+// testRouter builds the router main would from the default configuration,
+// with the handlers' dependencies set up and nothing reaching the network.
+func testRouter(t *testing.T) *chi.Mux {
+	t.Helper()
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.BenchmarkMode = true
+	heavySem = compute.NewSemaphore(cfg.MaxConcurrentHeavy, cfg.HeavyQueueTimeout)
+	filler = padding.New(0)
+	weatherFetcher = weather.NewOpenMeteo(0, time.Second)
+	weatherFetcher.GeocodeURL = "http://127.0.0.1:1/search"
+	weatherFetcher.ForecastURL = "http://127.0.0.1:1/forecast"
+	return newRouter(cfg)
+}
+
+func usersRouter() *chi.Mux {
+	router := chi.NewRouter()
+	router.Get("/api/v1/db/users", getUsers)
+	router.Post("/api/v1/db/users", createUser)
+	return router
+}
+
+func TestUsersUnavailableWithoutDatabase(t *testing.T) {
+	resetDB(t)
+	initDB(unreachableDB)
+	if dbReady {
+		t.Fatal("dbReady after a failed ping")
+	}
+
+	router := usersRouter()
+	for _, tt := range []struct{ method, body string }{
+		{http.MethodGet, ""},
+		{http.MethodPost, `{"name":"Ada","email":"ada@example.com"}`},
+	} {
+		w := apitest.Serve(router, tt.method, "/api/v1/db/users", tt.body)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: status %d, want 503", tt.method, w.Code)
+		}
+		var body struct{ Code, Message string }
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v in %q", tt.method, err, w.Body)
+		}
+		if body.Code != "service_unavailable" || body.Message != "database unavailable" {
+			t.Errorf("%s: body %+v, want service_unavailable/database unavailable", tt.method, body)
+		}
+	}
+}
+
+func TestUsersServedOnceConnected(t *testing.T) {
+	resetDB(t)
+	initDB(config.DBConfig{Driver: config.DriverSQLite})
+	if !dbReady {
+		t.Fatal("dbReady false after opening SQLite")
+	}
+
+	router := usersRouter()
+	if w := apitest.Serve(router, http.MethodPost, "/api/v1/db/users", `{"name":"Ada","email":"ada@example.com"}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST: status %d: %s", w.Code, w.Body)
+	}
+	if w := apitest.Serve(router, http.MethodGet, "/api/v1/db/users", ""); w.Code != http.StatusOK {
+		t.Fatalf("GET: status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestHugeComputeCutOffByRequestTimeout(t *testing.T) {
+	heavySem = compute.NewSemaphore(1, time.Second)
+	filler = padding.New(0)
+	router := chi.NewRouter()
+	router.With(timeoutMiddleware(50*time.Millisecond)).Get("/api/v1/weather/analytics/heavy", analyticsHeavy)
+
+	// Left alone this is 10^10 loop steps, minutes of work
+	start := time.Now()
+	w := apitest.Serve(router, http.MethodGet, "/api/v1/weather/analytics/heavy?size=10000000&iterations=1000", "")
+	elapsed := time.Since(start)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want 503: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "compute timeout") {
+		t.Errorf("body %s, want compute timeout", w.Body)
+	}
+	if elapsed > 5*time.Second {
+		t.Errorf("took %s to give up after a 50ms timeout", elapsed)
+	}
+}
+
+// TestComputeRoutesWaitForHeavySlot holds the only heavy slot and checks
+// that every route running the heavy workload is turned away instead of
+// running beside it.
+func TestComputeRoutesWaitForHeavySlot(t *testing.T) {
+	handler := testRouter(t)
+	heavySem = compute.NewSemaphore(1, 0)
+	release, err := heavySem.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	for _, route := range []struct{ method, target, body string }{
+		{http.MethodGet, "/api/v1/weather/analytics/heavy?size=1000&iterations=1", ""},
+		{http.MethodGet, "/api/v1/weather/analytics/heavy?size=1000&iterations=1&warmup=1", ""},
+		{http.MethodGet, "/api/v1/weather/analytics/medium?size=1000&iterations=1", ""},
+		{http.MethodGet, "/api/v1/weather/analytics/stream?size=1000&iterations=1", ""},
+		{http.MethodPost, "/api/v1/weather/analytics/batch", `[{"size":1000,"iterations":1}]`},
+		{http.MethodGet, "/api/v1/analytics/compare?size=1000&iterations=1", ""},
+	} {
+		if w := apitest.Serve(handler, route.method, route.target, route.body); w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s %s: status %d with no free slot, want 503: %s", route.method, route.target, w.Code, w.Body)
+		}
+	}
+}
+
+func TestBaselineHeadersOnEveryRoute(t *testing.T) {
+	resetDB(t)
+	apitest.CheckBaselineHeaders(t, testRouter(t), "chi")
+}
+
+// TestShutdownOrderOnSIGTERM sends the process a real SIGTERM while a request
+// is in flight and records each shutdown step as it happens.
+func TestShutdownOrderOnSIGTERM(t *testing.T) {
+	resetDB(t)
+	initDB(config.DBConfig{Driver: config.DriverSQLite})
+	jobQueue = compute.NewJobQueue(1, 1)
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}
+	// whileDBOpen records event, flagging it if the DB is already closed
+	whileDBOpen := func(event string) {
+		if err := db.Ping(); err != nil {
+			event += " after db closed"
+		}
+		record(event)
+	}
+
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		whileDBOpen("flush metrics")
+	}))
+	defer gateway.Close()
+
+	started := make(chan struct{})
+	router := chi.NewRouter()
+	router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		record("drain request")
+		w.Write([]byte("done"))
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: router}
+	go srv.Serve(ln)
+	addr := ln.Addr().String()
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	<-started
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	cfg := config.Config{ShutdownTimeout: 5 * time.Second, PushgatewayURL: gateway.URL}
+	done := make(chan struct{})
+	go func() {
+		shutdown(quit, cfg, srv, func(context.Context) error {
+			whileDBOpen("flush traces")
+			return nil
+		}, func() {
+			whileDBOpen("stop stats")
+		})
+		close(done)
+	}()
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			record("stop accepting")
+			break
+		}
+		conn.Close()
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not return")
+	}
+	if err := db.Ping(); err != nil {
+		record("close db")
+	}
+
+	if code := <-status; code != http.StatusOK {
+		t.Errorf("in-flight request: status %d, want 200", code)
+	}
+	want := []string{"stop accepting", "drain request", "flush metrics", "flush traces", "stop stats", "close db"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("shutdown order %q, want %q", events, want)
+	}
+}
+
+func TestPanicRecoveredAsJSON(t *testing.T) {
+	router := testRouter(t)
+	router.Get("/panic", func(w http.ResponseWriter, r *http.Request) { panic("deliberate test panic") })
+
+	w := apitest.Serve(router, http.MethodGet, "/panic", "")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want 500", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type %q, want application/json", ct)
+	}
+	var body struct{ Code, Message string }
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%v in %q", err, w.Body)
+	}
+	if body.Code != "internal_server_error" || body.Message != "internal server error" {
+		t.Errorf("body %+v, want internal_server_error/internal server error", body)
+	}
+}
+
+// streamJSON is respondJSON as it was before it buffered the body: the
+// encoder writes straight to the ResponseWriter.
+func streamJSON(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	mediaType, ok := negotiate.Select(r.Header.Get("Accept"), negotiate.Formats...)
+	if !ok {
+		status, mediaType, data = http.StatusNotAcceptable, negotiate.JSON, negotiate.NotAcceptable()
+	}
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(status)
+	negotiate.Encoder(mediaType, encoder).Marshal(w, data)
+}
+
+// streamedHealth is healthHandler answering through streamJSON.
+func streamedHealth(w http.ResponseWriter, r *http.Request) {
+	uptimeMs := time.Since(startTime).Milliseconds()
+	streamJSON(w, r, http.StatusOK, api.HealthResponse{
+		Status:        "healthy",
+		Framework:     "chi",
+		Gomaxprocs:    runtime.GOMAXPROCS(0),
+		NumCPU:        runtime.NumCPU(),
+		UptimeSeconds: uptimeMs / 1000,
+		UptimeMs:      uptimeMs,
+		Timestamp:     time.Now().UnixMilli(),
+	})
+}
+
+var healthVariants = []struct {
+	name    string
+	handler http.HandlerFunc
+}{
+	{"buffered", healthHandler},
+	{"streamed", streamedHealth},
+}
+
+// TestRespondJSONSentWithContentLength covers a body past net/http's 2 KB
+// chunking buffer too, which streamJSON would send chunked.
+func TestRespondJSONSentWithContentLength(t *testing.T) {
+	large := map[string]string{"padding": strings.Repeat("x", 4096)}
+	for name, handler := range map[string]http.HandlerFunc{
+		"health": healthHandler,
+		"4 KB": func(w http.ResponseWriter, r *http.Request) {
+			respondJSON(w, r, http.StatusOK, large)
+		},
+	} {
+		srv := httptest.NewServer(handler)
+		resp, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		srv.Close()
+		if resp.ContentLength != int64(len(body)) || len(resp.TransferEncoding) != 0 {
+			t.Errorf("%s: Content-Length %d, Transfer-Encoding %q for a %d byte body", name, resp.ContentLength, resp.TransferEncoding, len(body))
+		}
+	}
+}
+
+// BenchmarkHealthHandler measures the handler alone: buffering costs the
+// Content-Length header's two allocations.
+func BenchmarkHealthHandler(b *testing.B) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	for _, v := range healthVariants {
+		b.Run(v.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				v.handler(httptest.NewRecorder(), req)
+			}
+		})
+	}
+}
+
+// BenchmarkHealthRoundTrip measures a keep-alive client against a loopback
+// server. net/http sets Content-Length itself on a body that fits its 2 KB
+// buffer before chunking, so at health's size both variants go out framed
+// alike; bodies past that, such as padded ones, are where streaming turns
+// chunked.
+func BenchmarkHealthRoundTrip(b *testing.B) {
+	for _, v := range healthVariants {
+		b.Run(v.name, func(b *testing.B) {
+			srv := httptest.NewServer(v.handler)
+			defer srv.Close()
+			client := srv.Client()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				resp, err := client.Get(srv.URL)
+				if err != nil {
+					b.Fatal(err)
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+		})
+	}
+}
+
+// endlessUsers replaces the users table with a view that recurses forever
+// without yielding a row, so a query on it runs until it is interrupted.
+const endlessUsers = `
+DROP TABLE users;
+CREATE VIEW users AS
+    WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n)
+    SELECT x AS id, 'name' AS name, 'email' AS email, CURRENT_TIMESTAMP AS created_at
+    FROM n WHERE x < 0;
+`
+
+func TestUsersQueryCancelledWithRequest(t *testing.T) {
+	resetDB(t)
+	initDB(config.DBConfig{Driver: config.DriverSQLite})
+	if _, err := db.Exec(endlessUsers); err != nil {
+		t.Fatal(err)
+	}
+	router := chi.NewRouter()
+	router.With(timeoutMiddleware(100*time.Millisecond)).Get("/api/v1/db/users", getUsers)
+
+	answered := make(chan *httptest.ResponseRecorder, 1)
+	go func() { answered <- apitest.Serve(router, http.MethodGet, "/api/v1/db/users", "") }()
+	var w *httptest.ResponseRecorder
+	select {
+	case w = <-answered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no answer 5s after a 100ms timeout: the query ignored the request context")
+	}
+	if w.Code == http.StatusOK {
+		t.Fatalf("status 200 from a query that never finishes: %s", w.Body)
+	}
+
+	// The pool holds a single connection, so it is only free again if the
+	// query on it was interrupted rather than left running
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var one int
+	if err := db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		t.Errorf("connection still busy after the request timed out: %v", err)
+	}
+}
+
+func TestConditionalCompute(t *testing.T) {
+	apitest.CheckConditional(t, testRouter(t))
+}
+
+func TestUnroutedRequestsAnswerJSON(t *testing.T) {
+	apitest.CheckUnrouted(t, testRouter(t))
+}
+
+func TestMarshalFailureAnswersJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	respondJSON(w, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, map[string]float64{"x": math.NaN()})
+	apitest.CheckError(t, w, http.StatusInternalServerError, "internal_server_error")
+}
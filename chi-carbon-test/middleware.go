@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"carbon-bench/api"
+	"carbon-bench/clientstats"
+	"carbon-bench/config"
+	"carbon-bench/cpuacct"
+	"carbon-bench/faultinject"
+	"carbon-bench/idempotency"
+	"carbon-bench/jwtauth"
+	"carbon-bench/params"
+	"carbon-bench/ratelimit"
+	"carbon-bench/tracing"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/cors"
+)
+
+// clientStatsMiddleware counts every request against its client IP.
+func clientStatsMiddleware(counter *clientstats.Counter, trustProxy bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			counter.Record(clientstats.ClientIP(r, trustProxy))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// inflightMiddleware counts the request as in flight until the rest of the
+// chain has returned.
+func inflightMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Start()
+		defer requests.Done()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// headersMiddleware sets the baseline response headers shared by every
+// framework.
+func headersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.SetBaselineHeaders(w.Header(), "chi")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recoverMiddleware turns a handler panic into a logged stack trace and the
+// JSON 500 every framework answers with, where middleware.Recoverer would
+// write plain text.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// Deliberate aborts must keep propagating to net/http
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			log.Printf("panic: %v\n%s", rec, debug.Stack())
+			writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: "internal server error"})
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// timeoutMiddleware bounds the request context so long-running compute and
+// database queries observe the deadline and bail out early. Handlers answer
+// the timeout themselves; unlike middleware.Timeout this writes nothing once
+// they return, so a 503 isn't followed by a superfluous 504.
+func timeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// rateLimitMiddleware applies a per-client-IP token bucket and answers 429
+// with Retry-After once a client exceeds its rate.
+func rateLimitMiddleware(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ok, retryAfter := limiter.Allow(ratelimit.RemoteIP(r)); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(retryAfter)))
+				writeError(w, r, http.StatusTooManyRequests, api.ErrorResponse{Message: "rate limit exceeded"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authMiddleware answers 401 to requests without a valid bearer token,
+// except those jwtauth.Exempt lets through.
+func authMiddleware(verifier *jwtauth.Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if jwtauth.Exempt(unprefixed(r.URL.Path)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if err := verifier.Check(r.Header.Get("Authorization")); err != nil {
+				w.Header().Set("WWW-Authenticate", jwtauth.Challenge)
+				writeError(w, r, http.StatusUnauthorized, api.ErrorResponse{Message: err.Error()})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// faultMiddleware delays and fails requests as the injector decides. The
+// delay ends early if the client goes away.
+func faultMiddleware(injector *faultinject.Injector) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			delay, fail := injector.Next()
+			if delay > 0 {
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-r.Context().Done():
+					timer.Stop()
+					return
+				}
+			}
+			if fail {
+				writeError(w, r, http.StatusInternalServerError, api.ErrorResponse{Message: "injected fault"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// corsMaxAge is how long browsers may cache a preflight response.
+const corsMaxAge = 10 * time.Minute
+
+// corsMiddleware answers preflight requests for every route, including ones
+// with no OPTIONS handler, and adds the CORS headers to allowed origins.
+func corsMiddleware(c config.CORSConfig) func(http.Handler) http.Handler {
+	return cors.Handler(cors.Options{
+		AllowedOrigins: c.AllowedOrigins,
+		AllowedMethods: c.AllowedMethods,
+		AllowedHeaders: c.AllowedHeaders,
+		MaxAge:         int(corsMaxAge.Seconds()),
+	})
+}
+
+// cpuTimeMiddleware pins the request to its OS thread and reports the thread
+// CPU time consumed before the response header is sent as X-CPU-Ms. This
+// separates CPU cost from wall time, which includes sleeps and I/O waits.
+// The thread stays pinned through those waits too, so under load there is
+// an OS thread per in-flight request; ENABLE_CPU_ACCOUNTING=true opts in.
+func cpuTimeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		next.ServeHTTP(cpuacct.NewResponseWriter(w), r)
+	})
+}
+
+// goroutineMiddleware logs requests that finish with a different goroutine
+// count than they started with, to catch handlers or middleware that leak
+// goroutines. The count is process-wide, so concurrent requests show up as
+// noise; hunt leaks at concurrency 1.
+func goroutineMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before := runtime.NumGoroutine()
+		next.ServeHTTP(w, r)
+		if delta := runtime.NumGoroutine() - before; delta != 0 {
+			log.Printf("⚠️  Goroutine delta %+d after %s %s", delta, r.Method, chi.RouteContext(r.Context()).RoutePattern())
+		}
+	})
+}
+
+// tracingMiddleware wraps each request in a server span, returns its trace ID
+// in X-Trace-Id, and names the span after the matched route once chi has
+// routed the request.
+func tracingMiddleware(next http.Handler) http.Handler {
+	traced := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracing.SetTraceIDHeader(w, r.Context())
+		next.ServeHTTP(w, r)
+
+		if pattern := chi.RouteContext(r.Context()).RoutePattern(); pattern != "" {
+			tracing.SetSpanName(r.Context(), r.Method+" "+pattern)
+		}
+	})
+	return tracing.Middleware(traced, "chi-carbon-test")
+}
+
+// idempotencyMiddleware replays the recorded response to a repeat of a
+// request whose Idempotency-Key was already used with the same body, instead
+// of running the handler again, and answers 409 when the key comes back with
+// a different body. Requests without the header, or any request when store
+// is nil, pass straight through.
+func idempotencyMiddleware(store *idempotency.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotency.Header)
+			if key == "" || store == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > idempotency.MaxKeyLength {
+				writeError(w, r, http.StatusBadRequest, api.ErrorResponse{
+					Message: fmt.Sprintf("%s exceeds %d characters", idempotency.Header, idempotency.MaxKeyLength),
+				})
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				respondBodyError(w, r, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// Scope keys to the route so one key can't replay another endpoint
+			key = chi.RouteContext(r.Context()).RoutePattern() + " " + key
+			resp, owned, err := store.Claim(r.Context(), key, body)
+			switch {
+			case errors.Is(err, idempotency.ErrMismatch):
+				writeError(w, r, http.StatusConflict, api.ErrorResponse{Message: err.Error()})
+				return
+			case err != nil:
+				// The client went away waiting for the original request
+				return
+			case resp != nil:
+				w.Header().Set("Content-Type", resp.ContentType)
+				w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+				w.Header().Set(idempotency.ReplayedHeader, "true")
+				w.WriteHeader(resp.Status)
+				w.Write(resp.Body)
+				return
+			case !owned:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			completed := false
+			defer func() {
+				// A panicking handler records nothing, so a retry runs again
+				if !completed {
+					store.Release(key)
+				}
+			}()
+
+			rec := &idempotencyWriter{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			store.Complete(key, &idempotency.Response{
+				Status:      rec.status,
+				ContentType: rec.Header().Get("Content-Type"),
+				Body:        rec.body.Bytes(),
+			})
+			completed = true
+		})
+	}
+}
+
+// idempotencyWriter keeps the status and a copy of the response body for
+// the idempotency store.
+type idempotencyWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *idempotencyWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *idempotencyWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// strictParamsMiddleware answers 400 with every offending parameter when a
+// request carries query parameters its endpoint doesn't read or values that
+// don't parse, where the handlers would fall back to their defaults.
+func strictParamsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if problems := params.CheckQuery(unprefixed(r.URL.Path), r.URL.Query()); len(problems) > 0 {
+			writeError(w, r, http.StatusBadRequest, api.ErrorResponse{Message: "invalid query parameters", Details: map[string]interface{}{"params": problems}})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unprefixed strips API_PREFIX from a request path, giving the path the
+// route was declared with.
+func unprefixed(path string) string {
+	path = strings.TrimPrefix(path, apiPrefix)
+	if path == "" {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,160 @@
+// Package clientstats counts requests per client IP, to check that a load
+// generator spreads its traffic across the client machines it is meant to.
+package clientstats
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"carbon-bench/ratelimit"
+)
+
+// shardCount splits the table so concurrent requests from different
+// clients rarely contend for the same lock.
+const shardCount = 64
+
+// maxClientsPerShard bounds memory under spoofed or very wide traffic.
+// Requests from clients that arrive once a shard is full are counted as
+// untracked instead.
+const maxClientsPerShard = 1024
+
+// MaxTop caps the clients one Top call returns.
+const MaxTop = 1000
+
+// Client is one client's request count.
+type Client struct {
+	IP       string    `json:"ip"`
+	Requests int64     `json:"requests"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Summary is the result of Top.
+type Summary struct {
+	TotalClients  int      `json:"total_clients"`
+	TotalRequests int64    `json:"total_requests"`
+	Untracked     int64    `json:"untracked_requests"`
+	Clients       []Client `json:"clients"`
+}
+
+type shard struct {
+	mu        sync.Mutex
+	clients   map[string]*Client
+	untracked int64
+}
+
+// Counter is a sharded request count per client IP. A nil *Counter counts
+// nothing, so callers can skip the middleware entirely.
+type Counter struct {
+	shards [shardCount]shard
+}
+
+// New returns an empty Counter.
+func New() *Counter {
+	c := &Counter{}
+	for i := range c.shards {
+		c.shards[i].clients = make(map[string]*Client)
+	}
+	return c
+}
+
+// Record counts one request from ip.
+func (c *Counter) Record(ip string) {
+	if c == nil {
+		return
+	}
+	now := time.Now()
+	s := &c.shards[shardOf(ip)]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, ok := s.clients[ip]
+	if !ok {
+		if len(s.clients) >= maxClientsPerShard {
+			s.untracked++
+			return
+		}
+		client = &Client{IP: ip}
+		s.clients[ip] = client
+	}
+	client.Requests++
+	client.LastSeen = now
+}
+
+// Top returns the n clients with the most requests, most first, along with
+// totals over every client.
+func (c *Counter) Top(n int) Summary {
+	sum := Summary{Clients: []Client{}}
+	if c == nil {
+		return sum
+	}
+
+	var all []Client
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		for _, client := range s.clients {
+			all = append(all, *client)
+			sum.TotalRequests += client.Requests
+		}
+		sum.Untracked += s.untracked
+		s.mu.Unlock()
+	}
+	sum.TotalRequests += sum.Untracked
+	sum.TotalClients = len(all)
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Requests != all[j].Requests {
+			return all[i].Requests > all[j].Requests
+		}
+		return all[i].IP < all[j].IP
+	})
+	if len(all) > n {
+		all = all[:n]
+	}
+	if len(all) > 0 {
+		sum.Clients = all
+	}
+	return sum
+}
+
+// shardOf hashes ip with FNV-1a.
+func shardOf(ip string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(ip); i++ {
+		h ^= uint32(ip[i])
+		h *= 16777619
+	}
+	return int(h % shardCount)
+}
+
+// ClientIP returns the IP a request came from: the first address in
+// X-Forwarded-For when trustProxy is set and the header holds one, the
+// remote address otherwise. Only enable trustProxy behind a proxy that
+// sets the header, since clients can send any value.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if ip := forwardedFor(r.Header.Get("X-Forwarded-For")); ip != "" {
+			return ip
+		}
+	}
+	return ratelimit.RemoteIP(r)
+}
+
+// forwardedFor returns the first address of an X-Forwarded-For value, the
+// original client's, or "" when it isn't an IP.
+func forwardedFor(header string) string {
+	if header == "" {
+		return ""
+	}
+	first, _, _ := strings.Cut(header, ",")
+	first = strings.TrimSpace(first)
+	if net.ParseIP(first) == nil {
+		return ""
+	}
+	return first
+}
@@ -0,0 +1,288 @@
+// Command benchrunner drives the framework servers with concurrent load and
+// reports latency percentiles, throughput and error rate per endpoint, as a
+// table on stdout and a JSON report on disk.
+//
+// Usage:
+//
+//	go run ./cmd/benchrunner [flags] URL...
+//
+// For example, against the Gin and Chi containers:
+//
+//	go run ./cmd/benchrunner -n 1000 -c 50 http://localhost:8004 http://localhost:8005
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// endpoints maps the names accepted by -endpoints to request paths.
+var endpoints = map[string]string{
+	"health":   "/api/v1/health",
+	"light":    "/api/v1/weather/analytics/light",
+	"medium":   "/api/v1/weather/analytics/medium",
+	"heavy":    "/api/v1/weather/analytics/heavy",
+	"external": "/api/v1/weather/external",
+	"fetch":    "/api/v1/weather/fetch",
+	"users":    "/api/v1/db/users",
+}
+
+// Percentiles summarizes a latency distribution in milliseconds.
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// Result is the outcome of one endpoint on one target.
+type Result struct {
+	Target    string  `json:"target"`
+	Framework string  `json:"framework"`
+	Endpoint  string  `json:"endpoint"`
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	DurationS float64 `json:"duration_s"`
+	RPS       float64 `json:"throughput_rps"`
+
+	// LatencyMs is measured by the client; ServerElapsedMs is the
+	// elapsed_ms the server reported, when the endpoint returns one
+	LatencyMs       Percentiles  `json:"latency_ms"`
+	ServerElapsedMs *Percentiles `json:"server_elapsed_ms,omitempty"`
+}
+
+// Report is the JSON document written at the end of a run.
+type Report struct {
+	StartedAt   time.Time `json:"started_at"`
+	Requests    int       `json:"requests_per_endpoint"`
+	Concurrency int       `json:"concurrency"`
+	Results     []Result  `json:"results"`
+}
+
+// sample is what one request contributes to a Result.
+type sample struct {
+	latency   time.Duration
+	framework string
+	elapsedMs *float64
+	err       error
+}
+
+func main() {
+	requests := flag.Int("n", 100, "requests per endpoint and target")
+	concurrency := flag.Int("c", 10, "concurrent requests")
+	endpointList := flag.String("endpoints", "light,medium,heavy", "comma-separated endpoints: "+endpointNames())
+	timeout := flag.Duration("timeout", 30*time.Second, "per-request timeout")
+	output := flag.String("o", "", `JSON report path ("-" for stdout; default test_results/benchrunner_<timestamp>.json)`)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: benchrunner [flags] URL...\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	targets := flag.Args()
+	if len(targets) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *requests < 1 || *concurrency < 1 {
+		log.Fatal("-n and -c must be at least 1")
+	}
+
+	var names []string
+	for _, name := range strings.Split(*endpointList, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := endpoints[name]; !ok {
+			log.Fatalf("unknown endpoint %q (want one of %s)", name, endpointNames())
+		}
+		names = append(names, name)
+	}
+
+	client := &http.Client{
+		Timeout: *timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        *concurrency,
+			MaxIdleConnsPerHost: *concurrency,
+		},
+	}
+
+	report := Report{StartedAt: time.Now(), Requests: *requests, Concurrency: *concurrency}
+	for _, target := range targets {
+		target = strings.TrimRight(target, "/")
+		for _, name := range names {
+			log.Printf("▶ %s %s (%d requests, concurrency %d)", target, name, *requests, *concurrency)
+			report.Results = append(report.Results, run(client, target, name, *requests, *concurrency))
+		}
+	}
+
+	printTable(os.Stdout, report.Results)
+	if err := writeReport(*output, report); err != nil {
+		log.Fatalf("Writing report: %v", err)
+	}
+}
+
+// run fires requests at one endpoint with at most concurrency in flight and
+// aggregates the samples.
+func run(client *http.Client, target, name string, requests, concurrency int) Result {
+	url := target + endpoints[name]
+	samples := make([]sample, requests)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				samples[i] = fetch(client, url)
+			}
+		}()
+	}
+
+	start := time.Now()
+	for i := range samples {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	duration := time.Since(start)
+
+	result := Result{
+		Target:    target,
+		Endpoint:  name,
+		Requests:  requests,
+		DurationS: duration.Seconds(),
+		RPS:       float64(requests) / duration.Seconds(),
+	}
+
+	var latencies, elapsed []float64
+	for _, s := range samples {
+		if s.err != nil {
+			result.Errors++
+			continue
+		}
+		if result.Framework == "" {
+			result.Framework = s.framework
+		}
+		latencies = append(latencies, float64(s.latency.Microseconds())/1000)
+		if s.elapsedMs != nil {
+			elapsed = append(elapsed, *s.elapsedMs)
+		}
+	}
+	result.ErrorRate = float64(result.Errors) / float64(requests)
+	result.LatencyMs = percentiles(latencies)
+	if len(elapsed) > 0 {
+		p := percentiles(elapsed)
+		result.ServerElapsedMs = &p
+	}
+	return result
+}
+
+// fetch performs one request. Transport failures, non-2xx statuses and
+// bodies that aren't JSON all count as errors.
+func fetch(client *http.Client, url string) sample {
+	start := time.Now()
+	resp, err := client.Get(url)
+	if err != nil {
+		return sample{err: err}
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Framework string   `json:"framework"`
+		ElapsedMs *float64 `json:"elapsed_ms"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	// Drain so the connection is reused
+	io.Copy(io.Discard, resp.Body)
+	latency := time.Since(start)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return sample{err: fmt.Errorf("status %d", resp.StatusCode)}
+	}
+	if err != nil {
+		return sample{err: err}
+	}
+	return sample{latency: latency, framework: body.Framework, elapsedMs: body.ElapsedMs}
+}
+
+// percentiles uses the nearest-rank method. It sorts values in place.
+func percentiles(values []float64) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+	sort.Float64s(values)
+	rank := func(p float64) float64 {
+		i := int(p*float64(len(values))+0.5) - 1
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(values) {
+			i = len(values) - 1
+		}
+		return values[i]
+	}
+	return Percentiles{P50: rank(0.50), P90: rank(0.90), P99: rank(0.99)}
+}
+
+func printTable(w io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "framework\tendpoint\trequests\terrors\terror %\treq/s\tp50 ms\tp90 ms\tp99 ms\tserver p50 ms\t")
+	for _, r := range results {
+		framework := r.Framework
+		if framework == "" {
+			framework = r.Target
+		}
+		serverP50 := "-"
+		if r.ServerElapsedMs != nil {
+			serverP50 = fmt.Sprintf("%.1f", r.ServerElapsedMs.P50)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.1f\t%.1f\t%.2f\t%.2f\t%.2f\t%s\t\n",
+			framework, r.Endpoint, r.Requests, r.Errors, r.ErrorRate*100, r.RPS,
+			r.LatencyMs.P50, r.LatencyMs.P90, r.LatencyMs.P99, serverP50)
+	}
+	tw.Flush()
+}
+
+func writeReport(path string, report Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if path == "" {
+		path = filepath.Join("test_results", "benchrunner_"+report.StartedAt.Format("20060102_150405")+".json")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	log.Printf("✓ Report saved to %s", path)
+	return nil
+}
+
+func endpointNames() string {
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,144 @@
+// Command seed fills the users table with a fixed set of synthetic users, so
+// getUsers is benchmarked against a table of known size and content on every
+// run and machine.
+//
+// It connects with the same DB_* environment as the framework apps, creates
+// the table when it is missing, and inserts every user in one transaction
+// using multi-row INSERTs. User i is always named and addressed the same way,
+// so two seeds of the same size produce identical tables.
+//
+// Usage:
+//
+//	go run ./cmd/seed [flags]
+//
+// For example, to reset the table to exactly 10000 users:
+//
+//	go run ./cmd/seed -n 10000 -truncate
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"carbon-bench/config"
+	"carbon-bench/store"
+)
+
+// firstNames and lastNames are combined by index to give the users
+// realistic-looking names of varying length.
+var (
+	firstNames = []string{
+		"Ada", "Alan", "Barbara", "Charles", "Donald", "Edsger", "Frances", "Grace",
+		"Hedy", "John", "Katherine", "Ken", "Leslie", "Margaret", "Niklaus", "Radia",
+	}
+	lastNames = []string{
+		"Lovelace", "Turing", "Liskov", "Shannon", "Knuth", "Dijkstra", "Allen", "Hopper",
+		"Lamarr", "McCarthy", "Johnson", "Thompson", "Lamport", "Hamilton", "Wirth", "Perlman",
+	}
+)
+
+func main() {
+	count := flag.Int("n", 10000, "users to insert")
+	batch := flag.Int("batch", 500, fmt.Sprintf("users per INSERT statement (at most %d)", store.MaxBulkUsers))
+	truncate := flag.Bool("truncate", false, "empty the users table and restart its ids first")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: seed [flags]\n\nConnects using the DB_* environment variables.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *count < 0 {
+		log.Fatal("-n must not be negative")
+	}
+	if *batch < 1 || *batch > store.MaxBulkUsers {
+		log.Fatalf("-batch must be between 1 and %d", store.MaxBulkUsers)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.DB.Driver == config.DriverSQLite {
+		log.Fatal("DB_DRIVER=sqlite is an in-memory database private to each app; seed PostgreSQL or MySQL instead")
+	}
+
+	dialect := store.DialectFor(cfg.DB.Driver)
+	db, err := sql.Open(dialect.Name, cfg.DB.DSN())
+	if err != nil {
+		log.Fatalf("Opening database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := db.PingContext(ctx); err != nil {
+		log.Fatalf("Connecting to database: %v", err)
+	}
+	if created, err := dialect.MigrateUsers(ctx, db); err != nil {
+		log.Fatalf("Migration: %v", err)
+	} else if created {
+		log.Println("✓ Migration: created users table")
+	}
+
+	start := time.Now()
+	if err := seed(ctx, db, dialect, *count, *batch, *truncate); err != nil {
+		if _, ok := store.ConstraintViolation(err, nil); ok {
+			log.Fatalf("Seeding: %v (the table already holds seeded users; rerun with -truncate)", err)
+		}
+		log.Fatalf("Seeding: %v", err)
+	}
+	log.Printf("✓ Seeded %d users in batches of %d (%s)", *count, *batch, time.Since(start).Round(time.Millisecond))
+
+	var total int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+		log.Printf("⚠️  Counting users: %v", err)
+		return
+	}
+	log.Printf("✓ users table now holds %d rows", total)
+}
+
+// seed inserts count users in one transaction, batch at a time, after
+// emptying the table when truncate is set.
+func seed(ctx context.Context, db *sql.DB, d store.Dialect, count, batch int, truncate bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if truncate {
+		if _, err := tx.ExecContext(ctx, d.TruncateUsers); err != nil {
+			return fmt.Errorf("truncating: %w", err)
+		}
+		log.Println("✓ Truncated users table")
+	}
+
+	users := make([]store.NewUser, 0, batch)
+	for first := 0; first < count; first += batch {
+		users = users[:0]
+		for i := first; i < first+batch && i < count; i++ {
+			users = append(users, user(i))
+		}
+
+		query, args := d.BulkInsertUsers(users)
+		// Exec discards the RETURNING rows on PostgreSQL
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("inserting users %d-%d: %w", first+1, first+len(users), err)
+		}
+	}
+	return tx.Commit()
+}
+
+// user returns the i-th synthetic user, numbered from 0. Emails are unique
+// by construction.
+func user(i int) store.NewUser {
+	first := firstNames[i%len(firstNames)]
+	last := lastNames[(i/len(firstNames))%len(lastNames)]
+	return store.NewUser{
+		Name:  fmt.Sprintf("%s %s %d", first, last, i+1),
+		Email: fmt.Sprintf("seed.user%07d@example.com", i+1),
+	}
+}
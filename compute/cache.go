@@ -0,0 +1,117 @@
+package compute
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache is a size-bounded LRU of compute results that expire after a fixed
+// TTL. It is keyed by the full Params, so jobs differing in any of kernel,
+// size, iterations, goroutines or seed never share an entry.
+//
+// A nil *Cache is valid and caches nothing, so handlers can call Compute
+// unconditionally.
+type Cache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[Params]*list.Element
+}
+
+type cacheEntry struct {
+	params  Params
+	result  Result
+	expires time.Time
+}
+
+// NewCache returns a cache holding at most size results for ttl each.
+func NewCache(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[Params]*list.Element, size),
+	}
+}
+
+// Compute returns the cached result for p when there is a live one, and
+// otherwise runs HeavyCompute under a slot of sem and caches its result. hit
+// reports which happened. A hit did no work and never waits for a slot, so
+// its ElapsedMs and carbon estimates are zero and it carries no Allocations;
+// ResultHash and TotalSum are those of the original run.
+func (c *Cache) Compute(ctx context.Context, p Params, sem *Semaphore) (result Result, hit bool, err error) {
+	if result, ok := c.get(p); ok {
+		result.ElapsedMs = 0
+		result.EstimatedJoules = 0
+		result.EstimatedCO2Grams = 0
+		result.Allocations = nil
+		return result, true, nil
+	}
+
+	release, err := sem.Acquire(ctx)
+	if err != nil {
+		return Result{}, false, err
+	}
+	result, err = HeavyCompute(ctx, p)
+	release()
+	if err != nil {
+		return Result{}, false, err
+	}
+	if c != nil {
+		c.add(p, result)
+	}
+	return result, false, nil
+}
+
+func (c *Cache) get(p Params) (Result, bool) {
+	if c == nil {
+		return Result{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[p]
+	if !ok {
+		return Result{}, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, p)
+		return Result{}, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.result, true
+}
+
+func (c *Cache) add(p Params, result Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+	if elem, ok := c.entries[p]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.result, entry.expires = result, expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[p] = c.order.PushFront(&cacheEntry{params: p, result: result, expires: expires})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).params)
+	}
+}
+
+// CacheStatus is the value of the "cache" response field.
+func CacheStatus(hit bool) string {
+	if hit {
+		return "hit"
+	}
+	return "miss"
+}
@@ -0,0 +1,61 @@
+package compute
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// busySemaphore returns a one-slot semaphore whose slot is already taken and
+// that rejects at once, so any job needing a slot fails with ErrBusy.
+func busySemaphore(t *testing.T) *Semaphore {
+	t.Helper()
+	sem := NewSemaphore(1, 0)
+	release, err := sem.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(release)
+	return sem
+}
+
+func TestCacheHitNeedsNoSlot(t *testing.T) {
+	cache := NewCache(4, time.Minute)
+	p := Params{Kernel: KernelLoop, Size: 1000, Iterations: 1}
+	if _, hit, err := cache.Compute(context.Background(), p, nil); err != nil || hit {
+		t.Fatalf("first Compute: hit %v, err %v", hit, err)
+	}
+
+	sem := busySemaphore(t)
+	if _, hit, err := cache.Compute(context.Background(), p, sem); err != nil || !hit {
+		t.Errorf("cached Compute with no free slot: hit %v, err %v; want a hit", hit, err)
+	}
+	miss := Params{Kernel: KernelLoop, Size: 2000, Iterations: 1}
+	if _, _, err := cache.Compute(context.Background(), miss, sem); !errors.Is(err, ErrBusy) {
+		t.Errorf("uncached Compute with no free slot: err %v, want ErrBusy", err)
+	}
+}
+
+func TestCacheHitReportsNoWork(t *testing.T) {
+	cache := NewCache(4, time.Minute)
+	p := Params{Kernel: KernelAlloc, Size: 4096, Iterations: 2}
+	miss, _, err := cache.Compute(context.Background(), p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if miss.Allocations == nil {
+		t.Fatal("alloc kernel run reported no allocations")
+	}
+
+	hit, ok, err := cache.Compute(context.Background(), p, nil)
+	if err != nil || !ok {
+		t.Fatalf("second Compute: hit %v, err %v", ok, err)
+	}
+	if hit.Allocations != nil || hit.ElapsedMs != 0 || hit.EstimatedJoules != 0 || hit.EstimatedCO2Grams != 0 {
+		t.Errorf("hit reports work: allocations %+v, %dms, %gJ, %gg CO2", hit.Allocations, hit.ElapsedMs, hit.EstimatedJoules, hit.EstimatedCO2Grams)
+	}
+	if hit.ResultHash != miss.ResultHash || hit.TotalSum != miss.TotalSum {
+		t.Errorf("hit result %s/%d, want the original %s/%d", hit.ResultHash, hit.TotalSum, miss.ResultHash, miss.TotalSum)
+	}
+}
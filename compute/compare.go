@@ -0,0 +1,93 @@
+package compute
+
+import (
+	"context"
+	"fmt"
+)
+
+// Kernels lists the CPU-bound kernels, in the order Compare runs them.
+// KernelAlloc measures the allocator and collector instead and isn't
+// compared.
+var Kernels = []Kernel{KernelLoop, KernelMatmul}
+
+// MaxCompareOps bounds the element operations of one Compare across all
+// kernels, about two seconds of single-core work.
+const MaxCompareOps = 2_000_000_000
+
+// KernelTiming is one kernel's run within a Comparison. Ops counts its
+// element operations, so NsPerOp shows whether a kernel costs what its
+// complexity predicts; a near-zero NsPerOp suggests the compiler dropped
+// the work. Ratio is ElapsedMs relative to the first kernel's.
+type KernelTiming struct {
+	Kernel     Kernel  `json:"kernel"`
+	ResultHash string  `json:"result_hash"`
+	Ops        int64   `json:"ops"`
+	ElapsedMs  float64 `json:"elapsed_ms"`
+	NsPerOp    float64 `json:"ns_per_op"`
+	Ratio      float64 `json:"ratio"`
+}
+
+// Comparison is the outcome of Compare.
+type Comparison struct {
+	Size       int            `json:"size"`
+	Iterations int            `json:"iterations"`
+	TotalOps   int64          `json:"total_ops"`
+	Kernels    []KernelTiming `json:"kernels"`
+}
+
+// kernelOps returns the element operations kernel k performs for size and
+// iterations: one per element for the loop, size³ multiply-adds per
+// iteration for matmul.
+func kernelOps(k Kernel, size, iterations int) int64 {
+	n := int64(size)
+	if k == KernelMatmul {
+		return n * n * n * int64(iterations)
+	}
+	return n * int64(iterations)
+}
+
+// CheckCompare reports an error when size and iterations would take a
+// Compare past MaxCompareOps. size must already be within every kernel's
+// MaxSize.
+func CheckCompare(size, iterations int) error {
+	var total int64
+	for _, k := range Kernels {
+		total += kernelOps(k, size, iterations)
+	}
+	if total > MaxCompareOps {
+		return fmt.Errorf("size %d and iterations %d need %d operations, more than %d", size, iterations, total, MaxCompareOps)
+	}
+	return nil
+}
+
+// Compare runs every kernel in turn on one goroutine with the same size and
+// iterations, so their timings are comparable. The runs aren't recorded in
+// HeavyStats. It returns ctx.Err() if the context ends first.
+func Compare(ctx context.Context, size, iterations int) (Comparison, error) {
+	c := Comparison{Size: size, Iterations: iterations}
+	for _, k := range Kernels {
+		p := Params{Kernel: k, Size: size, Iterations: iterations, Goroutines: 1}
+		result, elapsed, err := heavyCompute(ctx, p, nil)
+		if err != nil {
+			return Comparison{}, err
+		}
+
+		t := KernelTiming{
+			Kernel:     k,
+			ResultHash: result.ResultHash,
+			Ops:        kernelOps(k, size, iterations),
+			ElapsedMs:  float64(elapsed.Nanoseconds()) / 1e6,
+		}
+		t.NsPerOp = float64(elapsed.Nanoseconds()) / float64(t.Ops)
+		c.TotalOps += t.Ops
+		c.Kernels = append(c.Kernels, t)
+	}
+
+	base := c.Kernels[0].ElapsedMs
+	for i := range c.Kernels {
+		if base > 0 {
+			c.Kernels[i].Ratio = c.Kernels[i].ElapsedMs / base
+		}
+	}
+	return c, nil
+}
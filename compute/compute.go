@@ -0,0 +1,579 @@
+// Package compute implements the CPU-bound analytics workloads shared by all
+// framework apps, so every implementation runs byte-for-byte identical work.
+package compute
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"carbon-bench/carbon"
+	"carbon-bench/params"
+)
+
+// Kernel selects the workload run by HeavyCompute.
+type Kernel string
+
+const (
+	// KernelLoop sums x*x mod (size+1) over a slice of size ints, once per
+	// iteration: O(size × iterations) time, O(size) memory. It is cheap per
+	// element and is kept as the default for backward compatibility.
+	KernelLoop Kernel = "loop"
+
+	// KernelMatmul multiplies two dense size×size matrices once per
+	// iteration: O(size³ × iterations) time, O(size²) memory. Unlike the loop
+	// kernel it is genuinely CPU- and cache-bound, so sizes in the low
+	// hundreds already take noticeable time.
+	KernelMatmul Kernel = "matmul"
+
+	// KernelAlloc allocates AllocBytesPerIteration per iteration as []byte
+	// buffers of size bytes each, touches every cache line of each buffer
+	// and drops it: allocator- and GC-bound rather than CPU-bound. Its
+	// Result carries the Allocations it caused.
+	KernelAlloc Kernel = "alloc"
+)
+
+// Upper bounds accepted by the analytics endpoints. They keep a single
+// request from allocating gigabytes or pinning a core for minutes.
+const (
+	MaxLoopSize   = 10_000_000
+	MaxMatmulSize = 2_000
+	MaxAllocSize  = AllocBytesPerIteration
+	MaxIterations = 1_000
+	MaxGoroutines = 64
+	MaxSeed       = math.MaxInt32
+)
+
+// DefaultMatmulSize is the matrix dimension KernelMatmul runs at when a
+// request gives no size. The endpoints' loop defaults of 2000 and 5000 would
+// run past the request timeout or exceed MaxMatmulSize.
+const DefaultMatmulSize = 200
+
+// DefaultSize returns the size a request naming no size runs the kernel at:
+// DefaultMatmulSize for KernelMatmul and the endpoint's own loopDefault for
+// the others.
+func (k Kernel) DefaultSize(loopDefault int) int {
+	if k == KernelMatmul {
+		return DefaultMatmulSize
+	}
+	return loopDefault
+}
+
+// MaxSize returns the largest size accepted for the kernel.
+func (k Kernel) MaxSize() int {
+	switch k {
+	case KernelMatmul:
+		return MaxMatmulSize
+	case KernelAlloc:
+		return MaxAllocSize
+	}
+	return MaxLoopSize
+}
+
+// ParseKernel maps a query/body value to a Kernel. An empty string selects
+// KernelLoop.
+func ParseKernel(s string) (Kernel, error) {
+	switch Kernel(s) {
+	case "", KernelLoop:
+		return KernelLoop, nil
+	case KernelMatmul:
+		return KernelMatmul, nil
+	case KernelAlloc:
+		return KernelAlloc, nil
+	}
+	return "", fmt.Errorf("unknown kernel %q", s)
+}
+
+// Params describes a single compute job. Goroutines splits each iteration
+// across that many workers; it changes the wall time but never the result.
+//
+// Seed selects the kernel's input data. Seed 0 keeps the original fixed
+// inputs; any other seed draws them from a PRNG seeded with it. Identical
+// (Kernel, Size, Iterations, Seed) always yield an identical ResultHash,
+// across runs and processes and regardless of Goroutines.
+type Params struct {
+	Kernel     Kernel `json:"kernel"`
+	Size       int    `json:"size"`
+	Iterations int    `json:"iterations"`
+	Goroutines int    `json:"goroutines,omitempty"`
+	Seed       int    `json:"seed,omitempty"`
+}
+
+// Validate checks the job against the same bounds enforced on query
+// parameters, filling in the default kernel and a single goroutine when none
+// was given.
+func (p *Params) Validate() error {
+	kernel, err := ParseKernel(string(p.Kernel))
+	if err != nil {
+		return err
+	}
+	p.Kernel = kernel
+
+	if p.Size < 1 || p.Size > kernel.MaxSize() {
+		return &params.RangeError{Param: "size", Value: p.Size, Min: 1, Max: kernel.MaxSize()}
+	}
+	if p.Iterations < 1 || p.Iterations > MaxIterations {
+		return &params.RangeError{Param: "iterations", Value: p.Iterations, Min: 1, Max: MaxIterations}
+	}
+	if p.Goroutines == 0 {
+		p.Goroutines = 1
+	}
+	if p.Goroutines < 1 || p.Goroutines > MaxGoroutines {
+		return &params.RangeError{Param: "goroutines", Value: p.Goroutines, Min: 1, Max: MaxGoroutines}
+	}
+	if p.Seed < 0 || p.Seed > MaxSeed {
+		return &params.RangeError{Param: "seed", Value: p.Seed, Min: 0, Max: MaxSeed}
+	}
+	return nil
+}
+
+// DecodeParams reads a JSON job from r, keeping the fields of defaults that
+// the body omits, and validates it like the query-parameter path. An omitted
+// size is the kernel's DefaultSize, with defaults.Size as the loop default.
+func DecodeParams(r io.Reader, defaults Params) (Params, error) {
+	// Size shadows the embedded Params.Size, so an omitted size stays nil
+	body := struct {
+		Params
+		Size *int `json:"size"`
+	}{Params: defaults}
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return Params{}, err
+	}
+	p := body.Params
+	if body.Size != nil {
+		p.Size = *body.Size
+	} else if kernel, err := ParseKernel(string(p.Kernel)); err == nil {
+		p.Size = kernel.DefaultSize(defaults.Size)
+	}
+	if err := p.Validate(); err != nil {
+		return Params{}, err
+	}
+	return p, nil
+}
+
+// Result is the outcome of a compute job as returned by the analytics
+// endpoints. For KernelMatmul, MatrixSize is the square matrix dimension;
+// for KernelLoop it is the slice length and for KernelAlloc the buffer size.
+// Allocations is set for KernelAlloc only.
+type Result struct {
+	ResultHash string `json:"result_hash"`
+	TotalSum   int64  `json:"total_sum"`
+	MatrixSize int    `json:"matrix_size"`
+	Iterations int    `json:"iterations"`
+	Kernel     Kernel `json:"kernel"`
+	ElapsedMs  int64  `json:"elapsed_ms"`
+
+	EstimatedJoules   float64 `json:"estimated_joules"`
+	EstimatedCO2Grams float64 `json:"estimated_co2_grams"`
+
+	Allocations *AllocStats `json:"allocations,omitempty"`
+}
+
+// StatusClientClosedRequest is the non-standard status (from nginx) for a
+// request the client abandoned before the response was ready.
+const StatusClientClosedRequest = 499
+
+// ErrorStatus maps a HeavyCompute, RunBatch or Semaphore.Acquire error to
+// the HTTP status and message handlers answer with: 499 when the client went
+// away and cancelled the request context, 503 when all compute slots stayed
+// busy or the request deadline passed.
+func ErrorStatus(err error) (status int, message string) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest, "client closed request"
+	case errors.Is(err, ErrBusy):
+		return http.StatusServiceUnavailable, "server busy"
+	}
+	return http.StatusServiceUnavailable, "compute timeout"
+}
+
+// checkMask controls how often the loop kernel polls for cancellation: once
+// every checkMask+1 elements.
+const checkMask = 1<<16 - 1
+
+// Progress is reported after each completed iteration of a kernel.
+type Progress struct {
+	Iteration  int     `json:"iteration"`
+	Iterations int     `json:"iterations"`
+	Progress   float64 `json:"progress"`
+	PartialSum int64   `json:"partial_sum"`
+}
+
+// ProgressFunc receives a Progress after each iteration.
+type ProgressFunc func(Progress)
+
+// HeavyCompute runs the selected kernel and reports its checksum, wall time
+// and estimated carbon cost. It periodically checks ctx and returns ctx.Err()
+// once the context is cancelled or its deadline passes.
+func HeavyCompute(ctx context.Context, p Params) (Result, error) {
+	return HeavyComputeWithProgress(ctx, p, nil)
+}
+
+// HeavyComputeWithProgress is HeavyCompute with a callback invoked after
+// every iteration, for streaming endpoints. progress may be nil. Completed
+// runs are recorded in the HeavyStats histogram, and their ETag for
+// KnownETag.
+func HeavyComputeWithProgress(ctx context.Context, p Params, progress ProgressFunc) (Result, error) {
+	result, elapsed, err := heavyCompute(ctx, p, progress)
+	if err != nil {
+		return Result{}, err
+	}
+	heavyElapsed.Observe(elapsed)
+	rememberETag(p, result.ResultHash)
+	return result, nil
+}
+
+// heavyCompute runs the job without recording it, returning its exact wall
+// time alongside the result.
+func heavyCompute(ctx context.Context, p Params, progress ProgressFunc) (Result, time.Duration, error) {
+	if p.Kernel == "" {
+		p.Kernel = KernelLoop
+	}
+
+	// Pin to one OS thread so the thread CPU reading covers only this work
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	start := time.Now()
+	cpuStart := carbon.ThreadCPUSeconds()
+
+	report := func(iteration int, partialSum int64) {
+		if progress != nil {
+			progress(Progress{
+				Iteration:  iteration,
+				Iterations: p.Iterations,
+				Progress:   float64(iteration) / float64(p.Iterations),
+				PartialSum: partialSum,
+			})
+		}
+	}
+
+	// Workers on other threads add their own CPU time here
+	var workerCPU float64
+
+	// ReadMemStats stops the world, so only the alloc kernel pays for it
+	var memBefore runtime.MemStats
+	if p.Kernel == KernelAlloc {
+		runtime.ReadMemStats(&memBefore)
+	}
+
+	var total int64
+	var err error
+	switch p.Kernel {
+	case KernelMatmul:
+		total, err = matmulKernel(ctx, p, &workerCPU, report)
+	case KernelAlloc:
+		total, err = allocKernel(ctx, p, &workerCPU, report)
+	default:
+		total, err = loopKernel(ctx, p, &workerCPU, report)
+	}
+	if err != nil {
+		return Result{}, 0, err
+	}
+
+	var allocations *AllocStats
+	if p.Kernel == KernelAlloc {
+		var memAfter runtime.MemStats
+		runtime.ReadMemStats(&memAfter)
+		allocations = &AllocStats{
+			Mallocs:        memAfter.Mallocs - memBefore.Mallocs,
+			BytesAllocated: memAfter.TotalAlloc - memBefore.TotalAlloc,
+			NumGC:          memAfter.NumGC - memBefore.NumGC,
+			GCPauseTotalNs: memAfter.PauseTotalNs - memBefore.PauseTotalNs,
+		}
+	}
+
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d", total)))
+	hashStr := hex.EncodeToString(hash[:])
+
+	elapsed := time.Since(start)
+	joules, grams := carbon.EstimateCO2(carbon.ThreadCPUSeconds() - cpuStart + workerCPU)
+
+	return Result{
+		ResultHash: hashStr,
+		TotalSum:   total,
+		MatrixSize: p.Size,
+		Iterations: p.Iterations,
+		Kernel:     p.Kernel,
+		ElapsedMs:  elapsed.Milliseconds(),
+
+		EstimatedJoules:   joules,
+		EstimatedCO2Grams: grams,
+
+		Allocations: allocations,
+	}, elapsed, nil
+}
+
+// seededRand returns the PRNG for a job's inputs, or nil for seed 0. The
+// math/rand sources produce the same stream for a given seed on every
+// platform and Go release, which is what makes seeded results reproducible.
+func seededRand(seed int) *rand.Rand {
+	if seed == 0 {
+		return nil
+	}
+	return rand.New(rand.NewSource(int64(seed)))
+}
+
+func loopKernel(ctx context.Context, p Params, workerCPU *float64, report func(int, int64)) (int64, error) {
+	size := p.Size
+	a := make([]int, size)
+	rng := seededRand(p.Seed)
+	for i := range a {
+		if rng != nil {
+			a[i] = rng.Intn(size)
+		} else {
+			a[i] = i
+		}
+	}
+
+	var total int64
+	for iteration := 0; iteration < p.Iterations; iteration++ {
+		sum, err := parallel(size, p.Goroutines, workerCPU, func(lo, hi int) (int64, error) {
+			var sum int64
+			for i, x := range a[lo:hi] {
+				if i&checkMask == 0 {
+					if err := ctx.Err(); err != nil {
+						return 0, err
+					}
+				}
+				sum += int64(x*x) % int64(size+1)
+			}
+			return sum, nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		total += sum
+		report(iteration+1, total)
+	}
+	return total, nil
+}
+
+// matmulKernel computes C = A×B for row-major size×size matrices and sums
+// the elements of C. Entries are kept small so the sum fits in an int64.
+func matmulKernel(ctx context.Context, p Params, workerCPU *float64, report func(int, int64)) (int64, error) {
+	size := p.Size
+	a := make([]int64, size*size)
+	b := make([]int64, size*size)
+	c := make([]int64, size*size)
+	rng := seededRand(p.Seed)
+	for i := range a {
+		if rng != nil {
+			a[i] = rng.Int63n(17)
+			b[i] = rng.Int63n(13)
+		} else {
+			a[i] = int64(i % 17)
+			b[i] = int64((i * 7) % 13)
+		}
+	}
+
+	var total int64
+	for iteration := 0; iteration < p.Iterations; iteration++ {
+		// Each worker owns a band of rows of C, so no two write the same element
+		sum, err := parallel(size, p.Goroutines, workerCPU, func(lo, hi int) (int64, error) {
+			var sum int64
+			// i-k-j order walks B and C row-wise for cache-friendly access
+			for i := lo; i < hi; i++ {
+				if err := ctx.Err(); err != nil {
+					return 0, err
+				}
+				row := c[i*size : (i+1)*size]
+				for j := range row {
+					row[j] = 0
+				}
+				for k := 0; k < size; k++ {
+					aik := a[i*size+k]
+					bRow := b[k*size : (k+1)*size]
+					for j := range row {
+						row[j] += aik * bRow[j]
+					}
+				}
+				for _, v := range row {
+					sum += v
+				}
+			}
+			return sum, nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		total += sum
+		// Feed the result back so iterations can't be collapsed into one
+		a, c = c, a
+		for i := range a {
+			a[i] %= 17
+		}
+		report(iteration+1, total)
+	}
+	return total, nil
+}
+
+// AllocBytesPerIteration is what KernelAlloc allocates per iteration,
+// whatever the buffer size, so MaxIterations bounds a job at 4 GiB in total.
+const AllocBytesPerIteration = 4 << 20
+
+// cacheLine is the stride allocKernel touches buffers at, so every line is
+// brought into cache without the kernel turning into a memset.
+const cacheLine = 64
+
+// allocKernel allocates AllocBytesPerIteration/size buffers of size bytes
+// per iteration, writes one byte per cache line of each and sums what it
+// wrote. Buffer i's bytes depend only on i, j and the seed, so the total is
+// the same however the buffers are split across goroutines.
+func allocKernel(ctx context.Context, p Params, workerCPU *float64, report func(int, int64)) (int64, error) {
+	size := p.Size
+	buffers := AllocBytesPerIteration / size
+
+	var total int64
+	for iteration := 0; iteration < p.Iterations; iteration++ {
+		sum, err := parallel(buffers, p.Goroutines, workerCPU, func(lo, hi int) (int64, error) {
+			var sum int64
+			for i := lo; i < hi; i++ {
+				if i&255 == 0 {
+					if err := ctx.Err(); err != nil {
+						return 0, err
+					}
+				}
+				buf := make([]byte, size)
+				for j := 0; j < len(buf); j += cacheLine {
+					buf[j] = byte(i + j + p.Seed)
+					sum += int64(buf[j])
+				}
+			}
+			return sum, nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		total += sum
+		report(iteration+1, total)
+	}
+	return total, nil
+}
+
+// parallel runs fn over [0, n) split into at most goroutines contiguous
+// chunks and returns the sum of their results. Integer addition is
+// associative, so the sum doesn't depend on the split. Each worker pins its
+// own OS thread and adds the CPU time it used to workerCPU, since the
+// caller's thread reading can't see it. With one goroutine fn runs inline.
+func parallel(n, goroutines int, workerCPU *float64, fn func(lo, hi int) (int64, error)) (int64, error) {
+	if goroutines <= 1 || n < 2 {
+		return fn(0, n)
+	}
+
+	type share struct {
+		sum        int64
+		cpuSeconds float64
+		err        error
+	}
+	chunk := (n + goroutines - 1) / goroutines
+	shares := make([]share, goroutines)
+
+	var wg sync.WaitGroup
+	for w := range shares {
+		lo := w * chunk
+		if lo >= n {
+			break
+		}
+		hi := lo + chunk
+		if hi > n {
+			hi = n
+		}
+
+		wg.Add(1)
+		go func(s *share, lo, hi int) {
+			defer wg.Done()
+			runtime.LockOSThread()
+			defer runtime.UnlockOSThread()
+
+			cpuStart := carbon.ThreadCPUSeconds()
+			s.sum, s.err = fn(lo, hi)
+			s.cpuSeconds = carbon.ThreadCPUSeconds() - cpuStart
+		}(&shares[w], lo, hi)
+	}
+	wg.Wait()
+
+	var total int64
+	for _, s := range shares {
+		if s.err != nil {
+			return 0, s.err
+		}
+		total += s.sum
+		if carbon.PerThreadCPU {
+			*workerCPU += s.cpuSeconds
+		}
+	}
+	return total, nil
+}
+
+// MaxBatchJobs caps the number of jobs accepted by a single batch request.
+const MaxBatchJobs = 64
+
+// RunBatch runs jobs with at most concurrency executing at once and returns
+// their results in input order. Each job also holds a slot of limit while it
+// runs, so a batch competes for the CPU like as many single requests. The
+// first failure cancels the jobs that are still queued or running and is
+// returned as the error.
+func RunBatch(ctx context.Context, jobs []Params, concurrency int, limit *Semaphore) ([]Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]Result, len(jobs))
+	sem := make(chan struct{}, concurrency)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for i, job := range jobs {
+		wg.Add(1)
+		go func(i int, job Params) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				fail(ctx.Err())
+				return
+			}
+
+			release, err := limit.Acquire(ctx)
+			if err != nil {
+				fail(err)
+				return
+			}
+			result, err := HeavyCompute(ctx, job)
+			release()
+			if err != nil {
+				fail(err)
+				return
+			}
+			results[i] = result
+		}(i, job)
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
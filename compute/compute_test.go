@@ -0,0 +1,173 @@
+package compute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeParamsDefaultSize(t *testing.T) {
+	defaults := Params{Size: 5000, Iterations: 5}
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{}`, 5000},
+		{`{"kernel":"loop"}`, 5000},
+		{`{"kernel":"matmul"}`, DefaultMatmulSize},
+		{`{"kernel":"matmul","size":50}`, 50},
+	}
+	for _, tt := range tests {
+		p, err := DecodeParams(strings.NewReader(tt.body), defaults)
+		if err != nil {
+			t.Errorf("DecodeParams(%s): %v", tt.body, err)
+			continue
+		}
+		if p.Size != tt.want {
+			t.Errorf("DecodeParams(%s).Size = %d, want %d", tt.body, p.Size, tt.want)
+		}
+	}
+}
+
+func TestDecodeParamsRejectsExplicitZeroSize(t *testing.T) {
+	if _, err := DecodeParams(strings.NewReader(`{"kernel":"matmul","size":0}`), Params{Size: 5000, Iterations: 5}); err == nil {
+		t.Error("size 0 was accepted")
+	}
+}
+
+// reproducibleJobs cover every kernel with a nonzero seed, small enough to
+// run in milliseconds.
+var reproducibleJobs = []Params{
+	{Kernel: KernelLoop, Size: 5000, Iterations: 3, Seed: 42},
+	{Kernel: KernelMatmul, Size: 40, Iterations: 2, Seed: 42},
+	{Kernel: KernelAlloc, Size: 4096, Iterations: 1, Seed: 42},
+}
+
+func mustCompute(t *testing.T, p Params) Result {
+	t.Helper()
+	if err := p.Validate(); err != nil {
+		t.Fatalf("%+v: %v", p, err)
+	}
+	result, err := HeavyCompute(context.Background(), p)
+	if err != nil {
+		t.Fatalf("HeavyCompute(%+v): %v", p, err)
+	}
+	return result
+}
+
+func TestSeededResultReproducible(t *testing.T) {
+	for _, p := range reproducibleJobs {
+		first := mustCompute(t, p)
+		if again := mustCompute(t, p); again.ResultHash != first.ResultHash {
+			t.Errorf("%s: hash %s then %s", p.Kernel, first.ResultHash, again.ResultHash)
+		}
+
+		parallel := p
+		parallel.Goroutines = 4
+		if got := mustCompute(t, parallel); got.ResultHash != first.ResultHash {
+			t.Errorf("%s: 4 goroutines hash %s, want %s", p.Kernel, got.ResultHash, first.ResultHash)
+		}
+
+		// The alloc kernel's bytes wrap mod 256 over whole cycles, so its
+		// sum doesn't depend on the seed
+		if p.Kernel == KernelAlloc {
+			continue
+		}
+		reseeded := p
+		reseeded.Seed = 43
+		if got := mustCompute(t, reseeded); got.ResultHash == first.ResultHash {
+			t.Errorf("%s: seeds 42 and 43 share hash %s", p.Kernel, got.ResultHash)
+		}
+	}
+}
+
+// TestSeededResultReproducibleAcrossProcesses runs the jobs again in a fresh
+// test process, as two server instances would, and compares hashes.
+func TestSeededResultReproducibleAcrossProcesses(t *testing.T) {
+	if os.Getenv("COMPUTE_HASH_CHILD") == "1" {
+		for _, p := range reproducibleJobs {
+			fmt.Println(mustCompute(t, p).ResultHash)
+		}
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSeededResultReproducibleAcrossProcesses$")
+	cmd.Env = append(os.Environ(), "COMPUTE_HASH_CHILD=1")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("child process: %v", err)
+	}
+	for _, p := range reproducibleJobs {
+		want := mustCompute(t, p).ResultHash
+		if !strings.Contains(string(out), want) {
+			t.Errorf("%s: hash %s missing from child output %q", p.Kernel, want, out)
+		}
+	}
+}
+
+// hugeJobs take minutes per kernel if nothing stops them.
+var hugeJobs = []Params{
+	{Kernel: KernelLoop, Size: MaxLoopSize, Iterations: MaxIterations, Goroutines: 1},
+	{Kernel: KernelLoop, Size: MaxLoopSize, Iterations: MaxIterations, Goroutines: 4},
+	{Kernel: KernelMatmul, Size: MaxMatmulSize, Iterations: MaxIterations, Goroutines: 1},
+	{Kernel: KernelMatmul, Size: MaxMatmulSize, Iterations: MaxIterations, Goroutines: 4},
+	{Kernel: KernelAlloc, Size: MaxAllocSize, Iterations: MaxIterations, Goroutines: 1},
+	{Kernel: KernelAlloc, Size: MaxAllocSize, Iterations: MaxIterations, Goroutines: 4},
+}
+
+func TestHeavyComputeAlreadyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, p := range hugeJobs {
+		start := time.Now()
+		_, err := HeavyCompute(ctx, p)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s/%d goroutines: err %v, want context.Canceled", p.Kernel, p.Goroutines, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s/%d goroutines: took %s on a cancelled context", p.Kernel, p.Goroutines, elapsed)
+		}
+	}
+}
+
+func TestHeavyComputeStopsWhenCancelled(t *testing.T) {
+	for _, p := range hugeJobs {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err := HeavyCompute(ctx, p)
+		elapsed := time.Since(start)
+		cancel()
+
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s/%d goroutines: err %v, want context.Canceled", p.Kernel, p.Goroutines, err)
+		}
+		if elapsed > 2*time.Second {
+			t.Errorf("%s/%d goroutines: took %s to stop after a 20ms cancel", p.Kernel, p.Goroutines, elapsed)
+		}
+	}
+}
+
+func TestHeavyComputeStopsAtDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := HeavyCompute(ctx, hugeJobs[0]); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRunBatchTakesSemaphoreSlot(t *testing.T) {
+	jobs := []Params{{Kernel: KernelLoop, Size: 1000, Iterations: 1}}
+	if _, err := RunBatch(context.Background(), jobs, 1, busySemaphore(t)); !errors.Is(err, ErrBusy) {
+		t.Errorf("RunBatch with no free slot: err %v, want ErrBusy", err)
+	}
+	if _, err := RunBatch(context.Background(), jobs, 1, NewSemaphore(1, 0)); err != nil {
+		t.Errorf("RunBatch with a free slot: %v", err)
+	}
+}
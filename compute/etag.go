@@ -0,0 +1,70 @@
+package compute
+
+import (
+	"strings"
+	"sync"
+)
+
+// maxKnownETags bounds the parameter sets whose ETag is remembered. The
+// table is simply emptied when it fills; a forgotten entry only costs a
+// recomputation.
+const maxKnownETags = 4096
+
+var (
+	knownMu    sync.Mutex
+	knownETags = make(map[Params]string)
+)
+
+// ETag returns the entity tag for a result. It is derived from ResultHash
+// alone, so every framework tags identical inputs identically. The tag is
+// weak: the JSON, MessagePack and protobuf bodies of a result, with any
+// padding, share it, and are equivalent but not byte-identical.
+func ETag(resultHash string) string {
+	return `W/"` + resultHash + `"`
+}
+
+// KnownETag returns the ETag of the result HeavyCompute last produced for p
+// in this process, letting a conditional request be answered with 304
+// without computing anything.
+func KnownETag(p Params) (string, bool) {
+	knownMu.Lock()
+	defer knownMu.Unlock()
+
+	etag, ok := knownETags[etagKey(p)]
+	return etag, ok
+}
+
+func rememberETag(p Params, resultHash string) {
+	knownMu.Lock()
+	defer knownMu.Unlock()
+
+	if len(knownETags) >= maxKnownETags {
+		knownETags = make(map[Params]string)
+	}
+	knownETags[etagKey(p)] = ETag(resultHash)
+}
+
+// etagKey drops Goroutines, which never changes the result.
+func etagKey(p Params) Params {
+	if p.Kernel == "" {
+		p.Kernel = KernelLoop
+	}
+	p.Goroutines = 0
+	return p
+}
+
+// MatchesETag reports whether an If-None-Match header value names etag,
+// using the weak comparison RFC 9110 prescribes for If-None-Match.
+func MatchesETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package compute
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// MaxFanoutTasks bounds the goroutines a single Fanout request starts. Each
+// holds at least a 2 KiB stack until it finishes, and a burst of requests at
+// the cap still fits comfortably in memory.
+const MaxFanoutTasks = 50_000
+
+// fanoutWork is the number of multiply-adds each task performs: enough that
+// the compiler can't drop it, small enough that scheduling dominates.
+const fanoutWork = 100
+
+// fanoutSampleEvery is how often, in goroutines started, Fanout samples the
+// goroutine count.
+const fanoutSampleEvery = 256
+
+// FanoutResult is the outcome of a Fanout job. PeakGoroutines is the highest
+// process-wide goroutine count seen while tasks were being started, so it
+// includes goroutines belonging to concurrent requests.
+type FanoutResult struct {
+	Tasks          int   `json:"tasks"`
+	Sum            int64 `json:"sum"`
+	ElapsedMs      int64 `json:"elapsed_ms"`
+	NsPerTask      int64 `json:"ns_per_task"`
+	PeakGoroutines int   `json:"peak_goroutines"`
+}
+
+// Fanout starts tasks goroutines that each do a tiny computation, waits for
+// all of them and sums their results, measuring goroutine creation and
+// scheduling overhead rather than the work itself. It stops starting tasks
+// and returns ctx.Err() once ctx ends.
+func Fanout(ctx context.Context, tasks int) (FanoutResult, error) {
+	results := make([]int64, tasks)
+	peak := runtime.NumGoroutine()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < tasks; i++ {
+		if i%fanoutSampleEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				wg.Wait()
+				return FanoutResult{}, err
+			}
+			if n := runtime.NumGoroutine(); n > peak {
+				peak = n
+			}
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var sum int64
+			for j := int64(0); j < fanoutWork; j++ {
+				sum += (int64(i) + j) * j
+			}
+			results[i] = sum
+		}(i)
+	}
+	if n := runtime.NumGoroutine(); n > peak {
+		peak = n
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	var sum int64
+	for _, r := range results {
+		sum += r
+	}
+	return FanoutResult{
+		Tasks:          tasks,
+		Sum:            sum,
+		ElapsedMs:      elapsed.Milliseconds(),
+		NsPerTask:      elapsed.Nanoseconds() / int64(tasks),
+		PeakGoroutines: peak,
+	}, nil
+}
@@ -0,0 +1,56 @@
+package compute
+
+import "runtime"
+
+// HeapMeasurement attributes heap usage to a single job. It forces a
+// collection before the job starts so garbage left by earlier requests isn't
+// counted against it. A forced GC stalls the whole process, so this is for
+// isolated measurements, never for load tests.
+type HeapMeasurement struct {
+	baseline uint64
+}
+
+// HeapDelta is the heap usage attributed to one job.
+type HeapDelta struct {
+	// BaselineBytes is HeapAlloc right after the forced GC before the job
+	BaselineBytes uint64 `json:"baseline_bytes"`
+	// AllocDeltaBytes is HeapAlloc right after the job minus the baseline:
+	// what the job allocated that hadn't been collected yet
+	AllocDeltaBytes int64 `json:"alloc_delta_bytes"`
+	// RetainedDeltaBytes is HeapAlloc after a second forced GC minus the
+	// baseline: what the job left reachable
+	RetainedDeltaBytes int64 `json:"retained_delta_bytes"`
+}
+
+// StartHeapMeasurement forces a GC and records the live heap. It returns nil
+// when enabled is false; Done on a nil measurement does nothing.
+func StartHeapMeasurement(enabled bool) *HeapMeasurement {
+	if !enabled {
+		return nil
+	}
+	runtime.GC()
+	return &HeapMeasurement{baseline: heapAlloc()}
+}
+
+// Done reads the heap after the job and again after a second forced GC. It
+// returns nil for a nil measurement.
+func (m *HeapMeasurement) Done() *HeapDelta {
+	if m == nil {
+		return nil
+	}
+	after := heapAlloc()
+	runtime.GC()
+	retained := heapAlloc()
+
+	return &HeapDelta{
+		BaselineBytes:      m.baseline,
+		AllocDeltaBytes:    int64(after) - int64(m.baseline),
+		RetainedDeltaBytes: int64(retained) - int64(m.baseline),
+	}
+}
+
+func heapAlloc() uint64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
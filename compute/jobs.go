@@ -0,0 +1,174 @@
+package compute
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Job statuses, in the order a job moves through them. A job ends in
+// JobDone or JobFailed.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+const (
+	// jobRetention is how long a finished job stays available for polling.
+	jobRetention = 10 * time.Minute
+
+	jobSweepInterval = time.Minute
+)
+
+// ErrQueueFull is returned by JobQueue.Submit when the queue is at capacity.
+var ErrQueueFull = errors.New("compute job queue full")
+
+// Job is a snapshot of an asynchronous compute job. QueueMs is the time it
+// waited for a worker; Result is set once it's done, and Error if it failed.
+type Job struct {
+	ID         string     `json:"job_id"`
+	Status     string     `json:"status"`
+	Params     Params     `json:"params"`
+	QueuedAt   time.Time  `json:"queued_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	QueueMs    int64      `json:"queue_ms"`
+	Result     *Result    `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// JobQueue runs compute jobs on a fixed pool of workers, so a client can
+// submit heavy work and poll for it instead of holding a connection open.
+type JobQueue struct {
+	pending chan *Job
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	mu        sync.Mutex
+	jobs      map[string]*Job
+	nextID    uint64
+	lastSweep time.Time
+}
+
+// NewJobQueue starts workers goroutines serving a queue of at most depth
+// waiting jobs.
+func NewJobQueue(workers, depth int) *JobQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &JobQueue{
+		pending: make(chan *Job, depth),
+		ctx:     ctx,
+		cancel:  cancel,
+		jobs:    make(map[string]*Job),
+
+		lastSweep: time.Now(),
+	}
+	q.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Submit queues p, which must already be validated, and returns the queued
+// job. It returns ErrQueueFull rather than waiting when no room is left.
+func (q *JobQueue) Submit(p Params) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.sweep(time.Now())
+
+	q.nextID++
+	job := &Job{
+		ID:       strconv.FormatUint(q.nextID, 10),
+		Status:   JobQueued,
+		Params:   p,
+		QueuedAt: time.Now(),
+	}
+	select {
+	case q.pending <- job:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	q.jobs[job.ID] = job
+	return *job, nil
+}
+
+// Get returns a snapshot of the job with the given ID. Finished jobs are
+// forgotten some minutes after they end.
+func (q *JobQueue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Depth reports how many jobs are waiting for a worker.
+func (q *JobQueue) Depth() int {
+	return len(q.pending)
+}
+
+// Close cancels running jobs and waits for the workers to exit. Jobs still
+// queued are never started.
+func (q *JobQueue) Close() {
+	q.cancel()
+	q.wg.Wait()
+}
+
+func (q *JobQueue) work() {
+	defer q.wg.Done()
+	for {
+		select {
+		case job := <-q.pending:
+			q.run(job)
+		case <-q.ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *JobQueue) run(job *Job) {
+	q.mu.Lock()
+	started := time.Now()
+	job.Status = JobRunning
+	job.StartedAt = &started
+	job.QueueMs = started.Sub(job.QueuedAt).Milliseconds()
+	p := job.Params
+	q.mu.Unlock()
+
+	result, err := HeavyCompute(q.ctx, p)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	finished := time.Now()
+	job.FinishedAt = &finished
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+		return
+	}
+	job.Status = JobDone
+	job.Result = &result
+}
+
+// sweep drops jobs that finished more than jobRetention ago, at most once
+// per jobSweepInterval. The caller holds q.mu.
+func (q *JobQueue) sweep(now time.Time) {
+	if now.Sub(q.lastSweep) <= jobSweepInterval {
+		return
+	}
+	q.lastSweep = now
+	for id, job := range q.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > jobRetention {
+			delete(q.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package compute
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// Bounds for MemoryPressure. MaxMemoryTotalBytes caps the bytes a single
+// request allocates over its lifetime, not what is live at once.
+const (
+	MaxMemoryObjects    = 1_000_000
+	MaxMemoryObjectSize = 1 << 20
+	MaxMemoryTotalBytes = 1 << 30
+)
+
+// memoryWindow is how many objects stay reachable at a time. Everything
+// older is garbage, so the heap churns instead of growing.
+const memoryWindow = 64
+
+// MemoryParams describes a memory-pressure job: Objects allocations of
+// Size payload bytes each.
+type MemoryParams struct {
+	Objects int
+	Size    int
+}
+
+// Validate checks the combined allocation volume; the per-parameter bounds
+// are enforced where the parameters are parsed.
+func (p MemoryParams) Validate() error {
+	if int64(p.Objects)*int64(p.Size) > MaxMemoryTotalBytes {
+		return fmt.Errorf("objects × size must not exceed %d bytes", MaxMemoryTotalBytes)
+	}
+	return nil
+}
+
+// AllocStats are runtime.MemStats deltas taken across a job. They are
+// process-wide, so requests running concurrently contribute to them too.
+type AllocStats struct {
+	Mallocs        uint64 `json:"mallocs"`
+	BytesAllocated uint64 `json:"bytes_allocated"`
+	NumGC          uint32 `json:"num_gc"`
+	GCPauseTotalNs uint64 `json:"gc_pause_total_ns"`
+}
+
+// MemoryResult is the outcome of a MemoryPressure job.
+type MemoryResult struct {
+	Objects     int        `json:"objects"`
+	ObjectSize  int        `json:"object_size"`
+	Checksum    uint64     `json:"checksum"`
+	ElapsedMs   int64      `json:"elapsed_ms"`
+	Allocations AllocStats `json:"allocations"`
+}
+
+// memoryObject holds a pointer as well as a payload so the collector has
+// to scan it, like the request-scoped structs real handlers allocate.
+type memoryObject struct {
+	prev    *memoryObject
+	payload []byte
+}
+
+// MemoryPressure allocates p.Objects short-lived objects, fills and sums
+// each one, and keeps only the most recent few reachable, generating
+// sustained GC work. It returns ctx.Err() once ctx ends.
+func MemoryPressure(ctx context.Context, p MemoryParams) (MemoryResult, error) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	var window [memoryWindow]*memoryObject
+	var prev *memoryObject
+	var checksum uint64
+	for i := 0; i < p.Objects; i++ {
+		if i&1023 == 0 {
+			if err := ctx.Err(); err != nil {
+				return MemoryResult{}, err
+			}
+		}
+
+		obj := &memoryObject{prev: prev, payload: make([]byte, p.Size)}
+		for j := range obj.payload {
+			obj.payload[j] = byte(i + j)
+		}
+		for _, b := range obj.payload {
+			checksum += uint64(b)
+		}
+
+		// Cut the chain at the window edge so older objects become garbage
+		if old := window[i%memoryWindow]; old != nil {
+			old.prev = nil
+		}
+		window[i%memoryWindow] = obj
+		prev = obj
+	}
+
+	elapsedMs := time.Since(start).Milliseconds()
+	runtime.ReadMemStats(&after)
+
+	return MemoryResult{
+		Objects:    p.Objects,
+		ObjectSize: p.Size,
+		Checksum:   checksum,
+		ElapsedMs:  elapsedMs,
+		Allocations: AllocStats{
+			Mallocs:        after.Mallocs - before.Mallocs,
+			BytesAllocated: after.TotalAlloc - before.TotalAlloc,
+			NumGC:          after.NumGC - before.NumGC,
+			GCPauseTotalNs: after.PauseTotalNs - before.PauseTotalNs,
+		},
+	}, nil
+}
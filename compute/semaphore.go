@@ -0,0 +1,68 @@
+package compute
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrBusy is returned by Semaphore.Acquire when no slot freed up within the
+// maximum wait.
+var ErrBusy = errors.New("too many concurrent compute jobs")
+
+// Semaphore bounds how many compute jobs run at once, so a burst of heavy
+// requests queues instead of oversubscribing the CPU and inflating the
+// carbon cost of every request in flight.
+//
+// A nil *Semaphore imposes no limit, so shared code can take a slot whether
+// or not the caller bounds its jobs.
+type Semaphore struct {
+	slots   chan struct{}
+	maxWait time.Duration
+}
+
+// NewSemaphore allows limit concurrent jobs. A job that can't start within
+// maxWait is rejected; zero rejects as soon as all slots are taken.
+func NewSemaphore(limit int, maxWait time.Duration) *Semaphore {
+	return &Semaphore{slots: make(chan struct{}, limit), maxWait: maxWait}
+}
+
+// Acquire takes a slot, waiting up to the maximum wait. It returns ErrBusy
+// when the wait runs out and ctx.Err() when ctx ends first. On success the
+// caller must call release once the job is done.
+func (s *Semaphore) Acquire(ctx context.Context) (release func(), err error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	release = func() { <-s.slots }
+
+	select {
+	case s.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if s.maxWait <= 0 {
+		return nil, ErrBusy
+	}
+
+	timer := time.NewTimer(s.maxWait)
+	defer timer.Stop()
+	select {
+	case s.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, ErrBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// InFlight reports how many jobs currently hold a slot.
+func (s *Semaphore) InFlight() int {
+	return len(s.slots)
+}
+
+// Limit reports the maximum number of concurrent jobs.
+func (s *Semaphore) Limit() int {
+	return cap(s.slots)
+}
@@ -0,0 +1,164 @@
+package compute
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// The histogram buckets durations in microseconds on a log-linear scale:
+// exact below 2^subBits µs, then 2^subBits buckets per power of two, so any
+// reported quantile is within 1/2^subBits (about 6%) of the true value.
+const (
+	subBits    = 4
+	subBuckets = 1 << subBits
+	numBuckets = subBuckets + (64-subBits)*subBuckets
+)
+
+// Histogram accumulates durations without locking: each observation is a
+// handful of atomic adds, so concurrent heavy requests don't serialize on
+// it. A snapshot taken while observations land may be off by those in
+// flight.
+type Histogram struct {
+	buckets [numBuckets]atomic.Uint64
+	count   atomic.Uint64
+	sumUs   atomic.Uint64
+	minUs   atomic.Uint64
+	maxUs   atomic.Uint64
+	// since is when the histogram was created or last reset, in Unix ns
+	since atomic.Int64
+}
+
+// ElapsedStats summarizes a Histogram. The durations are in milliseconds;
+// they are zero while Count is.
+type ElapsedStats struct {
+	Count  uint64    `json:"count"`
+	MinMs  float64   `json:"min_ms"`
+	MaxMs  float64   `json:"max_ms"`
+	MeanMs float64   `json:"mean_ms"`
+	P50Ms  float64   `json:"p50_ms"`
+	P95Ms  float64   `json:"p95_ms"`
+	P99Ms  float64   `json:"p99_ms"`
+	Since  time.Time `json:"since"`
+}
+
+// heavyElapsed records the wall time of every HeavyCompute run. Warmup runs
+// and cache hits aren't runs of the measured job and stay out of it.
+var heavyElapsed = NewHistogram()
+
+// HeavyStats summarizes the wall times of HeavyCompute runs since startup
+// or the last ResetHeavyStats.
+func HeavyStats() ElapsedStats {
+	return heavyElapsed.Stats()
+}
+
+// ResetHeavyStats clears the HeavyCompute histogram and returns what it
+// held, so a client can read and reset between benchmark phases in one call.
+func ResetHeavyStats() ElapsedStats {
+	return heavyElapsed.Reset()
+}
+
+// NewHistogram returns an empty histogram.
+func NewHistogram() *Histogram {
+	h := &Histogram{}
+	h.minUs.Store(math.MaxUint64)
+	h.since.Store(time.Now().UnixNano())
+	return h
+}
+
+// Observe adds one duration. Negative durations count as zero.
+func (h *Histogram) Observe(d time.Duration) {
+	us := uint64(0)
+	if d > 0 {
+		us = uint64(d.Microseconds())
+	}
+
+	h.buckets[bucketIndex(us)].Add(1)
+	h.count.Add(1)
+	h.sumUs.Add(us)
+	for cur := h.minUs.Load(); us < cur && !h.minUs.CompareAndSwap(cur, us); cur = h.minUs.Load() {
+	}
+	for cur := h.maxUs.Load(); us > cur && !h.maxUs.CompareAndSwap(cur, us); cur = h.maxUs.Load() {
+	}
+}
+
+// Stats summarizes the observations so far.
+func (h *Histogram) Stats() ElapsedStats {
+	stats := ElapsedStats{Since: time.Unix(0, h.since.Load()).UTC()}
+
+	var counts [numBuckets]uint64
+	var total uint64
+	for i := range h.buckets {
+		counts[i] = h.buckets[i].Load()
+		total += counts[i]
+	}
+	if total == 0 {
+		return stats
+	}
+
+	stats.Count = total
+	stats.MinMs = usToMs(h.minUs.Load())
+	stats.MaxMs = usToMs(h.maxUs.Load())
+	stats.MeanMs = usToMs(h.sumUs.Load()) / float64(h.count.Load())
+	stats.P50Ms = quantile(counts[:], total, 0.50, stats.MaxMs)
+	stats.P95Ms = quantile(counts[:], total, 0.95, stats.MaxMs)
+	stats.P99Ms = quantile(counts[:], total, 0.99, stats.MaxMs)
+	return stats
+}
+
+// Reset clears the histogram and returns its final Stats. Observations
+// racing with the reset may land on either side of it.
+func (h *Histogram) Reset() ElapsedStats {
+	stats := h.Stats()
+	for i := range h.buckets {
+		h.buckets[i].Store(0)
+	}
+	h.count.Store(0)
+	h.sumUs.Store(0)
+	h.minUs.Store(math.MaxUint64)
+	h.maxUs.Store(0)
+	h.since.Store(time.Now().UnixNano())
+	return stats
+}
+
+// bucketIndex maps a value to its bucket: the value itself below
+// subBuckets, otherwise the power of two it falls in and its next subBits
+// most significant bits.
+func bucketIndex(us uint64) int {
+	if us < subBuckets {
+		return int(us)
+	}
+	exp := bits.Len64(us) - 1
+	sub := (us >> (exp - subBits)) & (subBuckets - 1)
+	return subBuckets + (exp-subBits)*subBuckets + int(sub)
+}
+
+// bucketUpper is the largest value that lands in bucket i.
+func bucketUpper(i int) uint64 {
+	if i < subBuckets {
+		return uint64(i)
+	}
+	exp := (i-subBuckets)/subBuckets + subBits
+	sub := uint64((i - subBuckets) % subBuckets)
+	lower := (subBuckets + sub) << (exp - subBits)
+	return lower + (1 << (exp - subBits)) - 1
+}
+
+// quantile returns the upper bound of the bucket holding the q-th
+// observation, capped at the observed maximum.
+func quantile(counts []uint64, total uint64, q float64, maxMs float64) float64 {
+	rank := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, c := range counts {
+		seen += c
+		if seen >= rank {
+			return math.Min(usToMs(bucketUpper(i)), maxMs)
+		}
+	}
+	return maxMs
+}
+
+func usToMs(us uint64) float64 {
+	return float64(us) / 1000
+}
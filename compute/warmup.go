@@ -0,0 +1,44 @@
+package compute
+
+import (
+	"context"
+	"time"
+)
+
+// MaxWarmup bounds the warmup runs a single request may ask for.
+const MaxWarmup = 10
+
+// warmupPurpose is echoed in responses so a reader of the raw JSON knows
+// what the warmup block means for the timings next to it.
+const warmupPurpose = "untimed runs of the same job before the measured one, so elapsed_ms and the carbon estimates reflect steady state rather than cold caches"
+
+// WarmupInfo describes the warmup runs that preceded a measured job.
+// ElapsedMs is their combined wall time, reported separately so it's clear
+// the measured elapsed_ms excludes it.
+type WarmupInfo struct {
+	Runs      int    `json:"runs"`
+	ElapsedMs int64  `json:"elapsed_ms"`
+	Purpose   string `json:"purpose"`
+}
+
+// Warmup runs p n times and discards the results, bypassing any cache and
+// the HeavyStats histogram, to warm CPU caches and branch predictors before
+// the timed run. It returns nil for n <= 0, so handlers can call it
+// unconditionally.
+func Warmup(ctx context.Context, p Params, n int) (*WarmupInfo, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		if _, _, err := heavyCompute(ctx, p, nil); err != nil {
+			return nil, err
+		}
+	}
+	return &WarmupInfo{
+		Runs:      n,
+		ElapsedMs: time.Since(start).Milliseconds(),
+		Purpose:   warmupPurpose,
+	}, nil
+}
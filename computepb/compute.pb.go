@@ -0,0 +1,265 @@
+// Protocol Buffers form of the heavy and medium analytics responses, served
+// when a request sends Accept: application/x-protobuf. Field names match the
+// JSON keys. Regenerate compute.pb.go after editing, from the repository
+// root:
+//
+//	protoc --go_out=. --go_opt=module=carbon-bench computepb/compute.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.32.0
+// 	protoc        v4.25.1
+// source: computepb/compute.proto
+
+package computepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ComputeResult is one heavy or medium computation. The heap, warmup and
+// allocations diagnostics of the JSON response have no counterpart here.
+type ComputeResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Endpoint          string  `protobuf:"bytes,1,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	Framework         string  `protobuf:"bytes,2,opt,name=framework,proto3" json:"framework,omitempty"`
+	ResultHash        string  `protobuf:"bytes,3,opt,name=result_hash,json=resultHash,proto3" json:"result_hash,omitempty"`
+	TotalSum          int64   `protobuf:"varint,4,opt,name=total_sum,json=totalSum,proto3" json:"total_sum,omitempty"`
+	MatrixSize        int32   `protobuf:"varint,5,opt,name=matrix_size,json=matrixSize,proto3" json:"matrix_size,omitempty"`
+	Iterations        int32   `protobuf:"varint,6,opt,name=iterations,proto3" json:"iterations,omitempty"`
+	Kernel            string  `protobuf:"bytes,7,opt,name=kernel,proto3" json:"kernel,omitempty"`
+	ElapsedMs         int64   `protobuf:"varint,8,opt,name=elapsed_ms,json=elapsedMs,proto3" json:"elapsed_ms,omitempty"`
+	EstimatedJoules   float64 `protobuf:"fixed64,9,opt,name=estimated_joules,json=estimatedJoules,proto3" json:"estimated_joules,omitempty"`
+	EstimatedCo2Grams float64 `protobuf:"fixed64,10,opt,name=estimated_co2_grams,json=estimatedCo2Grams,proto3" json:"estimated_co2_grams,omitempty"`
+	// "hit" or "miss" when ENABLE_COMPUTE_CACHE is set, empty otherwise
+	Cache string `protobuf:"bytes,11,opt,name=cache,proto3" json:"cache,omitempty"`
+	// pad_bytes of filler, empty unless requested
+	Padding string `protobuf:"bytes,12,opt,name=padding,proto3" json:"padding,omitempty"`
+}
+
+func (x *ComputeResult) Reset() {
+	*x = ComputeResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_computepb_compute_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ComputeResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ComputeResult) ProtoMessage() {}
+
+func (x *ComputeResult) ProtoReflect() protoreflect.Message {
+	mi := &file_computepb_compute_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ComputeResult.ProtoReflect.Descriptor instead.
+func (*ComputeResult) Descriptor() ([]byte, []int) {
+	return file_computepb_compute_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ComputeResult) GetEndpoint() string {
+	if x != nil {
+		return x.Endpoint
+	}
+	return ""
+}
+
+func (x *ComputeResult) GetFramework() string {
+	if x != nil {
+		return x.Framework
+	}
+	return ""
+}
+
+func (x *ComputeResult) GetResultHash() string {
+	if x != nil {
+		return x.ResultHash
+	}
+	return ""
+}
+
+func (x *ComputeResult) GetTotalSum() int64 {
+	if x != nil {
+		return x.TotalSum
+	}
+	return 0
+}
+
+func (x *ComputeResult) GetMatrixSize() int32 {
+	if x != nil {
+		return x.MatrixSize
+	}
+	return 0
+}
+
+func (x *ComputeResult) GetIterations() int32 {
+	if x != nil {
+		return x.Iterations
+	}
+	return 0
+}
+
+func (x *ComputeResult) GetKernel() string {
+	if x != nil {
+		return x.Kernel
+	}
+	return ""
+}
+
+func (x *ComputeResult) GetElapsedMs() int64 {
+	if x != nil {
+		return x.ElapsedMs
+	}
+	return 0
+}
+
+func (x *ComputeResult) GetEstimatedJoules() float64 {
+	if x != nil {
+		return x.EstimatedJoules
+	}
+	return 0
+}
+
+func (x *ComputeResult) GetEstimatedCo2Grams() float64 {
+	if x != nil {
+		return x.EstimatedCo2Grams
+	}
+	return 0
+}
+
+func (x *ComputeResult) GetCache() string {
+	if x != nil {
+		return x.Cache
+	}
+	return ""
+}
+
+func (x *ComputeResult) GetPadding() string {
+	if x != nil {
+		return x.Padding
+	}
+	return ""
+}
+
+var File_computepb_compute_proto protoreflect.FileDescriptor
+
+var file_computepb_compute_proto_rawDesc = []byte{
+	0x0a, 0x17, 0x63, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x70, 0x62, 0x2f, 0x63, 0x6f, 0x6d, 0x70,
+	0x75, 0x74, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x13, 0x63, 0x61, 0x72, 0x62, 0x6f,
+	0x6e, 0x62, 0x65, 0x6e, 0x63, 0x68, 0x2e, 0x63, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x22, 0x8a,
+	0x03, 0x0a, 0x0d, 0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x12, 0x1a, 0x0a, 0x08, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x12, 0x1c, 0x0a, 0x09,
+	0x66, 0x72, 0x61, 0x6d, 0x65, 0x77, 0x6f, 0x72, 0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x09, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x77, 0x6f, 0x72, 0x6b, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0a, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x48, 0x61, 0x73, 0x68, 0x12, 0x1b, 0x0a, 0x09, 0x74,
+	0x6f, 0x74, 0x61, 0x6c, 0x5f, 0x73, 0x75, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08,
+	0x74, 0x6f, 0x74, 0x61, 0x6c, 0x53, 0x75, 0x6d, 0x12, 0x1f, 0x0a, 0x0b, 0x6d, 0x61, 0x74, 0x72,
+	0x69, 0x78, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x6d,
+	0x61, 0x74, 0x72, 0x69, 0x78, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x69, 0x74, 0x65,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x69,
+	0x74, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x6b, 0x65, 0x72,
+	0x6e, 0x65, 0x6c, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6b, 0x65, 0x72, 0x6e, 0x65,
+	0x6c, 0x12, 0x1d, 0x0a, 0x0a, 0x65, 0x6c, 0x61, 0x70, 0x73, 0x65, 0x64, 0x5f, 0x6d, 0x73, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x65, 0x6c, 0x61, 0x70, 0x73, 0x65, 0x64, 0x4d, 0x73,
+	0x12, 0x29, 0x0a, 0x10, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x6a, 0x6f,
+	0x75, 0x6c, 0x65, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0f, 0x65, 0x73, 0x74, 0x69,
+	0x6d, 0x61, 0x74, 0x65, 0x64, 0x4a, 0x6f, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x2e, 0x0a, 0x13, 0x65,
+	0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x63, 0x6f, 0x32, 0x5f, 0x67, 0x72, 0x61,
+	0x6d, 0x73, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x01, 0x52, 0x11, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61,
+	0x74, 0x65, 0x64, 0x43, 0x6f, 0x32, 0x47, 0x72, 0x61, 0x6d, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x63,
+	0x61, 0x63, 0x68, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x63, 0x61, 0x63, 0x68,
+	0x65, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x64, 0x64, 0x69, 0x6e, 0x67, 0x18, 0x0c, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x70, 0x61, 0x64, 0x64, 0x69, 0x6e, 0x67, 0x42, 0x18, 0x5a, 0x16, 0x63,
+	0x61, 0x72, 0x62, 0x6f, 0x6e, 0x2d, 0x62, 0x65, 0x6e, 0x63, 0x68, 0x2f, 0x63, 0x6f, 0x6d, 0x70,
+	0x75, 0x74, 0x65, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_computepb_compute_proto_rawDescOnce sync.Once
+	file_computepb_compute_proto_rawDescData = file_computepb_compute_proto_rawDesc
+)
+
+func file_computepb_compute_proto_rawDescGZIP() []byte {
+	file_computepb_compute_proto_rawDescOnce.Do(func() {
+		file_computepb_compute_proto_rawDescData = protoimpl.X.CompressGZIP(file_computepb_compute_proto_rawDescData)
+	})
+	return file_computepb_compute_proto_rawDescData
+}
+
+var file_computepb_compute_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_computepb_compute_proto_goTypes = []interface{}{
+	(*ComputeResult)(nil), // 0: carbonbench.compute.ComputeResult
+}
+var file_computepb_compute_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_computepb_compute_proto_init() }
+func file_computepb_compute_proto_init() {
+	if File_computepb_compute_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_computepb_compute_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ComputeResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_computepb_compute_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_computepb_compute_proto_goTypes,
+		DependencyIndexes: file_computepb_compute_proto_depIdxs,
+		MessageInfos:      file_computepb_compute_proto_msgTypes,
+	}.Build()
+	File_computepb_compute_proto = out.File
+	file_computepb_compute_proto_rawDesc = nil
+	file_computepb_compute_proto_goTypes = nil
+	file_computepb_compute_proto_depIdxs = nil
+}
@@ -0,0 +1,25 @@
+// Package computepb holds the Protocol Buffers message for heavy and medium
+// analytics results, generated from compute.proto, so the encoding cost of
+// protobuf can be compared with JSON and MessagePack on the same payload.
+package computepb
+
+import "carbon-bench/compute"
+
+// FromResult returns the message for result as answered by endpoint on
+// framework, with padding as its filler. Cache is left for the caller to
+// fill in.
+func FromResult(endpoint, framework string, result compute.Result, padding string) *ComputeResult {
+	return &ComputeResult{
+		Endpoint:          endpoint,
+		Framework:         framework,
+		ResultHash:        result.ResultHash,
+		TotalSum:          result.TotalSum,
+		MatrixSize:        int32(result.MatrixSize),
+		Iterations:        int32(result.Iterations),
+		Kernel:            string(result.Kernel),
+		ElapsedMs:         result.ElapsedMs,
+		EstimatedJoules:   result.EstimatedJoules,
+		EstimatedCo2Grams: result.EstimatedCO2Grams,
+		Padding:           padding,
+	}
+}
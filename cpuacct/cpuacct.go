@@ -0,0 +1,76 @@
+// Package cpuacct attributes CPU time to individual requests by reading the
+// serving thread's CPU clock at the start of a request and again just before
+// its response header is sent.
+//
+// The handler goroutine must stay locked to its OS thread for the reading to
+// be meaningful, and work handed off to other goroutines (e.g. the batch
+// endpoint's workers) is not included.
+package cpuacct
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+
+	"carbon-bench/carbon"
+)
+
+// Header carries the CPU milliseconds consumed by the request.
+const Header = "X-CPU-Ms"
+
+// Format renders CPU seconds as the header value in milliseconds.
+func Format(cpuSeconds float64) string {
+	return strconv.FormatFloat(cpuSeconds*1000, 'f', 3, 64)
+}
+
+// ResponseWriter sets Header on the first WriteHeader or Write. It keeps
+// the Flusher and Hijacker capabilities of the wrapped writer so streaming
+// and WebSocket endpoints still work behind it.
+type ResponseWriter struct {
+	http.ResponseWriter
+	start       float64
+	wroteHeader bool
+}
+
+// NewResponseWriter wraps w, measuring from the current thread CPU time.
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w, start: carbon.ThreadCPUSeconds()}
+}
+
+func (w *ResponseWriter) setHeader() {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.Header().Set(Header, Format(carbon.ThreadCPUSeconds()-w.start))
+}
+
+func (w *ResponseWriter) WriteHeader(code int) {
+	w.setHeader()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.setHeader()
+		f.Flush()
+	}
+}
+
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
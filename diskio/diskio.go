@@ -0,0 +1,111 @@
+// Package diskio implements the disk I/O workload shared by the framework
+// apps: a write, fsync and read-back of a temp file, so every implementation
+// pays the same filesystem cost.
+package diskio
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultBytes is the payload size when none is requested.
+	DefaultBytes = 1 << 20
+	// MaxBytes keeps a single request from filling the disk or tying it up
+	// for seconds.
+	MaxBytes = 64 << 20
+
+	chunkSize = 64 << 10
+)
+
+// Result is the outcome of one file round trip. The phase timings are in
+// microseconds because an fsync on fast storage often takes well under a
+// millisecond.
+type Result struct {
+	BytesWritten int64  `json:"bytes_written"`
+	BytesRead    int64  `json:"bytes_read"`
+	SHA256       string `json:"sha256"`
+	ElapsedMs    int64  `json:"elapsed_ms"`
+	WriteUs      int64  `json:"write_us"`
+	FsyncUs      int64  `json:"fsync_us"`
+	ReadUs       int64  `json:"read_us"`
+}
+
+// RoundTrip writes size bytes to a new temp file, fsyncs it, reads it back
+// and hashes what was read. The file is removed before returning. ctx is
+// checked between chunks.
+func RoundTrip(ctx context.Context, size int) (result Result, err error) {
+	start := time.Now()
+
+	f, err := os.CreateTemp("", "carbon-bench-*.bin")
+	if err != nil {
+		return Result{}, err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	// A fixed pattern keeps the hash identical across runs and frameworks
+	chunk := make([]byte, chunkSize)
+	for i := range chunk {
+		chunk[i] = byte(i)
+	}
+
+	phase := time.Now()
+	for remaining := size; remaining > 0; remaining -= len(chunk) {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		n := len(chunk)
+		if remaining < n {
+			n = remaining
+		}
+		written, err := f.Write(chunk[:n])
+		result.BytesWritten += int64(written)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	result.WriteUs = time.Since(phase).Microseconds()
+
+	phase = time.Now()
+	if err := f.Sync(); err != nil {
+		return Result{}, err
+	}
+	result.FsyncUs = time.Since(phase).Microseconds()
+
+	phase = time.Now()
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return Result{}, err
+	}
+	h := sha256.New()
+	for {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		n, err := f.Read(chunk)
+		h.Write(chunk[:n])
+		result.BytesRead += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	result.ReadUs = time.Since(phase).Microseconds()
+
+	if result.BytesRead != result.BytesWritten {
+		return Result{}, fmt.Errorf("read back %d of %d bytes", result.BytesRead, result.BytesWritten)
+	}
+
+	result.SHA256 = hex.EncodeToString(h.Sum(nil))
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	return result, nil
+}
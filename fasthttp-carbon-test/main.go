@@ -0,0 +1,1500 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+
+	"carbon-bench/api"
+	"carbon-bench/carbon"
+	"carbon-bench/compute"
+	"carbon-bench/computepb"
+	"carbon-bench/config"
+	"carbon-bench/csvstream"
+	"carbon-bench/diskio"
+	"carbon-bench/health"
+	"carbon-bench/inflight"
+	"carbon-bench/jsonenc"
+	"carbon-bench/jwtauth"
+	"carbon-bench/negotiate"
+	"carbon-bench/padding"
+	"carbon-bench/params"
+	"carbon-bench/selfcheck"
+	"carbon-bench/sse"
+	"carbon-bench/statslog"
+	"carbon-bench/store"
+	"carbon-bench/trailer"
+	"carbon-bench/weather"
+	"carbon-bench/wsecho"
+	_ "github.com/lib/pq"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+var (
+	startTime time.Time
+	db        *sql.DB
+	dbReady   bool
+
+	// Prepared once at startup unless DB_PREPARED_STATEMENTS=false
+	selectUsersStmt *sql.Stmt
+	insertUserStmt  *sql.Stmt
+	encoder         jsonenc.Encoder
+
+	// weatherUpstream is set when WEATHER_UPSTREAM_URL is configured
+	weatherUpstream *weather.Upstream
+	weatherFetcher  *weather.OpenMeteo
+
+	wsServer *wsecho.Server
+
+	// requests counts in-flight requests and their peak for /api/v1/metrics
+	requests inflight.Gauge
+
+	// enableTrailers is ENABLE_TRAILERS: streams end with timing trailers
+	enableTrailers bool
+
+	// filler pads analytics responses by pad_bytes, up to MAX_PAD_BYTES
+	filler *padding.Filler
+
+	// healthDegradedAfter is HEALTH_DEGRADED_MS, the dependency latency
+	// /api/v1/health/detailed reports as degraded
+	healthDegradedAfter = health.DefaultDegradedAfter
+
+	// requestTimeout bounds the analytics endpoints, including streams
+	// whose body outlives the handler
+	requestTimeout time.Duration
+
+	// authVerifier is set when JWT_SECRET is, and mints and checks tokens
+	authVerifier *jwtauth.Verifier
+)
+
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// createdUser is the createUser response: the user's fields plus whether the
+// prepared-statement path served the insert.
+type createdUser struct {
+	User
+	Prepared bool `json:"prepared"`
+}
+
+func main() {
+	startTime = time.Now()
+
+	// Response JSON encoder (JSON_ENCODER=stdlib|jsoniter)
+	var err error
+	encoder, err = jsonenc.New(getEnv("JSON_ENCODER", jsonenc.Stdlib))
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	log.Printf("✓ JSON encoder: %s", encoder.Name())
+
+	// Listen port (PORT, default 8000)
+	port, err := config.Port()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	maxProcs, err := config.MaxProcs()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	config.SetMaxProcs(maxProcs)
+	addr := fmt.Sprintf(":%d", port)
+
+	// HTTPS when TLS_CERT_FILE and TLS_KEY_FILE are set (ENABLE_TLS overrides)
+	tlsCfg, err := config.TLS()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	// Connection timeouts, shared by every framework
+	timeouts, err := config.Timeouts()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	// Bearer tokens signed with JWT_SECRET, required when REQUIRE_AUTH=true
+	authCfg, err := config.Auth()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	authVerifier = authCfg.Verifier()
+
+	// Initialize database
+	initDB()
+
+	// Resource snapshots in the log every STATS_INTERVAL_SECONDS
+	statsInterval := time.Duration(getEnvInt("STATS_INTERVAL_SECONDS", 0)) * time.Second
+	stopStats := statslog.Start(statsInterval, db)
+	if statsInterval > 0 {
+		log.Printf("✓ Stats logging every %s", statsInterval)
+	}
+
+	// Weather upstreams: Open-Meteo for fetch, optional real upstream for external
+	upstreamTimeout := time.Duration(getEnvInt("WEATHER_UPSTREAM_TIMEOUT_SECONDS", 5)) * time.Second
+	cacheTTL := time.Duration(getEnvInt("WEATHER_CACHE_TTL_SECONDS", 300)) * time.Second
+	weatherFetcher = weather.NewOpenMeteo(cacheTTL, upstreamTimeout)
+	if upstreamURL := getEnv("WEATHER_UPSTREAM_URL", ""); upstreamURL != "" {
+		weatherUpstream = weather.NewUpstream(upstreamURL, upstreamTimeout)
+		log.Printf("✓ Weather upstream: %s (timeout %s)", upstreamURL, upstreamTimeout)
+	}
+
+	// WebSocket echo server for the streaming workload
+	wsServer = wsecho.New(getEnvInt("WS_MAX_CONNECTIONS", 1000))
+
+	healthDegradedAfter = time.Duration(getEnvInt("HEALTH_DEGRADED_MS", int(health.DefaultDegradedAfter.Milliseconds()))) * time.Millisecond
+
+	// Filler for pad_bytes, generated once up to MAX_PAD_BYTES
+	filler = padding.New(getEnvInt("MAX_PAD_BYTES", padding.DefaultMax))
+
+	// Timing trailers on SSE streams (ENABLE_TRAILERS=true)
+	enableTrailers = getEnv("ENABLE_TRAILERS", "false") == "true"
+	if enableTrailers {
+		log.Printf("✓ Stream trailers: %s", trailer.Declaration)
+	}
+
+	// Carbon estimation model
+	gridIntensity, err := config.GridIntensity()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	carbon.Configure(getEnvFloat("CPU_WATTS_PER_CORE", carbon.DefaultWattsPerCore), gridIntensity.Static)
+	if gridIntensity.Live() {
+		carbon.SetProvider(gridIntensity.Provider())
+		log.Printf("✓ Grid intensity: %s", gridIntensity)
+	}
+
+	requestTimeout = time.Duration(getEnvInt("REQUEST_TIMEOUT_SECONDS", 10)) * time.Second
+
+	handler := newHandler(authCfg)
+
+	srv := &fasthttp.Server{
+		Handler:               handler,
+		NoDefaultServerHeader: true,
+		ReadTimeout:           timeouts.Read,
+		WriteTimeout:          timeouts.Write,
+		IdleTimeout:           timeouts.Idle,
+	}
+	log.Printf("✓ Server timeouts: %s", timeouts)
+
+	go func() {
+		log.Printf("🚀 fasthttp server starting on %s (%s)", addr, tlsCfg.Mode())
+		var err error
+		if tlsCfg.Enabled {
+			err = srv.ListenAndServeTLS(addr, tlsCfg.CertFile, tlsCfg.KeyFile)
+		} else {
+			err = srv.ListenAndServe(addr)
+		}
+		if err != nil {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	// SELF_CHECK requests every GET endpoint against the live server and
+	// exits nonzero if any of them doesn't answer 200
+	if getEnv("SELF_CHECK", "false") == "true" {
+		go func() {
+			target := selfcheck.Target{Network: "tcp", Address: addr, TLS: tlsCfg.Enabled}
+			if authCfg.Required {
+				token, err := authVerifier.Mint(jwtauth.DefaultSubject, time.Hour)
+				if err != nil {
+					log.Fatalf("Self-check token: %v", err)
+				}
+				target.Token = token.AccessToken
+			}
+			if err := selfcheck.Run(target); err != nil {
+				log.Printf("✗ Self-check failed: %v", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// Wait for SIGINT/SIGTERM, then drain in-flight requests before closing the DB
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	shutdownTimeout := time.Duration(getEnvInt("SHUTDOWN_TIMEOUT_SECONDS", 30)) * time.Second
+	log.Printf("Shutting down server (timeout %s)...", shutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.ShutdownWithContext(ctx); err != nil {
+		log.Printf("⚠️  Server shutdown warning: %v", err)
+	}
+
+	// Stop logging snapshots before the pool they read is closed
+	stopStats()
+
+	if db != nil {
+		db.Close()
+	}
+	log.Println("✓ Server stopped")
+}
+
+// newHandler builds the router with every route and wraps it in the
+// middleware stack.
+func newHandler(authCfg config.AuthConfig) fasthttp.RequestHandler {
+	r := newRouter()
+
+	// Root endpoint
+	r.Get("/", rootHandler)
+
+	// Liveness and readiness probes
+	r.Get("/api/v1/health", healthHandler)
+	r.Get("/api/v1/health/detailed", healthDetailedHandler)
+	r.Get("/api/v1/version", versionHandler)
+	r.Get("/api/v1/routes", routesHandler(r))
+	r.Get("/api/v1/ready", readyHandler)
+
+	// Test tokens for REQUIRE_AUTH=true, whenever JWT_SECRET is set
+	if authVerifier != nil {
+		r.Get(jwtauth.TokenPath, authToken)
+	}
+
+	// Process resource metrics
+	r.Get("/api/v1/metrics", metricsHandler)
+
+	// Analytics endpoints
+	r.Get("/api/v1/weather/analytics/heavy", timeoutMiddleware(analyticsHeavy))
+	r.Post("/api/v1/weather/analytics/heavy", timeoutMiddleware(analyticsHeavy))
+	r.Get("/api/v1/weather/analytics/light", timeoutMiddleware(analyticsLight))
+	r.Get("/api/v1/weather/analytics/medium", timeoutMiddleware(analyticsMedium))
+	r.Get("/api/v1/weather/analytics/memory", timeoutMiddleware(analyticsMemory))
+	r.Get("/api/v1/weather/analytics/fanout", timeoutMiddleware(analyticsFanout))
+	r.Post("/api/v1/weather/analytics/batch", timeoutMiddleware(analyticsBatch))
+	r.Get("/api/v1/analytics/compare", timeoutMiddleware(analyticsCompare))
+	r.Get("/api/v1/weather/analytics/stream", analyticsStream)
+
+	// Heavy compute timing summary; DELETE reads and resets it
+	r.Get("/api/v1/compute/stats", computeStats)
+	r.Delete("/api/v1/compute/stats", resetComputeStats)
+
+	// I/O endpoints
+	r.Get("/api/v1/weather/external", weatherExternal)
+	r.Get("/api/v1/weather/fetch", weatherFetch)
+	r.Get("/api/v1/io/file", fileIO)
+
+	// Serialization endpoints
+	r.Get("/api/v1/bench/json", benchJSON)
+
+	// Database endpoints, under the request timeout so a query is cancelled
+	// along with its request
+	r.Get("/api/v1/db/users", timeoutMiddleware(getUsers))
+	r.Post("/api/v1/db/users", timeoutMiddleware(createUser))
+	r.Post("/api/v1/db/users/bulk", timeoutMiddleware(bulkCreateUsers))
+	r.Get("/api/v1/db/stats", timeoutMiddleware(dbStats))
+
+	// Streaming endpoints
+	r.Get("/api/v1/ws", fasthttpadaptor.NewFastHTTPHandler(wsServer))
+
+	// BENCHMARK_MODE=true drops per-request access logs; errors are still logged
+	benchmarkMode := getEnv("BENCHMARK_MODE", "false") == "true"
+	if benchmarkMode {
+		log.Println("✓ Benchmark mode: request logging disabled")
+	}
+
+	handler := recoverMiddleware(r.Handler)
+	if authCfg.Required {
+		handler = authMiddleware(handler)
+		log.Println("✓ Authentication required: HS256 bearer tokens signed with JWT_SECRET")
+	}
+	handler = headersMiddleware(handler)
+	if !benchmarkMode {
+		handler = loggerMiddleware(handler)
+	}
+	handler = inflightMiddleware(handler)
+	return handler
+}
+
+func initDB() {
+	dbHost := getEnv("DB_HOST", "localhost")
+	dbPort := getEnv("DB_PORT", "5432")
+	dbName := getEnv("DB_NAME", "mydb")
+	dbUser := getEnv("DB_USER", "postgres")
+	dbPassword := getEnv("DB_PASSWORD", "1234")
+
+	ssl, err := config.DBSSL()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s %s",
+		dbHost, dbPort, dbUser, dbPassword, dbName, ssl.DSNParams())
+
+	db, err = sql.Open("postgres", connStr)
+	if err != nil {
+		log.Printf("⚠️  Database connection warning: %v", err)
+		return
+	}
+
+	maxOpenConns := getEnvInt("DB_MAX_OPEN_CONNS", 10)
+	maxIdleConns := getEnvInt("DB_MAX_IDLE_CONNS", 2)
+	connMaxLifetime := time.Duration(getEnvInt("DB_CONN_MAX_LIFETIME_SECONDS", 30)) * time.Second
+
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetConnMaxLifetime(connMaxLifetime)
+	log.Printf("✓ DB pool: max_open=%d max_idle=%d max_lifetime=%s", maxOpenConns, maxIdleConns, connMaxLifetime)
+
+	if err = db.Ping(); err != nil {
+		log.Printf("⚠️  Database ping warning: %v", err)
+	} else {
+		dbReady = true
+		log.Printf("✓ Database connected (sslmode=%s)", ssl.Mode)
+	}
+
+	if dbReady && getEnv("DB_PREPARED_STATEMENTS", "true") == "true" {
+		if err := prepareStatements(); err != nil {
+			log.Printf("⚠️  Prepared statement warning: %v", err)
+		} else {
+			log.Println("✓ Prepared statements ready")
+		}
+	}
+}
+
+// prepareStatements parses the user queries once so handlers skip the
+// per-request parse on the server side. On failure the handlers fall back
+// to unprepared queries.
+func prepareStatements() error {
+	selectStmt, err := db.Prepare(store.SelectUsersQuery)
+	if err != nil {
+		return err
+	}
+	insertStmt, err := db.Prepare(store.InsertUserQuery)
+	if err != nil {
+		selectStmt.Close()
+		return err
+	}
+
+	selectUsersStmt, insertUserStmt = selectStmt, insertStmt
+	return nil
+}
+
+func rootHandler(ctx *fasthttp.RequestCtx) {
+	respondJSON(ctx, http.StatusOK, api.RootResponse{
+		Service:       "Weather Analytics Service",
+		Framework:     "fasthttp",
+		Version:       "1.0.0",
+		Status:        "running",
+		UptimeSeconds: int(time.Since(startTime).Seconds()),
+	})
+}
+
+func healthHandler(ctx *fasthttp.RequestCtx) {
+	uptimeMs := time.Since(startTime).Milliseconds()
+	respondJSON(ctx, http.StatusOK, api.HealthResponse{
+		Status:        "healthy",
+		Framework:     "fasthttp",
+		Gomaxprocs:    runtime.GOMAXPROCS(0),
+		NumCPU:        runtime.NumCPU(),
+		UptimeSeconds: uptimeMs / 1000,
+		UptimeMs:      uptimeMs,
+		Timestamp:     time.Now().UnixMilli(),
+	})
+}
+
+// healthDetailedHandler pings the database and, when configured, the weather
+// upstream, reporting each one's latency and an overall status. It answers
+// 503 when the database is down.
+func healthDetailedHandler(ctx *fasthttp.RequestCtx) {
+	probes := []health.Probe{health.Database(db, dbReady)}
+	if weatherUpstream != nil {
+		probes = append(probes, health.Upstream(weatherUpstream))
+	}
+
+	report := health.Run(requestContext(ctx), healthDegradedAfter, probes...)
+	report.Framework = "fasthttp"
+	respondJSON(ctx, report.HTTPStatus(), report)
+}
+
+// versionHandler reports the build metadata so benchmark results can be
+// traced back to the exact binary.
+func versionHandler(ctx *fasthttp.RequestCtx) {
+	respondJSON(ctx, http.StatusOK, api.NewVersionResponse("fasthttp"))
+}
+
+// authToken mints a token signed with JWT_SECRET for the sub query
+// parameter, valid for ttl_seconds, so load generators can authenticate when
+// REQUIRE_AUTH=true. It is a benchmark helper: anyone who can reach it can
+// get a token.
+func authToken(ctx *fasthttp.RequestCtx) {
+	ttl, err := clampedIntParam(ctx, "ttl_seconds", jwtauth.DefaultTTLSeconds, 1, jwtauth.MaxTTLSeconds)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	subject := string(ctx.QueryArgs().Peek("sub"))
+	if subject == "" {
+		subject = jwtauth.DefaultSubject
+	}
+	token, err := authVerifier.Mint(subject, time.Duration(ttl)*time.Second)
+	if err != nil {
+		writeError(ctx, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	respondJSON(ctx, http.StatusOK, token)
+}
+
+// routesHandler lists every method and path registered on the router, so the
+// harness can check that all frameworks expose the same API before a run.
+func routesHandler(rt *router) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		respondJSON(ctx, http.StatusOK, api.NewRoutesResponse("fasthttp", rt.Routes()))
+	}
+}
+
+// readyHandler is the readiness probe. Unlike healthHandler, which only
+// reports that the process is alive, it fails while the database can't be
+// reached so orchestrators stop routing traffic to this instance.
+func readyHandler(ctx *fasthttp.RequestCtx) {
+	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	if db == nil {
+		respondJSON(ctx, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":            "not_ready",
+			"framework":         "fasthttp",
+			"failed_dependency": "database",
+			"error":             "database not initialized",
+		})
+		return
+	}
+
+	if err := db.PingContext(pingCtx); err != nil {
+		respondJSON(ctx, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":            "not_ready",
+			"framework":         "fasthttp",
+			"failed_dependency": "database",
+			"error":             err.Error(),
+		})
+		return
+	}
+
+	respondJSON(ctx, http.StatusOK, map[string]interface{}{
+		"status":       "ready",
+		"framework":    "fasthttp",
+		"dependencies": map[string]interface{}{"database": "ok"},
+	})
+}
+
+// metricsHandler reports process-level resource usage so throughput can be
+// correlated with memory pressure and CPU time without an external agent.
+func metricsHandler(ctx *fasthttp.RequestCtx) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	var usage syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &usage)
+	userSeconds := float64(usage.Utime.Nano()) / 1e9
+	systemSeconds := float64(usage.Stime.Nano()) / 1e9
+
+	respondJSON(ctx, http.StatusOK, map[string]interface{}{
+		"framework":        "fasthttp",
+		"uptime_seconds":   int(time.Since(startTime).Seconds()),
+		"goroutines":       runtime.NumGoroutine(),
+		"gomaxprocs":       runtime.GOMAXPROCS(0),
+		"num_cpu":          runtime.NumCPU(),
+		"websockets":       wsServer.Active(),
+		"current_inflight": requests.Current(),
+		"peak_inflight":    requests.Peak(),
+		"memory": map[string]interface{}{
+			"alloc_bytes":       mem.Alloc,
+			"total_alloc_bytes": mem.TotalAlloc,
+			"sys_bytes":         mem.Sys,
+			"num_gc":            mem.NumGC,
+			"pause_total_ns":    mem.PauseTotalNs,
+		},
+		"cpu": map[string]interface{}{
+			"user_seconds":   userSeconds,
+			"system_seconds": systemSeconds,
+			"total_seconds":  userSeconds + systemSeconds,
+		},
+	})
+}
+
+func analyticsHeavy(ctx *fasthttp.RequestCtx) {
+	p, err := heavyParams(ctx)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	warmupRuns, err := clampedIntParam(ctx, "warmup", 0, 0, compute.MaxWarmup)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	pad, err := padParam(ctx)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// A client already holding the result for these parameters needs
+	// nothing recomputed or resent
+	if etag, ok := compute.KnownETag(p); ok && notModified(ctx, etag) {
+		return
+	}
+
+	warmup, err := compute.Warmup(requestContext(ctx), p, warmupRuns)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(ctx, status, api.ErrorResponse{Message: message})
+		return
+	}
+	measurement := compute.StartHeapMeasurement(boolParam(ctx, "gc"))
+	result, err := compute.HeavyCompute(requestContext(ctx), p)
+	heapDelta := measurement.Done()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(ctx, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	if notModified(ctx, compute.ETag(result.ResultHash)) {
+		return
+	}
+	resp := map[string]interface{}{
+		"endpoint":    "heavy_analytics",
+		"framework":   "fasthttp",
+		"result_hash": result.ResultHash,
+		"total_sum":   result.TotalSum,
+		"matrix_size": result.MatrixSize,
+		"iterations":  result.Iterations,
+		"kernel":      result.Kernel,
+		"elapsed_ms":  result.ElapsedMs,
+
+		"estimated_joules":    result.EstimatedJoules,
+		"estimated_co2_grams": result.EstimatedCO2Grams,
+	}
+	if result.Allocations != nil {
+		resp["allocations"] = result.Allocations
+	}
+	msg := computepb.FromResult("heavy_analytics", "fasthttp", result, pad)
+	if heapDelta != nil {
+		resp["heap"] = heapDelta
+	}
+	if warmup != nil {
+		resp["warmup"] = warmup
+	}
+	if pad != "" {
+		resp["padding"] = pad
+	}
+	respondCompute(ctx, resp, msg)
+}
+
+func analyticsLight(ctx *fasthttp.RequestCtx) {
+	pad, err := padParam(ctx)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	start := time.Now()
+
+	var result int64
+	for i := 0; i < 1000; i++ {
+		result += int64(i * i)
+	}
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(ctx, http.StatusOK, api.LightAnalyticsResponse{
+		Endpoint:  "light_analytics",
+		Framework: "fasthttp",
+		Result:    result,
+		ElapsedMs: elapsedMs,
+		Padding:   pad,
+	})
+}
+
+func analyticsMedium(ctx *fasthttp.RequestCtx) {
+	p, err := computeParams(ctx, 2000, 3)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	warmupRuns, err := clampedIntParam(ctx, "warmup", 0, 0, compute.MaxWarmup)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	pad, err := padParam(ctx)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// A client already holding the result for these parameters needs
+	// nothing recomputed or resent
+	if etag, ok := compute.KnownETag(p); ok && notModified(ctx, etag) {
+		return
+	}
+
+	warmup, err := compute.Warmup(requestContext(ctx), p, warmupRuns)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(ctx, status, api.ErrorResponse{Message: message})
+		return
+	}
+	measurement := compute.StartHeapMeasurement(boolParam(ctx, "gc"))
+	result, err := compute.HeavyCompute(requestContext(ctx), p)
+	heapDelta := measurement.Done()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(ctx, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	if notModified(ctx, compute.ETag(result.ResultHash)) {
+		return
+	}
+	resp := map[string]interface{}{
+		"endpoint":    "medium_analytics",
+		"framework":   "fasthttp",
+		"result_hash": result.ResultHash,
+		"total_sum":   result.TotalSum,
+		"matrix_size": result.MatrixSize,
+		"iterations":  result.Iterations,
+		"kernel":      result.Kernel,
+		"elapsed_ms":  result.ElapsedMs,
+
+		"estimated_joules":    result.EstimatedJoules,
+		"estimated_co2_grams": result.EstimatedCO2Grams,
+	}
+	if result.Allocations != nil {
+		resp["allocations"] = result.Allocations
+	}
+	msg := computepb.FromResult("medium_analytics", "fasthttp", result, pad)
+	if heapDelta != nil {
+		resp["heap"] = heapDelta
+	}
+	if warmup != nil {
+		resp["warmup"] = warmup
+	}
+	if pad != "" {
+		resp["padding"] = pad
+	}
+	respondCompute(ctx, resp, msg)
+}
+
+// analyticsMemory allocates many short-lived objects to keep the garbage
+// collector busy, reporting the allocations and GC pauses incurred.
+func analyticsMemory(ctx *fasthttp.RequestCtx) {
+	p, err := memoryParams(ctx)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	measurement := compute.StartHeapMeasurement(boolParam(ctx, "gc"))
+	result, err := compute.MemoryPressure(requestContext(ctx), p)
+	heapDelta := measurement.Done()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(ctx, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	resp := map[string]interface{}{
+		"endpoint":    "memory_analytics",
+		"framework":   "fasthttp",
+		"objects":     result.Objects,
+		"object_size": result.ObjectSize,
+		"checksum":    result.Checksum,
+		"elapsed_ms":  result.ElapsedMs,
+		"allocations": result.Allocations,
+	}
+	if heapDelta != nil {
+		resp["heap"] = heapDelta
+	}
+	respondJSON(ctx, http.StatusOK, resp)
+}
+
+// analyticsFanout starts many goroutines that each do almost no work, to
+// measure goroutine creation and scheduling overhead as its own workload.
+func analyticsFanout(ctx *fasthttp.RequestCtx) {
+	tasks, err := clampedIntParam(ctx, "tasks", 1000, 1, compute.MaxFanoutTasks)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	result, err := compute.Fanout(requestContext(ctx), tasks)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(ctx, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(ctx, http.StatusOK, map[string]interface{}{
+		"endpoint":        "fanout_analytics",
+		"framework":       "fasthttp",
+		"tasks":           result.Tasks,
+		"sum":             result.Sum,
+		"elapsed_ms":      result.ElapsedMs,
+		"ns_per_task":     result.NsPerTask,
+		"peak_goroutines": result.PeakGoroutines,
+	})
+}
+
+// analyticsCompare runs every compute kernel at the same size and
+// iterations and reports their timings relative to each other, to check
+// that each kernel scales with its complexity.
+func analyticsCompare(ctx *fasthttp.RequestCtx) {
+	size, err := clampedIntParam(ctx, "size", 200, 1, compute.MaxMatmulSize)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	iterations, err := clampedIntParam(ctx, "iterations", 5, 1, compute.MaxIterations)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := compute.CheckCompare(size, iterations); err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	comparison, err := compute.Compare(requestContext(ctx), size, iterations)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(ctx, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(ctx, http.StatusOK, map[string]interface{}{
+		"endpoint":   "compare_analytics",
+		"framework":  "fasthttp",
+		"size":       comparison.Size,
+		"iterations": comparison.Iterations,
+		"total_ops":  comparison.TotalOps,
+		"kernels":    comparison.Kernels,
+	})
+}
+
+// computeStats summarizes the wall times of every heavy computation this
+// process has run since startup or the last reset.
+func computeStats(ctx *fasthttp.RequestCtx) {
+	respondJSON(ctx, http.StatusOK, map[string]interface{}{
+		"endpoint":  "compute_stats",
+		"framework": "fasthttp",
+		"heavy":     compute.HeavyStats(),
+	})
+}
+
+// resetComputeStats returns the summary and clears it, so one call closes a
+// benchmark phase and starts the next.
+func resetComputeStats(ctx *fasthttp.RequestCtx) {
+	respondJSON(ctx, http.StatusOK, map[string]interface{}{
+		"endpoint":  "compute_stats",
+		"framework": "fasthttp",
+		"heavy":     compute.ResetHeavyStats(),
+		"reset":     true,
+	})
+}
+
+// analyticsBatch runs a list of compute jobs with bounded concurrency and
+// returns their results in request order.
+func analyticsBatch(ctx *fasthttp.RequestCtx) {
+	var jobs []compute.Params
+	if err := json.NewDecoder(bytes.NewReader(ctx.PostBody())).Decode(&jobs); err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	if len(jobs) == 0 || len(jobs) > compute.MaxBatchJobs {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{
+			Message: fmt.Sprintf("batch must contain between 1 and %d jobs", compute.MaxBatchJobs),
+		})
+		return
+	}
+
+	for i := range jobs {
+		if err := jobs[i].Validate(); err != nil {
+			writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error(), Details: map[string]int{"index": i}})
+			return
+		}
+	}
+
+	results, err := compute.RunBatch(requestContext(ctx), jobs, runtime.NumCPU(), nil)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(ctx, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(ctx, http.StatusOK, results)
+}
+
+// analyticsStream runs the heavy workload and streams an SSE "progress" event
+// after every iteration, finishing with a "result" event carrying the
+// ComputeResult.
+func analyticsStream(ctx *fasthttp.RequestCtx) {
+	p, err := computeParams(ctx, 5000, 5)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// fasthttp produces a streamed body after the handler returns, so the
+	// headers are set here and the stream gets its own deadline
+	ctx.SetContentType("text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.Response.Header.Set("Connection", "keep-alive")
+	ctx.SetStatusCode(http.StatusOK)
+	if enableTrailers {
+		ctx.Response.Header.SetTrailer(trailer.Declaration)
+	}
+	ctx.SetBodyStreamWriter(func(bw *bufio.Writer) {
+		// The trailers are written from the header once this returns
+		if enableTrailers {
+			defer trailer.Start(ctx.Response.Header.Set).Finish()
+		}
+		streamCtx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		w := &sseWriter{Writer: bw, cancel: cancel}
+
+		result, err := compute.HeavyComputeWithProgress(streamCtx, p, func(progress compute.Progress) {
+			sse.WriteEvent(w, "progress", progress)
+		})
+		if err != nil {
+			// A cancelled context means the client went away; nobody is listening
+			if !errors.Is(err, context.Canceled) {
+				sse.WriteEvent(w, "error", api.ErrorResponse{Message: "compute timeout"}.ForStatus(http.StatusServiceUnavailable))
+			}
+			return
+		}
+
+		sse.WriteEvent(w, "result", result)
+	})
+}
+
+func weatherExternal(ctx *fasthttp.RequestCtx) {
+	if weatherUpstream != nil {
+		weatherExternalUpstream(ctx)
+		return
+	}
+
+	delayMs := parseIntParam(ctx, "delay_ms", 100)
+	start := time.Now()
+
+	// Stop waiting as soon as the client goes away, so abandoned requests
+	// don't hold a goroutine for the full delay
+	timer := time.NewTimer(time.Duration(delayMs) * time.Millisecond)
+	select {
+	case <-requestContext(ctx).Done():
+		timer.Stop()
+		writeError(ctx, compute.StatusClientClosedRequest, api.ErrorResponse{Message: "client closed request"})
+		return
+	case <-timer.C:
+	}
+
+	weatherData := map[string]interface{}{
+		"temperature": 25.5,
+		"humidity":    65,
+		"wind_speed":  12.3,
+		"conditions":  "Partly Cloudy",
+		"location":    "Colombo, LK",
+	}
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(ctx, http.StatusOK, map[string]interface{}{
+		"endpoint":           "external_api",
+		"framework":          "fasthttp",
+		"data":               weatherData,
+		"simulated_delay_ms": delayMs,
+		"elapsed_ms":         elapsedMs,
+	})
+}
+
+// weatherExternalUpstream proxies the configured upstream's JSON through,
+// exercising a real HTTP client instead of the simulated delay.
+func weatherExternalUpstream(ctx *fasthttp.RequestCtx) {
+	start := time.Now()
+
+	data, err := weatherUpstream.Fetch(ctx)
+	if err != nil {
+		writeError(ctx, http.StatusBadGateway, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(ctx, http.StatusOK, map[string]interface{}{
+		"endpoint":     "external_api",
+		"framework":    "fasthttp",
+		"data":         data,
+		"upstream_url": weatherUpstream.URL,
+		"elapsed_ms":   elapsedMs,
+	})
+}
+
+func weatherFetch(ctx *fasthttp.RequestCtx) {
+	if list := string(ctx.QueryArgs().Peek("cities")); list != "" {
+		weatherFetchMany(ctx, list)
+		return
+	}
+
+	city := string(ctx.QueryArgs().Peek("city"))
+	if city == "" {
+		city = "Colombo"
+	}
+	start := time.Now()
+
+	weatherData, source, coalesced := weatherFetcher.Fetch(ctx, city)
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(ctx, http.StatusOK, map[string]interface{}{
+		"endpoint":   "weather_fetch",
+		"framework":  "fasthttp",
+		"city":       city,
+		"data":       weatherData,
+		"source":     source,
+		"coalesced":  coalesced,
+		"elapsed_ms": elapsedMs,
+	})
+}
+
+// weatherFetchMany looks up every city of a comma-separated list
+// concurrently, at most parallelism at a time, and reports each city next to
+// the total elapsed time.
+func weatherFetchMany(ctx *fasthttp.RequestCtx, list string) {
+	cities, err := weather.ParseCities(list)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	parallelism, err := clampedIntParam(ctx, "parallelism", weather.DefaultParallelism, 1, weather.MaxCities)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	start := time.Now()
+
+	reports := weatherFetcher.FetchMany(ctx, cities, parallelism)
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(ctx, http.StatusOK, map[string]interface{}{
+		"endpoint":    "weather_fetch",
+		"framework":   "fasthttp",
+		"cities":      reports,
+		"count":       len(reports),
+		"parallelism": parallelism,
+		"elapsed_ms":  elapsedMs,
+	})
+}
+
+// fileIO writes a temp file, fsyncs it and reads it back, the disk
+// counterpart to the HTTP and database I/O endpoints.
+func fileIO(ctx *fasthttp.RequestCtx) {
+	size, err := clampedIntParam(ctx, "bytes", diskio.DefaultBytes, 1, diskio.MaxBytes)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	result, err := diskio.RoundTrip(requestContext(ctx), size)
+	if err != nil {
+		writeError(ctx, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(ctx, http.StatusOK, map[string]interface{}{
+		"endpoint":      "file_io",
+		"framework":     "fasthttp",
+		"bytes_written": result.BytesWritten,
+		"bytes_read":    result.BytesRead,
+		"sha256":        result.SHA256,
+		"elapsed_ms":    result.ElapsedMs,
+		"write_us":      result.WriteUs,
+		"fsync_us":      result.FsyncUs,
+		"read_us":       result.ReadUs,
+	})
+}
+
+// benchJSON builds a nested payload of the requested depth and width and
+// serializes it with the configured encoder, reporting the encoded size and
+// marshalling time. Only the summary is sent, so the measurement is pure
+// serialization with no network write of the payload itself.
+func benchJSON(ctx *fasthttp.RequestCtx) {
+	depth, err := clampedIntParam(ctx, "depth", 3, 0, jsonenc.MaxPayloadDepth)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	width, err := clampedIntParam(ctx, "width", 10, 1, jsonenc.MaxPayloadWidth)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	shape := string(ctx.QueryArgs().Peek("shape"))
+	if shape == "" {
+		shape = jsonenc.ShapeStruct
+	}
+
+	payload, nodes, err := jsonenc.BuildPayload(depth, width, shape)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	result, err := jsonenc.MarshalTimed(encoder, payload)
+	if err != nil {
+		writeError(ctx, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(ctx, http.StatusOK, map[string]interface{}{
+		"endpoint":   "json_bench",
+		"framework":  "fasthttp",
+		"encoder":    encoder.Name(),
+		"shape":      shape,
+		"depth":      depth,
+		"width":      width,
+		"nodes":      nodes,
+		"bytes":      result.Bytes,
+		"marshal_us": result.MarshalUs,
+	})
+}
+
+func getUsers(ctx *fasthttp.RequestCtx) {
+	if !requireDB(ctx) {
+		return
+	}
+
+	limit, err := clampedIntParam(ctx, "limit", 100, 1, 1000)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	offset, err := clampedIntParam(ctx, "offset", 0, 0, math.MaxInt32)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	repeat, err := clampedIntParam(ctx, "repeat", 1, 1, store.MaxQueryRepeat)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// The extra runs go first, so the page served is the last one read
+	start := time.Now()
+	if err := repeatUsers(requestContext(ctx), limit, offset, repeat-1); err != nil {
+		writeError(ctx, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	var rows *sql.Rows
+	if selectUsersStmt != nil {
+		rows, err = selectUsersStmt.QueryContext(requestContext(ctx), limit, offset)
+	} else {
+		rows, err = db.QueryContext(requestContext(ctx), store.SelectUsersQuery, limit, offset)
+	}
+	if err != nil {
+		writeError(ctx, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	if csvstream.Requested(string(ctx.QueryArgs().Peek("format")), string(ctx.Request.Header.Peek("Accept"))) {
+		writeUsersCSV(ctx, rows)
+		return
+	}
+
+	users := make([]User, 0, limit)
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			continue
+		}
+		users = append(users, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		writeError(ctx, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	queryMs := float64(time.Since(start).Nanoseconds()) / 1e6
+
+	resp := map[string]interface{}{
+		"users":    users,
+		"count":    len(users),
+		"limit":    limit,
+		"offset":   offset,
+		"prepared": selectUsersStmt != nil,
+	}
+	if repeat > 1 {
+		resp["repeat"] = repeat
+		resp["query_ms"] = queryMs
+	}
+	respondJSON(ctx, http.StatusOK, resp)
+}
+
+// repeatUsers reads the same page of users times more and discards it, so
+// ?repeat= can weight a request towards the database.
+func repeatUsers(ctx context.Context, limit, offset, times int) error {
+	for i := 0; i < times; i++ {
+		var rows *sql.Rows
+		var err error
+		if selectUsersStmt != nil {
+			rows, err = selectUsersStmt.QueryContext(ctx, limit, offset)
+		} else {
+			rows, err = db.QueryContext(ctx, store.SelectUsersQuery, limit, offset)
+		}
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var u User
+			rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeUsersCSV writes rows as a CSV attachment, each user encoded as it is
+// read. fasthttp buffers the body until the handler returns, so unlike the
+// net/http apps a query failing mid-stream still becomes a 500.
+func writeUsersCSV(ctx *fasthttp.RequestCtx, rows *sql.Rows) {
+	ctx.SetContentType(csvstream.ContentType + "; charset=utf-8")
+	ctx.Response.Header.Set("Content-Disposition", csvstream.Disposition(csvstream.UsersFilename))
+	ctx.Response.Header.Add("Vary", "Accept")
+	out, err := csvstream.NewUsers(ctx)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			continue
+		}
+		out.Write(u.ID, u.Name, u.Email, u.CreatedAt)
+	}
+	out.Flush()
+
+	if err := rows.Err(); err != nil {
+		ctx.ResetBody()
+		ctx.Response.Header.Del("Content-Disposition")
+		writeError(ctx, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+	}
+}
+
+func createUser(ctx *fasthttp.RequestCtx) {
+	if !requireDB(ctx) {
+		return
+	}
+
+	var input struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(ctx.PostBody())).Decode(&input); err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	var row *sql.Row
+	if insertUserStmt != nil {
+		row = insertUserStmt.QueryRowContext(requestContext(ctx), input.Name, input.Email)
+	} else {
+		row = db.QueryRowContext(requestContext(ctx), store.InsertUserQuery, input.Name, input.Email)
+	}
+
+	var user User
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+
+	if err != nil {
+		writeError(ctx, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(ctx, http.StatusCreated, createdUser{User: user, Prepared: insertUserStmt != nil})
+}
+
+// bulkCreateUsers inserts up to store.MaxBulkUsers users with a single
+// multi-row statement, rolling back the whole batch on any constraint
+// violation.
+func bulkCreateUsers(ctx *fasthttp.RequestCtx) {
+	if !requireDB(ctx) {
+		return
+	}
+
+	var input []store.NewUser
+	if err := json.NewDecoder(bytes.NewReader(ctx.PostBody())).Decode(&input); err != nil {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	if len(input) == 0 || len(input) > store.MaxBulkUsers {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{
+			Message: fmt.Sprintf("bulk insert must contain between 1 and %d users", store.MaxBulkUsers),
+		})
+		return
+	}
+
+	if i := store.DuplicateEmailIndex(input); i >= 0 {
+		writeError(ctx, http.StatusBadRequest, api.ErrorResponse{Message: "duplicate email in request", Details: map[string]int{"index": i}})
+		return
+	}
+
+	users, err := insertUsers(requestContext(ctx), input)
+	if err != nil {
+		if index, ok := store.ConstraintViolation(err, input); ok {
+			resp := api.ErrorResponse{Message: err.Error()}
+			if index >= 0 {
+				resp.Details = map[string]int{"index": index}
+			}
+			writeError(ctx, http.StatusBadRequest, resp)
+			return
+		}
+		writeError(ctx, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(ctx, http.StatusCreated, map[string]interface{}{
+		"users": users,
+		"count": len(users),
+	})
+}
+
+// dbStats reports the connection pool's state and cumulative counters, to
+// show whether requests are waiting on the pool during the DB benchmark.
+func dbStats(ctx *fasthttp.RequestCtx) {
+	if !requireDB(ctx) {
+		return
+	}
+	respondJSON(ctx, http.StatusOK, map[string]interface{}{
+		"endpoint":  "db_stats",
+		"framework": "fasthttp",
+		"pool":      store.Pool(db),
+	})
+}
+
+// insertUsers runs the bulk INSERT inside a transaction and returns the
+// created rows. Any error rolls the transaction back.
+func insertUsers(ctx context.Context, input []store.NewUser) ([]User, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query, args := store.BulkInsertUsersQuery(input)
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]User, 0, len(input))
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// requireDB writes a 503 and returns false when the database never connected.
+func requireDB(ctx *fasthttp.RequestCtx) bool {
+	if !dbReady {
+		writeError(ctx, http.StatusServiceUnavailable, api.ErrorResponse{Message: "database unavailable"})
+		return false
+	}
+	return true
+}
+
+func parseIntParam(ctx *fasthttp.RequestCtx, param string, defaultValue int) int {
+	if val := ctx.QueryArgs().Peek(param); len(val) > 0 {
+		if intVal, err := strconv.Atoi(string(val)); err == nil {
+			return intVal
+		}
+	}
+	return defaultValue
+}
+
+// computeParams reads kernel, size, iterations, goroutines and seed from the
+// query string, applying the endpoint's defaults and the kernel's bounds.
+func computeParams(ctx *fasthttp.RequestCtx, defaultSize, defaultIterations int) (compute.Params, error) {
+	kernel, err := compute.ParseKernel(string(ctx.QueryArgs().Peek("kernel")))
+	if err != nil {
+		return compute.Params{}, err
+	}
+
+	size, err := clampedIntParam(ctx, "size", kernel.DefaultSize(defaultSize), 1, kernel.MaxSize())
+	if err != nil {
+		return compute.Params{}, err
+	}
+	iterations, err := clampedIntParam(ctx, "iterations", defaultIterations, 1, compute.MaxIterations)
+	if err != nil {
+		return compute.Params{}, err
+	}
+	goroutines, err := clampedIntParam(ctx, "goroutines", 1, 1, compute.MaxGoroutines)
+	if err != nil {
+		return compute.Params{}, err
+	}
+	seed, err := clampedIntParam(ctx, "seed", 0, 0, compute.MaxSeed)
+	if err != nil {
+		return compute.Params{}, err
+	}
+
+	return compute.Params{Kernel: kernel, Size: size, Iterations: iterations, Goroutines: goroutines, Seed: seed}, nil
+}
+
+// memoryParams reads the object count and size for analyticsMemory.
+func memoryParams(ctx *fasthttp.RequestCtx) (compute.MemoryParams, error) {
+	objects, err := clampedIntParam(ctx, "objects", 10000, 1, compute.MaxMemoryObjects)
+	if err != nil {
+		return compute.MemoryParams{}, err
+	}
+	size, err := clampedIntParam(ctx, "size", 1024, 1, compute.MaxMemoryObjectSize)
+	if err != nil {
+		return compute.MemoryParams{}, err
+	}
+
+	p := compute.MemoryParams{Objects: objects, Size: size}
+	if err := p.Validate(); err != nil {
+		return compute.MemoryParams{}, err
+	}
+	return p, nil
+}
+
+// heavyParams reads the heavy job from a JSON body on POST requests sent as
+// application/json, and from the query string otherwise. Both paths share
+// the defaults and bounds.
+func heavyParams(ctx *fasthttp.RequestCtx) (compute.Params, error) {
+	if !ctx.IsPost() || !params.IsJSON(string(ctx.Request.Header.ContentType())) {
+		return computeParams(ctx, 5000, 5)
+	}
+	return compute.DecodeParams(bytes.NewReader(ctx.PostBody()), compute.Params{Size: 5000, Iterations: 5})
+}
+
+// clampedIntParam is parseIntParam with bounds: out-of-range values are
+// rejected rather than handed to the workload.
+func clampedIntParam(ctx *fasthttp.RequestCtx, param string, defaultValue, minValue, maxValue int) (int, error) {
+	return params.ClampedInt(param, string(ctx.QueryArgs().Peek(param)), defaultValue, minValue, maxValue)
+}
+
+// padParam reads pad_bytes and returns that much filler for the response's
+// padding field, or "" for none.
+func padParam(ctx *fasthttp.RequestCtx) (string, error) {
+	n, err := clampedIntParam(ctx, "pad_bytes", 0, 0, filler.Max())
+	if err != nil {
+		return "", err
+	}
+	return filler.Pad(n), nil
+}
+
+// boolParam reads a boolean query flag; see params.Bool.
+func boolParam(ctx *fasthttp.RequestCtx, param string) bool {
+	return params.Bool(string(ctx.QueryArgs().Peek(param)))
+}
+
+// notModified tags the response with etag and, when the request's
+// If-None-Match already names that tag, answers 304 with no body and returns
+// true. The 304 varies on Accept like the response it stands in for.
+func notModified(ctx *fasthttp.RequestCtx, etag string) bool {
+	// api.SetETag, for fasthttp's header type
+	ctx.Response.Header.Set("ETag", etag)
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	if !compute.MatchesETag(string(ctx.Request.Header.Peek("If-None-Match")), etag) {
+		return false
+	}
+	ctx.Response.Header.Add("Vary", "Accept")
+	ctx.SetStatusCode(fasthttp.StatusNotModified)
+	return true
+}
+
+// writeError sends an error in the api.ErrorResponse envelope shared by
+// every framework, its code defaulting to one derived from status.
+func writeError(ctx *fasthttp.RequestCtx, status int, e api.ErrorResponse) {
+	respondJSON(ctx, status, e.ForStatus(status))
+}
+
+// respondJSON sends data in the format the Accept header prefers, JSON
+// unless MessagePack is asked for, and a 406 when it accepts neither.
+func respondJSON(ctx *fasthttp.RequestCtx, status int, data interface{}) {
+	mediaType, ok := negotiate.Select(string(ctx.Request.Header.Peek("Accept")), negotiate.Formats...)
+	if !ok {
+		status, mediaType, data = fasthttp.StatusNotAcceptable, negotiate.JSON, negotiate.NotAcceptable()
+	}
+	ctx.Response.Header.Add("Vary", "Accept")
+	ctx.SetContentType(mediaType)
+	ctx.SetStatusCode(status)
+	negotiate.Encoder(mediaType, encoder).Marshal(ctx, data)
+}
+
+// respondCompute sends a heavy or medium result like respondJSON, or as its
+// protobuf message msg when the Accept header prefers Protocol Buffers.
+func respondCompute(ctx *fasthttp.RequestCtx, resp map[string]interface{}, msg *computepb.ComputeResult) {
+	mediaType, _ := negotiate.Select(string(ctx.Request.Header.Peek("Accept")), negotiate.ComputeFormats...)
+	if mediaType != negotiate.Protobuf {
+		respondJSON(ctx, http.StatusOK, resp)
+		return
+	}
+	ctx.Response.Header.Add("Vary", "Accept")
+	ctx.SetContentType(mediaType)
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	negotiate.Proto.Marshal(ctx, msg)
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value := os.Getenv(key); value != "" {
+		if intVal, err := strconv.Atoi(value); err == nil {
+			return intVal
+		}
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"carbon-bench/apitest"
+	"carbon-bench/config"
+	"carbon-bench/jsonenc"
+	"carbon-bench/padding"
+	"carbon-bench/weather"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMain(m *testing.M) {
+	var err error
+	if encoder, err = jsonenc.New(jsonenc.Stdlib); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// netHTTP runs a fasthttp handler behind http.Handler, so the shared apitest
+// checks can drive it. Each request gets a fresh RequestCtx whose response
+// is copied out once the handler returns.
+type netHTTP fasthttp.RequestHandler
+
+func (h netHTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req fasthttp.Request
+	req.Header.SetMethod(r.Method)
+	req.SetRequestURI(r.URL.RequestURI())
+	for name, values := range r.Header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	body, _ := io.ReadAll(r.Body)
+	req.SetBody(body)
+
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Init(&req, nil, nil)
+	h(ctx)
+
+	ctx.Response.Header.VisitAll(func(name, value []byte) {
+		w.Header().Add(string(name), string(value))
+	})
+	w.WriteHeader(ctx.Response.StatusCode())
+	w.Write(ctx.Response.Body())
+}
+
+// testHandler builds the handler main would with authentication off, with
+// the handlers' dependencies set up and nothing reaching the network. The
+// database is never opened, so its routes answer 503.
+func testHandler(t *testing.T) http.Handler {
+	t.Helper()
+	t.Setenv("BENCHMARK_MODE", "true")
+	requestTimeout = 10 * time.Second
+	filler = padding.New(0)
+	weatherFetcher = weather.NewOpenMeteo(0, time.Second)
+	weatherFetcher.GeocodeURL = "http://127.0.0.1:1/search"
+	weatherFetcher.ForecastURL = "http://127.0.0.1:1/forecast"
+	return netHTTP(newHandler(config.AuthConfig{}))
+}
+
+func TestBaselineHeadersOnEveryRoute(t *testing.T) {
+	apitest.CheckBaselineHeaders(t, testHandler(t), "fasthttp")
+}
+
+func TestConditionalCompute(t *testing.T) {
+	apitest.CheckConditional(t, testHandler(t))
+}
+
+func TestUnroutedRequestsAnswerJSON(t *testing.T) {
+	apitest.CheckUnrouted(t, testHandler(t))
+}
+
+func TestPanicRecoveredAsJSON(t *testing.T) {
+	handler := testHandler(t)
+	// Without its fetcher the weather route dereferences nil, standing in
+	// for any handler bug
+	weatherFetcher = nil
+	w := apitest.Serve(handler, http.MethodGet, "/api/v1/weather/fetch?city=Colombo", "")
+	apitest.CheckError(t, w, http.StatusInternalServerError, "internal_server_error")
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"carbon-bench/api"
+	"carbon-bench/jwtauth"
+	"github.com/valyala/fasthttp"
+)
+
+// requestContextKey holds the deadline-bound context set by timeoutMiddleware.
+type requestContextKey struct{}
+
+// loggerMiddleware logs one line per request, like chi's middleware.Logger.
+func loggerMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		next(ctx)
+
+		// Body() would drain a streamed body into memory, so streams log no size
+		size := "-"
+		if !ctx.Response.IsBodyStream() {
+			size = strconv.Itoa(len(ctx.Response.Body())) + "B"
+		}
+		log.Printf("%q from %s - %d %s in %s",
+			string(ctx.Method())+" "+string(ctx.RequestURI()),
+			ctx.RemoteAddr(), ctx.Response.StatusCode(), size, time.Since(start))
+	}
+}
+
+// inflightMiddleware counts the request as in flight until the wrapped
+// handler has returned. A streamed body is written after that, so SSE
+// streams only count while they are being set up.
+func inflightMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		requests.Start()
+		defer requests.Done()
+		next(ctx)
+	}
+}
+
+// headersMiddleware sets the baseline response headers shared by every
+// framework; fasthttp has its own header type, so these mirror
+// api.SetBaselineHeaders. They are added after the handler runs because
+// ctx.Error resets the response headers, and fasthttp only writes the
+// response once the handler has returned. Headers a handler set itself, such
+// as the SSE Cache-Control, are kept.
+func headersMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		next(ctx)
+
+		for _, kv := range [...][2]string{
+			{"Cache-Control", "no-store"},
+			{"X-Content-Type-Options", "nosniff"},
+			{"X-Framework", "fasthttp"},
+		} {
+			if len(ctx.Response.Header.Peek(kv[0])) == 0 {
+				ctx.Response.Header.Set(kv[0], kv[1])
+			}
+		}
+	}
+}
+
+// authMiddleware answers 401 to requests without a valid bearer token,
+// except those jwtauth.Exempt lets through. It wraps the router, so
+// unmatched routes need a token too.
+func authMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if jwtauth.Exempt(string(ctx.Path())) {
+			next(ctx)
+			return
+		}
+		if err := authVerifier.Check(string(ctx.Request.Header.Peek("Authorization"))); err != nil {
+			ctx.Response.Header.Set("WWW-Authenticate", jwtauth.Challenge)
+			writeError(ctx, http.StatusUnauthorized, api.ErrorResponse{Message: err.Error()})
+			return
+		}
+		next(ctx)
+	}
+}
+
+// recoverMiddleware turns a handler panic into a 500. Unlike net/http,
+// fasthttp doesn't recover panics, so one bad request would kill the server.
+func recoverMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Printf("panic: %v", rec)
+				ctx.ResetBody()
+				writeError(ctx, http.StatusInternalServerError, api.ErrorResponse{Message: "internal server error"})
+			}
+		}()
+		next(ctx)
+	}
+}
+
+// timeoutMiddleware bounds the request context so long-running compute and
+// database queries observe the deadline and bail out early. Handlers pick it
+// up through requestContext.
+func timeoutMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+
+		ctx.SetUserValue(requestContextKey{}, reqCtx)
+		next(ctx)
+	}
+}
+
+// requestContext returns the context set by timeoutMiddleware, or the
+// RequestCtx itself on routes without a deadline.
+func requestContext(ctx *fasthttp.RequestCtx) context.Context {
+	if reqCtx, ok := ctx.UserValue(requestContextKey{}).(context.Context); ok {
+		return reqCtx
+	}
+	return ctx
+}
+
+// sseWriter adapts a fasthttp body stream to the http.ResponseWriter the sse
+// package writes to. A failed flush means the client disconnected, so it
+// cancels the stream's compute.
+type sseWriter struct {
+	*bufio.Writer
+	cancel context.CancelFunc
+	header http.Header
+}
+
+// Header is unused: the stream's headers are already on the fasthttp response.
+func (w *sseWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *sseWriter) WriteHeader(int) {}
+
+func (w *sseWriter) Flush() {
+	if err := w.Writer.Flush(); err != nil {
+		w.cancel()
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+
+	"carbon-bench/api"
+
+	"github.com/valyala/fasthttp"
+)
+
+// router dispatches on exact path and method. fasthttp ships no router of its
+// own, and the benchmark routes are all static, so a map lookup is enough and
+// keeps the routing cost out of the measurement.
+type router struct {
+	routes map[string]map[string]fasthttp.RequestHandler
+}
+
+func newRouter() *router {
+	return &router{routes: make(map[string]map[string]fasthttp.RequestHandler)}
+}
+
+func (rt *router) Get(path string, h fasthttp.RequestHandler) {
+	rt.handle(http.MethodGet, path, h)
+}
+
+func (rt *router) Post(path string, h fasthttp.RequestHandler) {
+	rt.handle(http.MethodPost, path, h)
+}
+
+func (rt *router) Delete(path string, h fasthttp.RequestHandler) {
+	rt.handle(http.MethodDelete, path, h)
+}
+
+func (rt *router) handle(method, path string, h fasthttp.RequestHandler) {
+	if rt.routes[path] == nil {
+		rt.routes[path] = make(map[string]fasthttp.RequestHandler)
+	}
+	rt.routes[path][method] = h
+}
+
+// Handler answers unknown paths with 404 and known paths with an
+// unregistered method with 405, matching net/http routers, in the error
+// envelope every framework uses.
+func (rt *router) Handler(ctx *fasthttp.RequestCtx) {
+	methods, ok := rt.routes[string(ctx.Path())]
+	if !ok {
+		writeError(ctx, http.StatusNotFound, api.ErrorResponse{Message: "not found"})
+		return
+	}
+
+	h, ok := methods[string(ctx.Method())]
+	if !ok {
+		writeError(ctx, http.StatusMethodNotAllowed, api.ErrorResponse{Message: "method not allowed"})
+		return
+	}
+
+	h(ctx)
+}
+
+// Routes returns every registered method and path pair.
+func (rt *router) Routes() []api.Route {
+	var routes []api.Route
+	for path, methods := range rt.routes {
+		for method := range methods {
+			routes = append(routes, api.Route{Method: method, Path: path})
+		}
+	}
+	return routes
+}
@@ -0,0 +1,58 @@
+// Package faultinject decides which requests the framework apps' fault
+// injection middleware fails or delays, for benchmarking how clients and
+// frameworks behave under errors.
+package faultinject
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Injector draws per-request faults from a single seeded PRNG. The sequence
+// of decisions is fixed by the seed, so a sequential run reproduces exactly;
+// under concurrency the same faults occur in the same proportions, but which
+// request gets which depends on arrival order.
+type Injector struct {
+	failRate    float64
+	latencyRate float64
+	maxLatency  time.Duration
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// New returns an injector failing failRate of requests and delaying
+// latencyRate of them by a uniform random duration up to maxLatency. It
+// returns nil when both failRate and maxLatency are zero; a nil *Injector
+// injects nothing, so callers can skip the middleware entirely.
+func New(failRate float64, maxLatency time.Duration, latencyRate float64, seed int64) *Injector {
+	if failRate <= 0 && maxLatency <= 0 {
+		return nil
+	}
+	return &Injector{
+		failRate:    failRate,
+		latencyRate: latencyRate,
+		maxLatency:  maxLatency,
+		rng:         rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Next returns the faults for the next request: how long to delay it, and
+// whether to fail it.
+func (in *Injector) Next() (delay time.Duration, fail bool) {
+	if in == nil {
+		return 0, false
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	// Always draw the same number of values so one setting doesn't shift
+	// the other's sequence
+	delayRoll, delayFrac, failRoll := in.rng.Float64(), in.rng.Float64(), in.rng.Float64()
+	if in.maxLatency > 0 && delayRoll < in.latencyRate {
+		delay = time.Duration(delayFrac * float64(in.maxLatency))
+	}
+	return delay, failRoll < in.failRate
+}
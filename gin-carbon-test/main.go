@@ -1,23 +1,114 @@
 package main
 
 import (
-	"crypto/sha256"
+	"context"
 	"database/sql"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
+	"carbon-bench/api"
+	"carbon-bench/carbon"
+	"carbon-bench/clientstats"
+	"carbon-bench/compute"
+	"carbon-bench/computepb"
+	"carbon-bench/config"
+	"carbon-bench/csvstream"
+	"carbon-bench/diskio"
+	"carbon-bench/faultinject"
+	"carbon-bench/health"
+	"carbon-bench/idempotency"
+	"carbon-bench/inflight"
+	"carbon-bench/jsonenc"
+	"carbon-bench/jwtauth"
+	"carbon-bench/memdb"
+	"carbon-bench/ndjson"
+	"carbon-bench/negotiate"
+	"carbon-bench/padding"
+	"carbon-bench/params"
+	"carbon-bench/ratelimit"
+	"carbon-bench/selfcheck"
+	"carbon-bench/sse"
+	"carbon-bench/statslog"
+	"carbon-bench/store"
+	"carbon-bench/tracing"
+	"carbon-bench/trailer"
+	"carbon-bench/unixsock"
+	"carbon-bench/weather"
+	"carbon-bench/wsecho"
 	"github.com/gin-gonic/gin"
+	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 var (
 	startTime time.Time
 	db        *sql.DB
+	dbReady   bool
+	// dialect is the SQL syntax of the connected database
+	dialect = store.Postgres
+	// dbDriver names the connected database for tracing; the in-memory
+	// fallback speaks the Postgres dialect but is SQLite underneath
+	dbDriver = config.DriverSQLite
+
+	// Prepared once at startup unless DB_PREPARED_STATEMENTS=false
+	selectUsersStmt *sql.Stmt
+	insertUserStmt  *sql.Stmt
+	encoder         jsonenc.Encoder
+
+	// dbLatency is DB_SIMULATED_LATENCY_MS, slept by getUsers and createUser
+	dbLatency time.Duration
+
+	// weatherUpstream is set when WEATHER_UPSTREAM_URL is configured
+	weatherUpstream *weather.Upstream
+	weatherFetcher  *weather.OpenMeteo
+
+	wsServer *wsecho.Server
+
+	// requests counts in-flight requests and their peak for /api/v1/metrics
+	requests inflight.Gauge
+
+	// apiPrefix is API_PREFIX, the path every route is served under
+	apiPrefix string
+
+	// enableTrailers is ENABLE_TRAILERS: streams end with timing trailers
+	enableTrailers bool
+
+	// filler pads analytics responses by pad_bytes, up to MAX_PAD_BYTES
+	filler *padding.Filler
+
+	// healthDegradedAfter is HEALTH_DEGRADED_MS, the dependency latency
+	// /api/v1/health/detailed reports as degraded
+	healthDegradedAfter = health.DefaultDegradedAfter
+
+	// computeCache is set when ENABLE_COMPUTE_CACHE=true
+	computeCache *compute.Cache
+	// heavySem bounds concurrent heavy computations
+	heavySem *compute.Semaphore
+	// jobQueue runs /compute/async jobs on ASYNC_WORKERS workers
+	jobQueue *compute.JobQueue
+	// clientCounter is set unless ENABLE_CLIENT_STATS=false
+	clientCounter *clientstats.Counter
+	trustProxy    bool
+
+	// authVerifier is set when JWT_SECRET is, and mints and checks tokens
+	authVerifier *jwtauth.Verifier
 )
 
 type User struct {
@@ -27,266 +118,1905 @@ type User struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
-type ComputeResult struct {
-	ResultHash string `json:"result_hash"`
-	TotalSum   int64  `json:"total_sum"`
-	MatrixSize int    `json:"matrix_size"`
-	Iterations int    `json:"iterations"`
-	ElapsedMs  int64  `json:"elapsed_ms"`
+// createdUser is the createUser response: the user's fields plus whether the
+// prepared-statement path served the insert.
+type createdUser struct {
+	User
+	Prepared             bool  `json:"prepared"`
+	SimulatedDBLatencyMs int64 `json:"simulated_db_latency_ms,omitempty"`
+	Retries              int   `json:"retries,omitempty"`
+}
+
+// fetchedUser is the getUser response: the user's fields plus how many
+// times a transient DB error was retried.
+type fetchedUser struct {
+	User
+	Retries int `json:"retries,omitempty"`
+}
+
+// computeJobStatus is the getComputeJob response.
+type computeJobStatus struct {
+	Endpoint  string `json:"endpoint"`
+	Framework string `json:"framework"`
+	compute.Job
 }
 
 func main() {
 	startTime = time.Now()
 
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	config.SetMaxProcs(cfg.MaxProcs)
+
+	// Response JSON encoder (JSON_ENCODER=stdlib|jsoniter)
+	encoder, err = jsonenc.New(cfg.JSONEncoder)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	log.Printf("✓ JSON encoder: %s", encoder.Name())
+
+	// Distributed tracing, only when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.OTLPEndpoint, "gin-carbon-test")
+	if err != nil {
+		log.Fatalf("Tracing setup failed: %v", err)
+	}
+	if tracing.Enabled() {
+		log.Printf("✓ Tracing: exporting to %s", cfg.OTLPEndpoint)
+	}
+
 	// Initialize database
-	initDB()
-	defer db.Close()
+	initDB(cfg.DB)
+
+	// Resource snapshots in the log every STATS_INTERVAL_SECONDS
+	stopStats := statslog.Start(cfg.StatsInterval, db)
+	if cfg.StatsInterval > 0 {
+		log.Printf("✓ Stats logging every %s", cfg.StatsInterval)
+	}
+
+	heavySem = compute.NewSemaphore(cfg.MaxConcurrentHeavy, cfg.HeavyQueueTimeout)
+	jobQueue = compute.NewJobQueue(cfg.AsyncWorkers, cfg.AsyncQueueDepth)
+
+	if cfg.ComputeCache {
+		computeCache = compute.NewCache(cfg.ComputeCacheSize, cfg.ComputeCacheTTL)
+		log.Printf("✓ Compute cache: %d entries, TTL %s", cfg.ComputeCacheSize, cfg.ComputeCacheTTL)
+	}
+
+	// Weather upstreams: Open-Meteo for fetch, optional real upstream for external
+	weatherFetcher = weather.NewOpenMeteo(cfg.WeatherCacheTTL, cfg.WeatherUpstreamTimeout)
+	if cfg.WeatherUpstreamURL != "" {
+		weatherUpstream = weather.NewUpstream(cfg.WeatherUpstreamURL, cfg.WeatherUpstreamTimeout)
+		log.Printf("✓ Weather upstream: %s (timeout %s)", cfg.WeatherUpstreamURL, cfg.WeatherUpstreamTimeout)
+	}
+	if tracing.Enabled() {
+		weatherFetcher.Client.Transport = tracing.Transport(http.DefaultTransport)
+		if weatherUpstream != nil {
+			weatherUpstream.Client.Transport = tracing.Transport(http.DefaultTransport)
+		}
+	}
+
+	// WebSocket echo server for the streaming workload
+	wsServer = wsecho.New(cfg.WSMaxConnections)
+
+	healthDegradedAfter = cfg.HealthDegradedAfter
+
+	// Filler for pad_bytes, generated once up to MAX_PAD_BYTES
+	filler = padding.New(cfg.MaxPadBytes)
+
+	enableTrailers = cfg.Trailers
+	if enableTrailers {
+		log.Printf("✓ Stream trailers: %s", trailer.Declaration)
+	}
+
+	// Carbon estimation model
+	carbon.Configure(cfg.CPUWattsPerCore, cfg.GridIntensity.Static)
+	if cfg.GridIntensity.Live() {
+		carbon.SetProvider(cfg.GridIntensity.Provider())
+		log.Printf("✓ Grid intensity: %s", cfg.GridIntensity)
+	}
 
 	// Set Gin to release mode for production
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
+	engine := newEngine(cfg)
+
+	// Cleartext HTTP/2 is opt-in; clients that don't upgrade still get HTTP/1.1
+	var handler http.Handler = engine
+	protocol := "HTTP/1.1"
+	switch {
+	case cfg.TLS.Enabled:
+		// net/http negotiates HTTP/2 over TLS by itself
+		protocol = "TLS, HTTP/1.1 + h2"
+	case cfg.EnableH2C:
+		handler = h2c.NewHandler(handler, &http2.Server{})
+		protocol = "HTTP/1.1 + h2c"
+	}
+
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Handler: handler,
+	}
+	cfg.ServerTimeouts.Apply(srv)
+	log.Printf("✓ Server timeouts: %s", cfg.ServerTimeouts)
+
+	// LISTEN_UNIX_SOCKET replaces the TCP port entirely
+	network, address := "tcp", srv.Addr
+	var ln net.Listener
+	if cfg.UnixSocket != "" {
+		var err error
+		if ln, err = unixsock.Listen(cfg.UnixSocket); err != nil {
+			log.Fatalf("Failed to listen on %s: %v", cfg.UnixSocket, err)
+		}
+		network, address = "unix", cfg.UnixSocket
+	}
+
+	go func() {
+		log.Printf("🚀 Gin server starting on %s %s (%s)", network, address, protocol)
+		var err error
+		switch {
+		case ln != nil:
+			err = srv.Serve(ln)
+		case cfg.TLS.Enabled:
+			err = srv.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		default:
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	// SELF_CHECK requests every GET endpoint against the live server and
+	// exits nonzero if any of them doesn't answer 200
+	if cfg.SelfCheck {
+		go func() {
+			target := selfcheck.Target{Network: network, Address: address, TLS: cfg.TLS.Enabled, Prefix: apiPrefix}
+			if cfg.Auth.Required {
+				token, err := authVerifier.Mint(jwtauth.DefaultSubject, time.Hour)
+				if err != nil {
+					log.Fatalf("Self-check token: %v", err)
+				}
+				target.Token = token.AccessToken
+			}
+			if err := selfcheck.Run(target); err != nil {
+				log.Printf("✗ Self-check failed: %v", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	shutdown(quit, cfg, srv, shutdownTracing, stopStats)
+}
+
+// shutdown waits for SIGINT/SIGTERM on quit, then stops srv accepting and
+// drains in-flight requests, flushes metrics and traces, and only then closes
+// the DB so nothing recorded by the last requests is lost.
+func shutdown(quit <-chan os.Signal, cfg config.Config, srv *http.Server, shutdownTracing func(context.Context) error, stopStats func()) {
+	<-quit
+
+	log.Printf("Shutting down server (timeout %s)...", cfg.ShutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("⚠️  Server shutdown warning: %v", err)
+	}
+	// Abandon async jobs nobody can poll for any more
+	jobQueue.Close()
+	if cfg.PushgatewayURL != "" {
+		err := push.New(cfg.PushgatewayURL, "gin-carbon-test").
+			Gatherer(prometheus.DefaultGatherer).
+			PushContext(ctx)
+		if err != nil {
+			log.Printf("⚠️  Metrics push warning: %v", err)
+		} else {
+			log.Printf("✓ Metrics pushed to %s", cfg.PushgatewayURL)
+		}
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("⚠️  Tracing shutdown warning: %v", err)
+	}
+
+	// Stop logging snapshots before the pool they read is closed
+	stopStats()
+
+	if db != nil {
+		if err := db.Close(); err != nil {
+			log.Printf("⚠️  Database close warning: %v", err)
+		}
+	}
+	log.Println("✓ Server stopped")
+}
+
+// newEngine builds the engine: the middleware stack cfg asks for, then every
+// route under API_PREFIX.
+func newEngine(cfg config.Config) *gin.Engine {
+	engine := gin.New()
+	trustProxy = cfg.TrustProxy
+	authVerifier = cfg.Auth.Verifier()
+	if cfg.NoMiddleware {
+		log.Println("⚠️  No middleware: routes only, without logging, recovery, metrics or body limits")
+	} else {
+		useMiddleware(engine, cfg)
+	}
+
+	// Unknown paths and wrong methods answer in the error envelope too,
+	// rather than gin's plain-text 404
+	engine.HandleMethodNotAllowed = true
+	engine.NoRoute(notFound)
+	engine.NoMethod(methodNotAllowed)
+
+	// Every route hangs off API_PREFIX, empty unless instances share a
+	// reverse proxy that routes by path
+	r := engine.Group(cfg.APIPrefix)
+	apiPrefix = cfg.APIPrefix
+	if apiPrefix != "" {
+		log.Printf("✓ API prefix: %s", apiPrefix)
+	}
 
 	// Root endpoint
 	r.GET("/", rootHandler)
 
-	// Health check
+	// Liveness and readiness probes
 	r.GET("/api/v1/health", healthHandler)
+	r.GET("/api/v1/health/detailed", healthDetailedHandler)
+	r.GET("/api/v1/version", versionHandler)
+	r.GET("/api/v1/routes", routesHandler(engine))
+	r.GET("/api/v1/ready", readyHandler)
+
+	// Test tokens for REQUIRE_AUTH=true, whenever JWT_SECRET is set
+	if authVerifier != nil {
+		r.GET(jwtauth.TokenPath, authToken)
+	}
+
+	// Process resource metrics
+	r.GET("/api/v1/metrics", metricsHandler)
+
+	// Request counts per client IP
+	r.GET("/api/v1/clients", clientsHandler)
+
+	// Prometheus scrape endpoint
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	// Analytics endpoints
-	r.GET("/api/v1/weather/analytics/heavy", analyticsHeavy)
-	r.GET("/api/v1/weather/analytics/light", analyticsLight)
-	r.GET("/api/v1/weather/analytics/medium", analyticsMedium)
+	analytics := r.Group("/api/v1/weather/analytics", timeoutMiddleware(cfg.RequestTimeout))
+	analytics.GET("/heavy", analyticsHeavy)
+	analytics.POST("/heavy", analyticsHeavy)
+	analytics.GET("/light", analyticsLight)
+	analytics.GET("/medium", analyticsMedium)
+	analytics.GET("/memory", analyticsMemory)
+	analytics.GET("/fanout", analyticsFanout)
+	analytics.POST("/batch", analyticsBatch)
+	analytics.GET("/stream", analyticsStream)
+	r.GET("/api/v1/analytics/compare", timeoutMiddleware(cfg.RequestTimeout), analyticsCompare)
+
+	// Heavy compute timing summary; DELETE reads and resets it
+	r.GET("/api/v1/compute/stats", computeStats)
+	r.DELETE("/api/v1/compute/stats", resetComputeStats)
 
 	// I/O endpoints
 	r.GET("/api/v1/weather/external", weatherExternal)
 	r.GET("/api/v1/weather/fetch", weatherFetch)
+	r.GET("/api/v1/io/file", fileIO)
+
+	// Serialization endpoints
+	r.GET("/api/v1/bench/json", benchJSON)
 
-	// Database endpoints
-	r.GET("/api/v1/db/users", getUsers)
-	r.POST("/api/v1/db/users", createUser)
+	// Database endpoints, under the request timeout so a query is cancelled
+	// along with its request; creates honour Idempotency-Key so a client's
+	// retries don't insert duplicates
+	idempotent := idempotencyMiddleware(idempotency.New(cfg.IdempotencyTTL))
+	database := r.Group("/api/v1/db", timeoutMiddleware(cfg.RequestTimeout))
+	database.GET("/users", getUsers)
+	database.POST("/users", idempotent, createUser)
+	database.POST("/users/bulk", bulkCreateUsers)
+	database.GET("/users/:id", getUser)
+	database.PUT("/users/:id", updateUser)
+	database.DELETE("/users/:id", deleteUser)
+	database.GET("/stress", dbStress)
+	database.GET("/acquire", dbAcquire)
+	database.GET("/stats", dbStats)
+	r.GET("/api/v1/compute/stream-json", streamUsers)
 
-	log.Println("🚀 Gin server starting on :8000")
-	r.Run(":8000")
+	// Asynchronous compute: submit a job, then poll for its result
+	r.POST("/api/v1/compute/async", submitComputeJob)
+	r.GET("/api/v1/compute/async/:id", getComputeJob)
+
+	// Streaming endpoints
+	r.GET("/api/v1/ws", gin.WrapH(wsServer))
+
+	// Live profiling, off unless ENABLE_PPROF=true. It stays outside
+	// API_PREFIX: net/http/pprof finds profiles under /debug/pprof/ only.
+	if cfg.EnablePprof {
+		registerPprof(engine)
+		log.Println("✓ pprof enabled at /debug/pprof/")
+	}
+
+	return engine
+}
+
+// useMiddleware installs the global middleware stack on engine, in the
+// order requests pass through it. NO_MIDDLEWARE=true skips it.
+func useMiddleware(engine *gin.Engine, cfg config.Config) {
+	engine.Use(inflightMiddleware())
+	if cfg.BenchmarkMode {
+		log.Println("✓ Benchmark mode: request logging disabled")
+	} else {
+		engine.Use(gin.Logger())
+	}
+	engine.Use(recoveryMiddleware())
+	engine.Use(headersMiddleware())
+	engine.Use(prometheusMiddleware())
+	if cfg.ClientStats {
+		clientCounter = clientstats.New()
+		engine.Use(clientStatsMiddleware(clientCounter, cfg.TrustProxy))
+	}
+	if cfg.CORS.Enabled() {
+		engine.Use(corsMiddleware(cfg.CORS))
+		log.Printf("✓ CORS enabled for %s", strings.Join(cfg.CORS.AllowedOrigins, ", "))
+	}
+	if tracing.Enabled() {
+		engine.Use(otelgin.Middleware("gin-carbon-test"), traceIDMiddleware())
+	}
+	if cfg.CPUAccounting {
+		engine.Use(cpuTimeMiddleware())
+	}
+	if cfg.GoroutineTracking {
+		engine.Use(goroutineMiddleware())
+	}
+	if limiter := ratelimit.New(cfg.RateLimitRPS, cfg.RateLimitBurst); limiter != nil {
+		engine.Use(rateLimitMiddleware(limiter))
+	}
+	if cfg.Auth.Required {
+		engine.Use(authMiddleware(authVerifier))
+		log.Println("✓ Authentication required: HS256 bearer tokens signed with JWT_SECRET")
+	}
+	if injector := faultinject.New(cfg.FaultRate, cfg.FaultLatency, cfg.FaultLatencyRate, cfg.FaultSeed); injector != nil {
+		engine.Use(faultMiddleware(injector))
+		log.Printf("⚠️  Fault injection enabled: FAULT_RATE=%g, FAULT_LATENCY_MS=%d, FAULT_LATENCY_RATE=%g", cfg.FaultRate, cfg.FaultLatency.Milliseconds(), cfg.FaultLatencyRate)
+	}
+	if cfg.StrictParams {
+		engine.Use(strictParamsMiddleware())
+		log.Println("✓ Strict query parameters: unknown or malformed ones answer 400")
+	}
+	engine.Use(maxBodyMiddleware(cfg.MaxBodyBytes))
 }
 
-func initDB() {
-	dbHost := getEnv("DB_HOST", "localhost")
-	dbPort := getEnv("DB_PORT", "5432")
-	dbName := getEnv("DB_NAME", "mydb")
-	dbUser := getEnv("DB_USER", "postgres")
-	dbPassword := getEnv("DB_PASSWORD", "1234")
+func initDB(cfg config.DBConfig) {
+	dbLatency = cfg.SimulatedLatency
+	if dbLatency > 0 {
+		log.Printf("✓ Simulated DB latency: %s", dbLatency)
+	}
+
+	if cfg.Driver == config.DriverSQLite {
+		openSQLite()
+	} else {
+		openServer(cfg)
+		if !dbReady && cfg.FallbackSQLite {
+			log.Printf("⚠️  %s unavailable, falling back to in-memory SQLite", cfg.Driver)
+			openSQLite()
+		}
+	}
+
+	if dbReady && cfg.PreparedStatements {
+		if err := prepareStatements(); err != nil {
+			log.Printf("⚠️  Prepared statement warning: %v", err)
+		} else {
+			log.Println("✓ Prepared statements ready")
+		}
+	}
+}
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
+// openServer connects to the PostgreSQL or MySQL server named by cfg.
+func openServer(cfg config.DBConfig) {
+	dialect = store.DialectFor(cfg.Driver)
+	dbDriver = cfg.Driver
 
 	var err error
-	db, err = sql.Open("postgres", connStr)
+	db, err = sql.Open(dialect.Name, cfg.DSN())
 	if err != nil {
 		log.Printf("⚠️  Database connection warning: %v", err)
 		return
 	}
 
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(2)
-	db.SetConnMaxLifetime(30 * time.Second)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	log.Printf("✓ DB pool: max_open=%d max_idle=%d max_lifetime=%s", cfg.MaxOpenConns, cfg.MaxIdleConns, cfg.ConnMaxLifetime)
 
 	if err = db.Ping(); err != nil {
 		log.Printf("⚠️  Database ping warning: %v", err)
 	} else {
-		log.Println("✓ Database connected")
+		dbReady = true
+		log.Printf("✓ Database connected (%s)", cfg.Driver)
+	}
+
+	if dbReady && cfg.AutoMigrate {
+		created, err := dialect.MigrateUsers(context.Background(), db)
+		switch {
+		case err != nil:
+			log.Printf("⚠️  Migration warning: %v", err)
+		case created:
+			log.Println("✓ Migration: created users table")
+		default:
+			log.Println("✓ Migration: users table already present")
+		}
+	}
+}
+
+// openSQLite replaces db with a fresh in-memory SQLite database that already
+// holds the users table.
+func openSQLite() {
+	if db != nil {
+		db.Close()
 	}
+
+	var err error
+	dialect = store.Postgres
+	dbDriver = config.DriverSQLite
+	db, err = memdb.Open()
+	if err != nil {
+		log.Printf("⚠️  SQLite warning: %v", err)
+		db = nil
+		return
+	}
+	dbReady = true
+	log.Println("✓ Using in-memory SQLite database")
+}
+
+// prepareStatements parses the user queries once so handlers skip the
+// per-request parse on the server side. On failure the handlers fall back
+// to unprepared queries.
+func prepareStatements() error {
+	selectStmt, err := db.Prepare(dialect.SelectUsers)
+	if err != nil {
+		return err
+	}
+	insertStmt, err := db.Prepare(dialect.InsertUser)
+	if err != nil {
+		selectStmt.Close()
+		return err
+	}
+
+	selectUsersStmt, insertUserStmt = selectStmt, insertStmt
+	return nil
 }
 
 func rootHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"service":        "Weather Analytics Service",
-		"framework":      "Gin",
-		"version":        "1.0.0",
-		"status":         "running",
-		"uptime_seconds": int(time.Since(startTime).Seconds()),
+	respondJSON(c, http.StatusOK, api.RootResponse{
+		Service:       "Weather Analytics Service",
+		Framework:     "Gin",
+		Version:       "1.0.0",
+		Status:        "running",
+		UptimeSeconds: int(time.Since(startTime).Seconds()),
 	})
 }
 
 func healthHandler(c *gin.Context) {
 	uptimeMs := time.Since(startTime).Milliseconds()
-	c.JSON(http.StatusOK, gin.H{
-		"status":         "healthy",
-		"framework":      "gin",
-		"uptime_seconds": uptimeMs / 1000,
-		"uptime_ms":      uptimeMs,
-		"timestamp":      time.Now().UnixMilli(),
+	respondJSON(c, http.StatusOK, api.HealthResponse{
+		Status:        "healthy",
+		Framework:     "gin",
+		Gomaxprocs:    runtime.GOMAXPROCS(0),
+		NumCPU:        runtime.NumCPU(),
+		UptimeSeconds: uptimeMs / 1000,
+		UptimeMs:      uptimeMs,
+		Timestamp:     time.Now().UnixMilli(),
 	})
 }
 
-func analyticsHeavy(c *gin.Context) {
-	size := parseIntParam(c, "size", 5000)
-	iterations := parseIntParam(c, "iterations", 5)
+// healthDetailedHandler pings the database and, when configured, the weather
+// upstream, reporting each one's latency and an overall status. It answers
+// 503 when the database is down.
+func healthDetailedHandler(c *gin.Context) {
+	probes := []health.Probe{health.Database(db, dbReady)}
+	if weatherUpstream != nil {
+		probes = append(probes, health.Upstream(weatherUpstream))
+	}
 
-	result := heavyCompute(size, iterations)
-	c.JSON(http.StatusOK, gin.H{
-		"endpoint":    "heavy_analytics",
-		"framework":   "gin",
-		"result_hash": result.ResultHash,
-		"total_sum":   result.TotalSum,
-		"matrix_size": result.MatrixSize,
-		"iterations":  result.Iterations,
-		"elapsed_ms":  result.ElapsedMs,
-	})
+	report := health.Run(c.Request.Context(), healthDegradedAfter, probes...)
+	report.Framework = "gin"
+	respondJSON(c, report.HTTPStatus(), report)
 }
 
-func analyticsLight(c *gin.Context) {
-	start := time.Now()
+// versionHandler reports the build metadata so benchmark results can be
+// traced back to the exact binary.
+func versionHandler(c *gin.Context) {
+	respondJSON(c, http.StatusOK, api.NewVersionResponse("gin"))
+}
 
-	var result int64
-	for i := 0; i < 1000; i++ {
-		result += int64(i * i)
+// authToken mints a token signed with JWT_SECRET for the sub query
+// parameter, valid for ttl_seconds, so load generators can authenticate when
+// REQUIRE_AUTH=true. It is a benchmark helper: anyone who can reach it can
+// get a token.
+func authToken(c *gin.Context) {
+	ttl, err := clampedIntParam(c, "ttl_seconds", jwtauth.DefaultTTLSeconds, 1, jwtauth.MaxTTLSeconds)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
 	}
-
-	elapsedMs := time.Since(start).Milliseconds()
-
-	c.JSON(http.StatusOK, gin.H{
-		"endpoint":   "light_analytics",
-		"framework":  "gin",
-		"result":     result,
-		"elapsed_ms": elapsedMs,
-	})
+	token, err := authVerifier.Mint(c.DefaultQuery("sub", jwtauth.DefaultSubject), time.Duration(ttl)*time.Second)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	respondJSON(c, http.StatusOK, token)
 }
 
-func analyticsMedium(c *gin.Context) {
-	size := parseIntParam(c, "size", 2000)
-	iterations := parseIntParam(c, "iterations", 3)
-
-	result := heavyCompute(size, iterations)
-	c.JSON(http.StatusOK, gin.H{
-		"endpoint":    "medium_analytics",
-		"framework":   "gin",
-		"result_hash": result.ResultHash,
-		"total_sum":   result.TotalSum,
-		"matrix_size": result.MatrixSize,
-		"iterations":  result.Iterations,
-		"elapsed_ms":  result.ElapsedMs,
-	})
+// routesHandler lists every method and path registered on the engine, so the
+// harness can check that all frameworks expose the same API before a run.
+func routesHandler(engine *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var routes []api.Route
+		for _, route := range engine.Routes() {
+			routes = append(routes, api.Route{Method: route.Method, Path: route.Path})
+		}
+		respondJSON(c, http.StatusOK, routesResponse(routes))
+	}
 }
 
-func weatherExternal(c *gin.Context) {
-	delayMs := parseIntParam(c, "delay_ms", 100)
-	start := time.Now()
+// routesResponse lists routes under the API prefix they are served at.
+func routesResponse(routes []api.Route) api.RoutesResponse {
+	resp := api.NewRoutesResponse("gin", routes)
+	resp.Prefix = apiPrefix
+	return resp
+}
 
-	time.Sleep(time.Duration(delayMs) * time.Millisecond)
+// readyHandler is the readiness probe. Unlike healthHandler, which only
+// reports that the process is alive, it fails while the database can't be
+// reached so orchestrators stop routing traffic to this instance.
+func readyHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
 
-	weatherData := gin.H{
-		"temperature": 25.5,
-		"humidity":    65,
-		"wind_speed":  12.3,
-		"conditions":  "Partly Cloudy",
-		"location":    "Colombo, LK",
+	if db == nil {
+		respondJSON(c, http.StatusServiceUnavailable, gin.H{
+			"status":            "not_ready",
+			"framework":         "gin",
+			"failed_dependency": "database",
+			"error":             "database not initialized",
+		})
+		return
 	}
 
-	elapsedMs := time.Since(start).Milliseconds()
+	ctx, span := tracing.StartDB(ctx, dbDriver, "PING", "")
+	err := db.PingContext(ctx)
+	tracing.End(span, err)
+	if err != nil {
+		respondJSON(c, http.StatusServiceUnavailable, gin.H{
+			"status":            "not_ready",
+			"framework":         "gin",
+			"failed_dependency": "database",
+			"error":             err.Error(),
+		})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"endpoint":           "external_api",
-		"framework":          "gin",
-		"data":               weatherData,
-		"simulated_delay_ms": delayMs,
-		"elapsed_ms":         elapsedMs,
+	respondJSON(c, http.StatusOK, gin.H{
+		"status":       "ready",
+		"framework":    "gin",
+		"dependencies": gin.H{"database": "ok"},
 	})
 }
 
-func weatherFetch(c *gin.Context) {
-	city := c.DefaultQuery("city", "Colombo")
-	start := time.Now()
-
-	weatherData := gin.H{
-		"temperature": 28.0,
-		"windspeed":   10.5,
-		"weathercode": 1,
-		"note":        "Mock data",
-	}
+// metricsHandler reports process-level resource usage so throughput can be
+// correlated with memory pressure and CPU time without an external agent.
+func metricsHandler(c *gin.Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
 
-	elapsedMs := time.Since(start).Milliseconds()
+	var usage syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &usage)
+	userSeconds := float64(usage.Utime.Nano()) / 1e9
+	systemSeconds := float64(usage.Stime.Nano()) / 1e9
 
-	c.JSON(http.StatusOK, gin.H{
-		"endpoint":   "weather_fetch",
-		"framework":  "gin",
-		"city":       city,
-		"data":       weatherData,
-		"elapsed_ms": elapsedMs,
+	respondJSON(c, http.StatusOK, gin.H{
+		"framework":        "gin",
+		"uptime_seconds":   int(time.Since(startTime).Seconds()),
+		"goroutines":       runtime.NumGoroutine(),
+		"gomaxprocs":       runtime.GOMAXPROCS(0),
+		"num_cpu":          runtime.NumCPU(),
+		"websockets":       wsServer.Active(),
+		"current_inflight": requests.Current(),
+		"peak_inflight":    requests.Peak(),
+		"heavy": gin.H{
+			"in_flight": heavySem.InFlight(),
+			"limit":     heavySem.Limit(),
+		},
+		"memory": gin.H{
+			"alloc_bytes":       mem.Alloc,
+			"total_alloc_bytes": mem.TotalAlloc,
+			"sys_bytes":         mem.Sys,
+			"num_gc":            mem.NumGC,
+			"pause_total_ns":    mem.PauseTotalNs,
+		},
+		"cpu": gin.H{
+			"user_seconds":   userSeconds,
+			"system_seconds": systemSeconds,
+			"total_seconds":  userSeconds + systemSeconds,
+		},
 	})
 }
 
-func getUsers(c *gin.Context) {
-	rows, err := db.Query("SELECT id, name, email, created_at FROM users")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// clientsHandler lists the clients that sent the most requests, to check
+// how a load generator spreads its traffic.
+func clientsHandler(c *gin.Context) {
+	if clientCounter == nil {
+		writeError(c, http.StatusServiceUnavailable, api.ErrorResponse{Message: "client accounting disabled"})
 		return
 	}
-	defer rows.Close()
-
-	var users []User
-	for rows.Next() {
-		var u User
-		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
-			continue
-		}
-		users = append(users, u)
+	limit, err := clampedIntParam(c, "limit", 10, 1, clientstats.MaxTop)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	top := clientCounter.Top(limit)
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":           "clients",
+		"framework":          "gin",
+		"trust_proxy":        trustProxy,
+		"total_clients":      top.TotalClients,
+		"total_requests":     top.TotalRequests,
+		"untracked_requests": top.Untracked,
+		"clients":            top.Clients,
+	})
 }
 
-func createUser(c *gin.Context) {
-	var input struct {
-		Name  string `json:"name"`
-		Email string `json:"email"`
+func analyticsHeavy(c *gin.Context) {
+	p, err := heavyParams(c)
+	if err != nil {
+		respondBodyError(c, err)
+		return
 	}
-
-	if err := c.BindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	warmupRuns, err := clampedIntParam(c, "warmup", 0, 0, compute.MaxWarmup)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	pad, err := padParam(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
 		return
 	}
 
-	var user User
-	err := db.QueryRow(
-		"INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id, name, email, created_at",
-		input.Name, input.Email,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+	// A client already holding the result for these parameters needs
+	// nothing recomputed or resent
+	if etag, ok := compute.KnownETag(p); ok && notModified(c, etag) {
+		return
+	}
 
+	warmup, err := boundedWarmup(c.Request.Context(), p, warmupRuns)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status, message := compute.ErrorStatus(err)
+		writeError(c, status, api.ErrorResponse{Message: message})
+		return
+	}
+	measurement := compute.StartHeapMeasurement(boolParam(c, "gc"))
+	result, hit, err := computeCache.Compute(c.Request.Context(), p, heavySem)
+	heapDelta := measurement.Done()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(c, status, api.ErrorResponse{Message: message})
 		return
 	}
 
-	c.JSON(http.StatusCreated, user)
-}
-
-func heavyCompute(size, iterations int) ComputeResult {
-	start := time.Now()
+	if notModified(c, compute.ETag(result.ResultHash)) {
+		return
+	}
+	resp := gin.H{
+		"endpoint":    "heavy_analytics",
+		"framework":   "gin",
+		"result_hash": result.ResultHash,
+		"total_sum":   result.TotalSum,
+		"matrix_size": result.MatrixSize,
+		"iterations":  result.Iterations,
+		"kernel":      result.Kernel,
+		"elapsed_ms":  result.ElapsedMs,
 
-	a := make([]int, size)
-	for i := 0; i < size; i++ {
-		a[i] = i
+		"estimated_joules":    result.EstimatedJoules,
+		"estimated_co2_grams": result.EstimatedCO2Grams,
+	}
+	if result.Allocations != nil {
+		resp["allocations"] = result.Allocations
+	}
+	msg := computepb.FromResult("heavy_analytics", "gin", result, pad)
+	if computeCache != nil {
+		resp["cache"] = compute.CacheStatus(hit)
+		msg.Cache = compute.CacheStatus(hit)
 	}
+	if heapDelta != nil {
+		resp["heap"] = heapDelta
+	}
+	if warmup != nil {
+		resp["warmup"] = warmup
+	}
+	if pad != "" {
+		resp["padding"] = pad
+	}
+	respondCompute(c, resp, msg)
+}
 
-	var total int64
-	for iteration := 0; iteration < iterations; iteration++ {
-		for _, x := range a {
-			total += int64(x*x) % int64(size+1)
-		}
+func analyticsLight(c *gin.Context) {
+	pad, err := padParam(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
 	}
 
-	hash := sha256.Sum256([]byte(fmt.Sprintf("%d", total)))
-	hashStr := hex.EncodeToString(hash[:])
+	start := time.Now()
+
+	var result int64
+	for i := 0; i < 1000; i++ {
+		result += int64(i * i)
+	}
 
 	elapsedMs := time.Since(start).Milliseconds()
 
-	return ComputeResult{
-		ResultHash: hashStr,
-		TotalSum:   total,
-		MatrixSize: size,
-		Iterations: iterations,
-		ElapsedMs:  elapsedMs,
-	}
+	respondJSON(c, http.StatusOK, api.LightAnalyticsResponse{
+		Endpoint:  "light_analytics",
+		Framework: "gin",
+		Result:    result,
+		ElapsedMs: elapsedMs,
+		Padding:   pad,
+	})
+}
+
+func analyticsMedium(c *gin.Context) {
+	p, err := computeParams(c, 2000, 3)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	warmupRuns, err := clampedIntParam(c, "warmup", 0, 0, compute.MaxWarmup)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	pad, err := padParam(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// A client already holding the result for these parameters needs
+	// nothing recomputed or resent
+	if etag, ok := compute.KnownETag(p); ok && notModified(c, etag) {
+		return
+	}
+
+	warmup, err := boundedWarmup(c.Request.Context(), p, warmupRuns)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(c, status, api.ErrorResponse{Message: message})
+		return
+	}
+	measurement := compute.StartHeapMeasurement(boolParam(c, "gc"))
+	result, hit, err := computeCache.Compute(c.Request.Context(), p, heavySem)
+	heapDelta := measurement.Done()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(c, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	if notModified(c, compute.ETag(result.ResultHash)) {
+		return
+	}
+	resp := gin.H{
+		"endpoint":    "medium_analytics",
+		"framework":   "gin",
+		"result_hash": result.ResultHash,
+		"total_sum":   result.TotalSum,
+		"matrix_size": result.MatrixSize,
+		"iterations":  result.Iterations,
+		"kernel":      result.Kernel,
+		"elapsed_ms":  result.ElapsedMs,
+
+		"estimated_joules":    result.EstimatedJoules,
+		"estimated_co2_grams": result.EstimatedCO2Grams,
+	}
+	if result.Allocations != nil {
+		resp["allocations"] = result.Allocations
+	}
+	msg := computepb.FromResult("medium_analytics", "gin", result, pad)
+	if computeCache != nil {
+		resp["cache"] = compute.CacheStatus(hit)
+		msg.Cache = compute.CacheStatus(hit)
+	}
+	if heapDelta != nil {
+		resp["heap"] = heapDelta
+	}
+	if warmup != nil {
+		resp["warmup"] = warmup
+	}
+	if pad != "" {
+		resp["padding"] = pad
+	}
+	respondCompute(c, resp, msg)
+}
+
+// analyticsMemory allocates many short-lived objects to keep the garbage
+// collector busy, reporting the allocations and GC pauses incurred.
+func analyticsMemory(c *gin.Context) {
+	p, err := memoryParams(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	measurement := compute.StartHeapMeasurement(boolParam(c, "gc"))
+	result, err := compute.MemoryPressure(c.Request.Context(), p)
+	heapDelta := measurement.Done()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(c, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	resp := gin.H{
+		"endpoint":    "memory_analytics",
+		"framework":   "gin",
+		"objects":     result.Objects,
+		"object_size": result.ObjectSize,
+		"checksum":    result.Checksum,
+		"elapsed_ms":  result.ElapsedMs,
+		"allocations": result.Allocations,
+	}
+	if heapDelta != nil {
+		resp["heap"] = heapDelta
+	}
+	respondJSON(c, http.StatusOK, resp)
+}
+
+// analyticsFanout starts many goroutines that each do almost no work, to
+// measure goroutine creation and scheduling overhead as its own workload.
+func analyticsFanout(c *gin.Context) {
+	tasks, err := clampedIntParam(c, "tasks", 1000, 1, compute.MaxFanoutTasks)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	result, err := compute.Fanout(c.Request.Context(), tasks)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(c, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":        "fanout_analytics",
+		"framework":       "gin",
+		"tasks":           result.Tasks,
+		"sum":             result.Sum,
+		"elapsed_ms":      result.ElapsedMs,
+		"ns_per_task":     result.NsPerTask,
+		"peak_goroutines": result.PeakGoroutines,
+	})
+}
+
+// analyticsCompare runs every compute kernel at the same size and
+// iterations and reports their timings relative to each other, to check
+// that each kernel scales with its complexity.
+func analyticsCompare(c *gin.Context) {
+	size, err := clampedIntParam(c, "size", 200, 1, compute.MaxMatmulSize)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	iterations, err := clampedIntParam(c, "iterations", 5, 1, compute.MaxIterations)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := compute.CheckCompare(size, iterations); err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	release, err := heavySem.Acquire(c.Request.Context())
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(c, status, api.ErrorResponse{Message: message})
+		return
+	}
+	comparison, err := compute.Compare(c.Request.Context(), size, iterations)
+	release()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(c, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":   "compare_analytics",
+		"framework":  "gin",
+		"size":       comparison.Size,
+		"iterations": comparison.Iterations,
+		"total_ops":  comparison.TotalOps,
+		"kernels":    comparison.Kernels,
+	})
+}
+
+// computeStats summarizes the wall times of every heavy computation this
+// process has run since startup or the last reset.
+func computeStats(c *gin.Context) {
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":  "compute_stats",
+		"framework": "gin",
+		"heavy":     compute.HeavyStats(),
+	})
+}
+
+// resetComputeStats returns the summary and clears it, so one call closes a
+// benchmark phase and starts the next.
+func resetComputeStats(c *gin.Context) {
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":  "compute_stats",
+		"framework": "gin",
+		"heavy":     compute.ResetHeavyStats(),
+		"reset":     true,
+	})
+}
+
+// analyticsBatch runs a list of compute jobs with bounded concurrency and
+// returns their results in request order.
+func analyticsBatch(c *gin.Context) {
+	var jobs []compute.Params
+	if err := c.ShouldBindJSON(&jobs); err != nil {
+		respondBodyError(c, err)
+		return
+	}
+
+	if len(jobs) == 0 || len(jobs) > compute.MaxBatchJobs {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{
+			Message: fmt.Sprintf("batch must contain between 1 and %d jobs", compute.MaxBatchJobs),
+		})
+		return
+	}
+
+	for i := range jobs {
+		if err := jobs[i].Validate(); err != nil {
+			writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error(), Details: map[string]int{"index": i}})
+			return
+		}
+	}
+
+	results, err := compute.RunBatch(c.Request.Context(), jobs, runtime.NumCPU(), heavySem)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(c, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(c, http.StatusOK, results)
+}
+
+// submitComputeJob queues a heavy job, read the same way as analyticsHeavy's,
+// and answers 202 with its ID at once instead of holding the connection
+// until it's computed. A full queue answers 429.
+func submitComputeJob(c *gin.Context) {
+	p, err := heavyParams(c)
+	if err != nil {
+		respondBodyError(c, err)
+		return
+	}
+
+	job, err := jobQueue.Submit(p)
+	if err != nil {
+		c.Header("Retry-After", "1")
+		writeError(c, http.StatusTooManyRequests, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	statusURL := apiPrefix + "/api/v1/compute/async/" + job.ID
+	c.Header("Location", statusURL)
+	respondJSON(c, http.StatusAccepted, gin.H{
+		"endpoint":   "async_compute",
+		"framework":  "gin",
+		"job_id":     job.ID,
+		"status":     job.Status,
+		"status_url": statusURL,
+	})
+}
+
+// getComputeJob reports a queued job's status, with its result once done.
+func getComputeJob(c *gin.Context) {
+	job, ok := jobQueue.Get(c.Param("id"))
+	if !ok {
+		writeError(c, http.StatusNotFound, api.ErrorResponse{Message: "job not found"})
+		return
+	}
+	respondJSON(c, http.StatusOK, computeJobStatus{Endpoint: "async_compute_status", Framework: "gin", Job: job})
+}
+
+// analyticsStream runs the heavy workload and streams an SSE "progress" event
+// after every iteration, finishing with a "result" event carrying the
+// ComputeResult.
+func analyticsStream(c *gin.Context) {
+	p, err := computeParams(c, 5000, 5)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// Queue before the stream starts, so a busy server still answers 503
+	release, err := heavySem.Acquire(c.Request.Context())
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(c, status, api.ErrorResponse{Message: message})
+		return
+	}
+	defer release()
+
+	sse.SetHeaders(c.Writer)
+	if enableTrailers {
+		defer trailer.Declare(c.Writer).Finish()
+	}
+	c.Status(http.StatusOK)
+
+	result, err := compute.HeavyComputeWithProgress(c.Request.Context(), p, func(progress compute.Progress) {
+		sse.WriteEvent(c.Writer, "progress", progress)
+	})
+	if err != nil {
+		// A cancelled context means the client went away; nobody is listening
+		if !errors.Is(err, context.Canceled) {
+			sse.WriteEvent(c.Writer, "error", api.ErrorResponse{Message: "compute timeout"}.ForStatus(http.StatusServiceUnavailable))
+		}
+		return
+	}
+
+	sse.WriteEvent(c.Writer, "result", result)
+}
+
+func weatherExternal(c *gin.Context) {
+	if weatherUpstream != nil {
+		weatherExternalUpstream(c)
+		return
+	}
+
+	delayMs := parseIntParam(c, "delay_ms", 100)
+	start := time.Now()
+
+	// Stop waiting as soon as the client goes away, so abandoned requests
+	// don't hold a goroutine for the full delay
+	timer := time.NewTimer(time.Duration(delayMs) * time.Millisecond)
+	select {
+	case <-c.Request.Context().Done():
+		timer.Stop()
+		writeError(c, compute.StatusClientClosedRequest, api.ErrorResponse{Message: "client closed request"})
+		return
+	case <-timer.C:
+	}
+
+	weatherData := gin.H{
+		"temperature": 25.5,
+		"humidity":    65,
+		"wind_speed":  12.3,
+		"conditions":  "Partly Cloudy",
+		"location":    "Colombo, LK",
+	}
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":           "external_api",
+		"framework":          "gin",
+		"data":               weatherData,
+		"simulated_delay_ms": delayMs,
+		"elapsed_ms":         elapsedMs,
+	})
+}
+
+// weatherExternalUpstream proxies the configured upstream's JSON through,
+// exercising a real HTTP client instead of the simulated delay.
+func weatherExternalUpstream(c *gin.Context) {
+	start := time.Now()
+
+	data, err := weatherUpstream.Fetch(c.Request.Context())
+	if err != nil {
+		writeError(c, http.StatusBadGateway, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":     "external_api",
+		"framework":    "gin",
+		"data":         data,
+		"upstream_url": weatherUpstream.URL,
+		"elapsed_ms":   elapsedMs,
+	})
+}
+
+func weatherFetch(c *gin.Context) {
+	if list := c.Query("cities"); list != "" {
+		weatherFetchMany(c, list)
+		return
+	}
+
+	city := c.DefaultQuery("city", "Colombo")
+	start := time.Now()
+
+	weatherData, source, coalesced := weatherFetcher.Fetch(c.Request.Context(), city)
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":   "weather_fetch",
+		"framework":  "gin",
+		"city":       city,
+		"data":       weatherData,
+		"source":     source,
+		"coalesced":  coalesced,
+		"elapsed_ms": elapsedMs,
+	})
+}
+
+// weatherFetchMany looks up every city of a comma-separated list
+// concurrently, at most parallelism at a time, and reports each city next to
+// the total elapsed time.
+func weatherFetchMany(c *gin.Context, list string) {
+	cities, err := weather.ParseCities(list)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	parallelism, err := clampedIntParam(c, "parallelism", weather.DefaultParallelism, 1, weather.MaxCities)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	start := time.Now()
+
+	reports := weatherFetcher.FetchMany(c.Request.Context(), cities, parallelism)
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":    "weather_fetch",
+		"framework":   "gin",
+		"cities":      reports,
+		"count":       len(reports),
+		"parallelism": parallelism,
+		"elapsed_ms":  elapsedMs,
+	})
+}
+
+// fileIO writes a temp file, fsyncs it and reads it back, the disk
+// counterpart to the HTTP and database I/O endpoints.
+func fileIO(c *gin.Context) {
+	size, err := clampedIntParam(c, "bytes", diskio.DefaultBytes, 1, diskio.MaxBytes)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	result, err := diskio.RoundTrip(c.Request.Context(), size)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":      "file_io",
+		"framework":     "gin",
+		"bytes_written": result.BytesWritten,
+		"bytes_read":    result.BytesRead,
+		"sha256":        result.SHA256,
+		"elapsed_ms":    result.ElapsedMs,
+		"write_us":      result.WriteUs,
+		"fsync_us":      result.FsyncUs,
+		"read_us":       result.ReadUs,
+	})
+}
+
+// benchJSON builds a nested payload of the requested depth and width and
+// serializes it with the configured encoder, reporting the encoded size and
+// marshalling time. Only the summary is sent, so the measurement is pure
+// serialization with no network write of the payload itself.
+func benchJSON(c *gin.Context) {
+	depth, err := clampedIntParam(c, "depth", 3, 0, jsonenc.MaxPayloadDepth)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	width, err := clampedIntParam(c, "width", 10, 1, jsonenc.MaxPayloadWidth)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	shape := c.Query("shape")
+	if shape == "" {
+		shape = jsonenc.ShapeStruct
+	}
+
+	payload, nodes, err := jsonenc.BuildPayload(depth, width, shape)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	result, err := jsonenc.MarshalTimed(encoder, payload)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":   "json_bench",
+		"framework":  "gin",
+		"encoder":    encoder.Name(),
+		"shape":      shape,
+		"depth":      depth,
+		"width":      width,
+		"nodes":      nodes,
+		"bytes":      result.Bytes,
+		"marshal_us": result.MarshalUs,
+	})
+}
+
+func getUsers(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	limit, err := clampedIntParam(c, "limit", 100, 1, 1000)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	offset, err := clampedIntParam(c, "offset", 0, 0, math.MaxInt32)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	repeat, err := clampedIntParam(c, "repeat", 1, 1, store.MaxQueryRepeat)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// The extra runs go first, so the page served is the last one read
+	start := time.Now()
+	var repeatLatencyMs int64
+	retries, err := store.Retry(c.Request.Context(), func() (err error) {
+		repeatLatencyMs, err = repeatUsers(c.Request.Context(), limit, offset, repeat-1)
+		return err
+	})
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	if csvstream.Requested(c.Query("format"), c.GetHeader("Accept")) {
+		exportUsersCSV(c, limit, offset)
+		return
+	}
+
+	var users []User
+	var latencyMs int64
+	listRetries, err := store.Retry(c.Request.Context(), func() (err error) {
+		users, latencyMs, err = listUsers(c.Request.Context(), limit, offset)
+		return err
+	})
+	retries += listRetries
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	queryMs := float64(time.Since(start).Nanoseconds()) / 1e6
+	latencyMs += repeatLatencyMs
+
+	resp := gin.H{
+		"users":    users,
+		"count":    len(users),
+		"limit":    limit,
+		"offset":   offset,
+		"prepared": selectUsersStmt != nil,
+	}
+	if latencyMs > 0 {
+		resp["simulated_db_latency_ms"] = latencyMs
+	}
+	if repeat > 1 {
+		resp["repeat"] = repeat
+		resp["query_ms"] = queryMs
+	}
+	if retries > 0 {
+		resp["retries"] = retries
+	}
+	respondJSON(c, http.StatusOK, resp)
+}
+
+// listUsers reads one page of users, holding the connection for the
+// simulated DB latency before consuming the rows.
+func listUsers(ctx context.Context, limit, offset int) (users []User, latencyMs int64, err error) {
+	ctx, span := tracing.StartDB(ctx, dbDriver, "SELECT", dialect.SelectUsers)
+	defer func() { tracing.End(span, err) }()
+
+	var rows *sql.Rows
+	if selectUsersStmt != nil {
+		rows, err = selectUsersStmt.QueryContext(ctx, limit, offset)
+	} else {
+		rows, err = db.QueryContext(ctx, dialect.SelectUsers, limit, offset)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	latencyMs = simulateDBLatency()
+	users = make([]User, 0, limit)
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			continue
+		}
+		users = append(users, u)
+	}
+	return users, latencyMs, rows.Err()
+}
+
+// repeatUsers reads the same page of users times more and discards it, so
+// ?repeat= can weight a request towards the database. It returns the
+// simulated DB latency slept across the runs.
+func repeatUsers(ctx context.Context, limit, offset, times int) (latencyMs int64, err error) {
+	for i := 0; i < times; i++ {
+		_, ms, err := listUsers(ctx, limit, offset)
+		if err != nil {
+			return latencyMs, err
+		}
+		latencyMs += ms
+	}
+	return latencyMs, nil
+}
+
+// exportUsersCSV streams one page of users as a CSV attachment, writing
+// each row as it is read instead of collecting the page first. The status
+// line goes out before the rows, so a query failing mid-stream leaves the
+// export truncated.
+func exportUsersCSV(c *gin.Context, limit, offset int) {
+	ctx, span := tracing.StartDB(c.Request.Context(), dbDriver, "SELECT", dialect.SelectUsers)
+	var rows *sql.Rows
+	var err error
+	if selectUsersStmt != nil {
+		rows, err = selectUsersStmt.QueryContext(ctx, limit, offset)
+	} else {
+		rows, err = db.QueryContext(ctx, dialect.SelectUsers, limit, offset)
+	}
+	if err != nil {
+		tracing.End(span, err)
+		writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	simulateDBLatency()
+	csvstream.SetHeaders(c.Writer.Header(), csvstream.UsersFilename)
+	c.Writer.WriteHeader(http.StatusOK)
+	out, err := csvstream.NewUsers(c.Writer)
+	if err != nil {
+		tracing.End(span, err)
+		return
+	}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			continue
+		}
+		if err := out.Write(u.ID, u.Name, u.Email, u.CreatedAt); err != nil {
+			// The client went away; stop reading rows nobody will receive
+			tracing.End(span, err)
+			return
+		}
+	}
+	tracing.End(span, rows.Err())
+	out.Flush()
+}
+
+// dbStress runs the store.Stress workload: concurrent INSERT/SELECT
+// transactions issued from inside one request, so DB contention can be
+// measured apart from HTTP concurrency. Nothing it writes is committed.
+func dbStress(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	workers, err := clampedIntParam(c, "workers", 4, 1, store.MaxStressWorkers)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ops, err := clampedIntParam(c, "ops", 100, 1, store.MaxStressOps)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	result, err := store.Stress(c.Request.Context(), db, dialect, workers, ops)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(c, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":       "db_stress",
+		"framework":      "gin",
+		"workers":        result.Workers,
+		"ops_per_worker": result.OpsPerWorker,
+		"operations":     result.Operations,
+		"errors":         result.Errors,
+		"elapsed_ms":     result.ElapsedMs,
+		"ops_per_second": result.OpsPerSecond,
+		"avg_op_us":      result.AvgOpUs,
+		"max_op_us":      result.MaxOpUs,
+	})
+}
+
+// dbAcquire times n sequential connection checkouts from the pool with no
+// query on them, separating connection-management overhead from query
+// execution.
+func dbAcquire(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	n, err := clampedIntParam(c, "n", 100, 1, store.MaxAcquires)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	result, err := store.Acquire(c.Request.Context(), db, n)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(c, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":         "db_acquire",
+		"framework":        "gin",
+		"acquisitions":     result.Acquisitions,
+		"elapsed_ms":       result.ElapsedMs,
+		"min_us":           result.MinUs,
+		"mean_us":          result.MeanUs,
+		"p50_us":           result.P50Us,
+		"p90_us":           result.P90Us,
+		"p99_us":           result.P99Us,
+		"max_us":           result.MaxUs,
+		"wait_count":       result.WaitCount,
+		"wait_duration_ms": result.WaitDurationMs,
+	})
+}
+
+// streamUsers writes users as NDJSON while reading them, so the response is
+// never held in memory as a whole. Compare it with getUsers to weigh
+// streaming against buffered serialization.
+func streamUsers(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	limit, err := clampedIntParam(c, "limit", 1000, 1, 100000)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	offset, err := clampedIntParam(c, "offset", 0, 0, math.MaxInt32)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	flushEvery, err := clampedIntParam(c, "flush_every", 100, 1, 10000)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	ctx, span := tracing.StartDB(c.Request.Context(), dbDriver, "SELECT", dialect.SelectUsers)
+	rows, err := db.QueryContext(ctx, dialect.SelectUsers, limit, offset)
+	if err != nil {
+		tracing.End(span, err)
+		writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	out := ndjson.NewWriter(c.Writer, flushEvery)
+	if enableTrailers {
+		defer trailer.Declare(c.Writer).Finish()
+	}
+	c.Writer.WriteHeader(http.StatusOK)
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			continue
+		}
+		if err := out.Write(u); err != nil {
+			// The client went away; stop reading rows nobody will receive
+			tracing.End(span, err)
+			return
+		}
+	}
+
+	// The status line is gone, so a failure mid-stream becomes a final line
+	err = rows.Err()
+	tracing.End(span, err)
+	if err != nil {
+		out.Write(api.ErrorResponse{Message: err.Error()}.ForStatus(http.StatusInternalServerError))
+	}
+	out.Flush()
+}
+
+// simulateDBLatency sleeps for DB_SIMULATED_LATENCY_MS and returns the delay
+// in milliseconds. Callers invoke it before consuming their result so the
+// pooled connection stays checked out for the whole delay.
+func simulateDBLatency() int64 {
+	if dbLatency <= 0 {
+		return 0
+	}
+	time.Sleep(dbLatency)
+	return dbLatency.Milliseconds()
+}
+
+// getUser fetches one user by primary key, answering 404 when no row matches.
+func getUser(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	id, err := params.ID("user id", c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	var user User
+	retries, err := store.Retry(c.Request.Context(), func() (err error) {
+		user, err = findUser(c.Request.Context(), id)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(c, http.StatusNotFound, api.ErrorResponse{Message: "user not found"})
+		return
+	}
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(c, http.StatusOK, fetchedUser{User: user, Retries: retries})
+}
+
+// findUser fetches one user by primary key; sql.ErrNoRows means no match.
+func findUser(ctx context.Context, id int64) (user User, err error) {
+	ctx, span := tracing.StartDB(ctx, dbDriver, "SELECT", dialect.SelectUser)
+	err = db.QueryRowContext(ctx, dialect.SelectUser, id).Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		tracing.End(span, nil)
+	} else {
+		tracing.End(span, err)
+	}
+	return user, err
+}
+
+func createUser(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	var input store.NewUser
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondBodyError(c, err)
+		return
+	}
+	if ferr := input.Validate(); ferr != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Code: api.CodeInvalidField, Field: ferr.Field, Message: ferr.Message})
+		return
+	}
+
+	var user User
+	var latencyMs int64
+	retries, err := store.RetryWrite(c.Request.Context(), func() (err error) {
+		user, latencyMs, err = insertUser(c.Request.Context(), input)
+		return err
+	})
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(c, http.StatusCreated, createdUser{User: user, Prepared: insertUserStmt != nil, SimulatedDBLatencyMs: latencyMs, Retries: retries})
+}
+
+// insertUser inserts one user and returns the created row, holding the
+// connection for the simulated DB latency before reading it.
+func insertUser(ctx context.Context, input store.NewUser) (user User, latencyMs int64, err error) {
+	ctx, span := tracing.StartDB(ctx, dbDriver, "INSERT", dialect.InsertUser)
+	defer func() { tracing.End(span, err) }()
+
+	if !dialect.Returning {
+		return insertUserReadBack(ctx, input)
+	}
+
+	var row *sql.Row
+	if insertUserStmt != nil {
+		row = insertUserStmt.QueryRowContext(ctx, input.Name, input.Email)
+	} else {
+		row = db.QueryRowContext(ctx, dialect.InsertUser, input.Name, input.Email)
+	}
+	latencyMs = simulateDBLatency()
+	err = row.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+	return user, latencyMs, err
+}
+
+// insertUserReadBack is insertUser for a dialect without RETURNING: the row
+// is read back by its generated id, in the same transaction so the pair
+// holds one connection like the single statement does.
+func insertUserReadBack(ctx context.Context, input store.NewUser) (user User, latencyMs int64, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return user, 0, err
+	}
+	defer tx.Rollback()
+
+	var result sql.Result
+	if insertUserStmt != nil {
+		result, err = tx.StmtContext(ctx, insertUserStmt).ExecContext(ctx, input.Name, input.Email)
+	} else {
+		result, err = tx.ExecContext(ctx, dialect.InsertUser, input.Name, input.Email)
+	}
+	if err != nil {
+		return user, 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return user, 0, err
+	}
+
+	latencyMs = simulateDBLatency()
+	err = tx.QueryRowContext(ctx, dialect.SelectUser, id).Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+	if err != nil {
+		return user, latencyMs, err
+	}
+	return user, latencyMs, tx.Commit()
+}
+
+// updateUser replaces a user's name and email, answering 404 when no row
+// matches the id.
+func updateUser(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	id, err := params.ID("user id", c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	var input store.NewUser
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondBodyError(c, err)
+		return
+	}
+	if ferr := input.Validate(); ferr != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Code: api.CodeInvalidField, Field: ferr.Field, Message: ferr.Message})
+		return
+	}
+
+	var n int64
+	retries, err := store.RetryWrite(c.Request.Context(), func() (err error) {
+		n, err = execUser(c.Request.Context(), "UPDATE", dialect.UpdateUser, input.Name, input.Email, id)
+		return err
+	})
+	if err != nil {
+		if _, ok := store.ConstraintViolation(err, []store.NewUser{input}); ok {
+			writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+			return
+		}
+		writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	if n == 0 {
+		writeError(c, http.StatusNotFound, api.ErrorResponse{Message: "user not found"})
+		return
+	}
+
+	resp := gin.H{"id": id, "name": input.Name, "email": input.Email}
+	if retries > 0 {
+		resp["retries"] = retries
+	}
+	respondJSON(c, http.StatusOK, resp)
+}
+
+// deleteUser removes a user, answering 204 on success and 404 when no row
+// matches the id.
+func deleteUser(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	id, err := params.ID("user id", c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// A 204 has no body, so retries made here go unreported
+	var n int64
+	_, err = store.RetryWrite(c.Request.Context(), func() (err error) {
+		n, err = execUser(c.Request.Context(), "DELETE", dialect.DeleteUser, id)
+		return err
+	})
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	if n == 0 {
+		writeError(c, http.StatusNotFound, api.ErrorResponse{Message: "user not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// execUser runs a single-row write and returns the number of rows it
+// affected, so callers can tell a missing user from a successful write.
+func execUser(ctx context.Context, operation, query string, args ...interface{}) (n int64, err error) {
+	ctx, span := tracing.StartDB(ctx, dbDriver, operation, query)
+	defer func() { tracing.End(span, err) }()
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// bulkCreateUsers inserts up to store.MaxBulkUsers users with a single
+// multi-row statement, rolling back the whole batch on any constraint
+// violation.
+func bulkCreateUsers(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	var input []store.NewUser
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondBodyError(c, err)
+		return
+	}
+
+	if len(input) == 0 || len(input) > store.MaxBulkUsers {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{
+			Message: fmt.Sprintf("bulk insert must contain between 1 and %d users", store.MaxBulkUsers),
+		})
+		return
+	}
+
+	for i, u := range input {
+		if ferr := u.Validate(); ferr != nil {
+			writeError(c, http.StatusBadRequest, api.ErrorResponse{Code: api.CodeInvalidField, Field: ferr.Field, Message: ferr.Message, Details: map[string]int{"index": i}})
+			return
+		}
+	}
+
+	if i := store.DuplicateEmailIndex(input); i >= 0 {
+		writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: "duplicate email in request", Details: map[string]int{"index": i}})
+		return
+	}
+
+	var users []User
+	retries, err := store.RetryWrite(c.Request.Context(), func() (err error) {
+		users, err = insertUsers(c.Request.Context(), input)
+		return err
+	})
+	if err != nil {
+		if index, ok := store.ConstraintViolation(err, input); ok {
+			resp := api.ErrorResponse{Message: err.Error()}
+			if index >= 0 {
+				resp.Details = map[string]int{"index": index}
+			}
+			writeError(c, http.StatusBadRequest, resp)
+			return
+		}
+		writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	resp := gin.H{
+		"users": users,
+		"count": len(users),
+	}
+	if retries > 0 {
+		resp["retries"] = retries
+	}
+	respondJSON(c, http.StatusCreated, resp)
+}
+
+// dbStats reports the connection pool's state and cumulative counters, to
+// show whether requests are waiting on the pool during the DB benchmark.
+func dbStats(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	respondJSON(c, http.StatusOK, gin.H{
+		"endpoint":  "db_stats",
+		"framework": "gin",
+		"pool":      store.Pool(db),
+	})
+}
+
+// insertUsers runs the bulk INSERT inside a transaction and returns the
+// created rows, read back by email where the dialect lacks RETURNING. Any
+// error rolls the transaction back.
+func insertUsers(ctx context.Context, input []store.NewUser) (users []User, err error) {
+	query, args := dialect.BulkInsertUsers(input)
+	ctx, span := tracing.StartDB(ctx, dbDriver, "INSERT", query)
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var rows *sql.Rows
+	if dialect.Returning {
+		rows, err = tx.QueryContext(ctx, query, args...)
+	} else if _, err = tx.ExecContext(ctx, query, args...); err == nil {
+		query, args = dialect.SelectInsertedUsers(input)
+		rows, err = tx.QueryContext(ctx, query, args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users = make([]User, 0, len(input))
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// respondBodyError answers a request whose body couldn't be read: 413 when it
+// exceeded MAX_BODY_BYTES, 400 for malformed or invalid JSON.
+func respondBodyError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(c, http.StatusRequestEntityTooLarge, api.ErrorResponse{
+			Message: fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit),
+		})
+		return
+	}
+	writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+}
+
+// requireDB writes a 503 and returns false when the database never connected.
+func requireDB(c *gin.Context) bool {
+	if !dbReady {
+		writeError(c, http.StatusServiceUnavailable, api.ErrorResponse{Message: "database unavailable"})
+		return false
+	}
+	return true
 }
 
 func parseIntParam(c *gin.Context, param string, defaultValue int) int {
@@ -298,9 +2028,181 @@ func parseIntParam(c *gin.Context, param string, defaultValue int) int {
 	return defaultValue
 }
 
-func getEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// computeParams reads kernel, size, iterations, goroutines and seed from the
+// query string, applying the endpoint's defaults and the kernel's bounds.
+func computeParams(c *gin.Context, defaultSize, defaultIterations int) (compute.Params, error) {
+	kernel, err := compute.ParseKernel(c.Query("kernel"))
+	if err != nil {
+		return compute.Params{}, err
+	}
+
+	size, err := clampedIntParam(c, "size", kernel.DefaultSize(defaultSize), 1, kernel.MaxSize())
+	if err != nil {
+		return compute.Params{}, err
+	}
+	iterations, err := clampedIntParam(c, "iterations", defaultIterations, 1, compute.MaxIterations)
+	if err != nil {
+		return compute.Params{}, err
+	}
+	goroutines, err := clampedIntParam(c, "goroutines", 1, 1, compute.MaxGoroutines)
+	if err != nil {
+		return compute.Params{}, err
+	}
+	seed, err := clampedIntParam(c, "seed", 0, 0, compute.MaxSeed)
+	if err != nil {
+		return compute.Params{}, err
+	}
+
+	return compute.Params{Kernel: kernel, Size: size, Iterations: iterations, Goroutines: goroutines, Seed: seed}, nil
+}
+
+// memoryParams reads the object count and size for analyticsMemory.
+func memoryParams(c *gin.Context) (compute.MemoryParams, error) {
+	objects, err := clampedIntParam(c, "objects", 10000, 1, compute.MaxMemoryObjects)
+	if err != nil {
+		return compute.MemoryParams{}, err
+	}
+	size, err := clampedIntParam(c, "size", 1024, 1, compute.MaxMemoryObjectSize)
+	if err != nil {
+		return compute.MemoryParams{}, err
+	}
+
+	p := compute.MemoryParams{Objects: objects, Size: size}
+	if err := p.Validate(); err != nil {
+		return compute.MemoryParams{}, err
+	}
+	return p, nil
+}
+
+// boundedWarmup runs compute.Warmup under its own heavySem slot, so warm-up
+// runs count against MAX_CONCURRENT_HEAVY like the measured one.
+func boundedWarmup(ctx context.Context, p compute.Params, n int) (*compute.WarmupInfo, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	release, err := heavySem.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return compute.Warmup(ctx, p, n)
+}
+
+// heavyParams reads the heavy job from a JSON body on POST requests sent as
+// application/json, and from the query string otherwise. Both paths share
+// the defaults and bounds.
+func heavyParams(c *gin.Context) (compute.Params, error) {
+	if c.Request.Method != http.MethodPost || !params.IsJSON(c.ContentType()) {
+		return computeParams(c, 5000, 5)
+	}
+	return compute.DecodeParams(c.Request.Body, compute.Params{Size: 5000, Iterations: 5})
+}
+
+// clampedIntParam is parseIntParam with bounds: out-of-range values are
+// rejected rather than handed to the workload.
+func clampedIntParam(c *gin.Context, param string, defaultValue, minValue, maxValue int) (int, error) {
+	return params.ClampedInt(param, c.Query(param), defaultValue, minValue, maxValue)
+}
+
+// padParam reads pad_bytes and returns that much filler for the response's
+// padding field, or "" for none.
+func padParam(c *gin.Context) (string, error) {
+	n, err := clampedIntParam(c, "pad_bytes", 0, 0, filler.Max())
+	if err != nil {
+		return "", err
+	}
+	return filler.Pad(n), nil
+}
+
+// boolParam reads a boolean query flag; see params.Bool.
+func boolParam(c *gin.Context, param string) bool {
+	return params.Bool(c.Query(param))
+}
+
+// notModified tags the response with etag and, when the request's
+// If-None-Match already names that tag, answers 304 with no body and returns
+// true. The 304 varies on Accept like the response it stands in for.
+func notModified(c *gin.Context, etag string) bool {
+	api.SetETag(c.Writer.Header(), etag)
+	if !compute.MatchesETag(c.GetHeader("If-None-Match"), etag) {
+		return false
+	}
+	c.Writer.Header().Add("Vary", "Accept")
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// jsonRender renders through the configured encoder so gin responses use the
+// same marshaller as the other frameworks, or through MessagePack when that
+// was negotiated. The body is buffered to send an explicit Content-Length.
+type jsonRender struct {
+	status    int
+	data      interface{}
+	mediaType string
+}
+
+func (r jsonRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return jsonenc.Write(w, r.status, negotiate.Encoder(r.mediaType, encoder), r.data)
+}
+
+func (r jsonRender) WriteContentType(w http.ResponseWriter) {
+	if r.mediaType == negotiate.JSON {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", r.mediaType)
+	}
+}
+
+// writeError sends an error in the api.ErrorResponse envelope shared by
+// every framework, its code defaulting to one derived from status.
+func writeError(c *gin.Context, status int, e api.ErrorResponse) {
+	respondJSON(c, status, e.ForStatus(status))
+}
+
+// notFound answers a path no route serves.
+func notFound(c *gin.Context) {
+	writeError(c, http.StatusNotFound, api.ErrorResponse{Message: "not found"})
+}
+
+// methodNotAllowed answers a routed path requested with a method it doesn't
+// serve.
+func methodNotAllowed(c *gin.Context) {
+	writeError(c, http.StatusMethodNotAllowed, api.ErrorResponse{Message: "method not allowed"})
+}
+
+// respondJSON sends data in the format the Accept header prefers, JSON
+// unless MessagePack is asked for, and a 406 when it accepts neither.
+func respondJSON(c *gin.Context, status int, data interface{}) {
+	mediaType, ok := negotiate.Select(c.GetHeader("Accept"), negotiate.Formats...)
+	if !ok {
+		status, mediaType, data = http.StatusNotAcceptable, negotiate.JSON, negotiate.NotAcceptable()
+	}
+	c.Writer.Header().Add("Vary", "Accept")
+	c.Render(status, jsonRender{status: status, data: data, mediaType: mediaType})
+	renderFailed(c)
+}
+
+// renderFailed answers a body c.Render couldn't marshal with a 500 in the
+// error envelope, which always marshals. gin records the error but writes
+// nothing, which would otherwise leave an empty response with the intended
+// status.
+func renderFailed(c *gin.Context) {
+	if c.Writer.Written() || len(c.Errors) == 0 {
+		return
+	}
+	writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: c.Errors.Last().Error()})
+}
+
+// respondCompute sends a heavy or medium result like respondJSON, or as its
+// protobuf message msg when the Accept header prefers Protocol Buffers.
+func respondCompute(c *gin.Context, resp gin.H, msg *computepb.ComputeResult) {
+	mediaType, _ := negotiate.Select(c.GetHeader("Accept"), negotiate.ComputeFormats...)
+	if mediaType != negotiate.Protobuf {
+		respondJSON(c, http.StatusOK, resp)
+		return
 	}
-	return fallback
+	c.Writer.Header().Add("Vary", "Accept")
+	c.Render(http.StatusOK, jsonRender{status: http.StatusOK, data: msg, mediaType: mediaType})
+	renderFailed(c)
 }
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"carbon-bench/apitest"
+	"carbon-bench/compute"
+	"carbon-bench/config"
+	"carbon-bench/jsonenc"
+	"carbon-bench/padding"
+	"carbon-bench/weather"
+	"github.com/gin-gonic/gin"
+)
+
+// unreachableDB names a PostgreSQL server on a port nothing listens on, so
+// initDB's ping fails at once.
+var unreachableDB = config.DBConfig{
+	Driver: config.DriverPostgres,
+	URL:    "postgres://postgres@127.0.0.1:1/mydb?sslmode=disable&connect_timeout=2",
+}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	var err error
+	if encoder, err = jsonenc.New(jsonenc.Stdlib); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// resetDB closes whatever initDB opened and forgets it, so each test starts
+// from a server that never connected.
+func resetDB(t *testing.T) {
+	t.Cleanup(func() {
+		if db != nil {
+			db.Close()
+		}
+		db, dbReady, selectUsersStmt, insertUserStmt = nil, false, nil, nil
+	})
+}
+
+// testEngine builds the engine main would from the default configuration,
+// with the handlers' dependencies set up and nothing reaching the network.
+func testEngine(t *testing.T) *gin.Engine {
+	t.Helper()
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.BenchmarkMode = true
+	heavySem = compute.NewSemaphore(cfg.MaxConcurrentHeavy, cfg.HeavyQueueTimeout)
+	filler = padding.New(0)
+	weatherFetcher = weather.NewOpenMeteo(0, time.Second)
+	weatherFetcher.GeocodeURL = "http://127.0.0.1:1/search"
+	weatherFetcher.ForecastURL = "http://127.0.0.1:1/forecast"
+	return newEngine(cfg)
+}
+
+func usersEngine() *gin.Engine {
+	engine := gin.New()
+	engine.GET("/api/v1/db/users", getUsers)
+	engine.POST("/api/v1/db/users", createUser)
+	return engine
+}
+
+func TestUsersUnavailableWithoutDatabase(t *testing.T) {
+	resetDB(t)
+	initDB(unreachableDB)
+	if dbReady {
+		t.Fatal("dbReady after a failed ping")
+	}
+
+	engine := usersEngine()
+	for _, tt := range []struct{ method, body string }{
+		{http.MethodGet, ""},
+		{http.MethodPost, `{"name":"Ada","email":"ada@example.com"}`},
+	} {
+		w := apitest.Serve(engine, tt.method, "/api/v1/db/users", tt.body)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: status %d, want 503", tt.method, w.Code)
+		}
+		var body struct{ Code, Message string }
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v in %q", tt.method, err, w.Body)
+		}
+		if body.Code != "service_unavailable" || body.Message != "database unavailable" {
+			t.Errorf("%s: body %+v, want service_unavailable/database unavailable", tt.method, body)
+		}
+	}
+}
+
+func TestUsersServedOnceConnected(t *testing.T) {
+	resetDB(t)
+	initDB(config.DBConfig{Driver: config.DriverSQLite})
+	if !dbReady {
+		t.Fatal("dbReady false after opening SQLite")
+	}
+
+	engine := usersEngine()
+	if w := apitest.Serve(engine, http.MethodPost, "/api/v1/db/users", `{"name":"Ada","email":"ada@example.com"}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST: status %d: %s", w.Code, w.Body)
+	}
+	if w := apitest.Serve(engine, http.MethodGet, "/api/v1/db/users", ""); w.Code != http.StatusOK {
+		t.Fatalf("GET: status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestHugeComputeCutOffByRequestTimeout(t *testing.T) {
+	heavySem = compute.NewSemaphore(1, time.Second)
+	filler = padding.New(0)
+	engine := gin.New()
+	engine.GET("/api/v1/weather/analytics/heavy", timeoutMiddleware(50*time.Millisecond), analyticsHeavy)
+
+	// Left alone this is 10^10 loop steps, minutes of work
+	start := time.Now()
+	w := apitest.Serve(engine, http.MethodGet, "/api/v1/weather/analytics/heavy?size=10000000&iterations=1000", "")
+	elapsed := time.Since(start)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want 503: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "compute timeout") {
+		t.Errorf("body %s, want compute timeout", w.Body)
+	}
+	if elapsed > 5*time.Second {
+		t.Errorf("took %s to give up after a 50ms timeout", elapsed)
+	}
+}
+
+// TestComputeRoutesWaitForHeavySlot holds the only heavy slot and checks
+// that every route running the heavy workload is turned away instead of
+// running beside it.
+func TestComputeRoutesWaitForHeavySlot(t *testing.T) {
+	handler := testEngine(t)
+	heavySem = compute.NewSemaphore(1, 0)
+	release, err := heavySem.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	for _, route := range []struct{ method, target, body string }{
+		{http.MethodGet, "/api/v1/weather/analytics/heavy?size=1000&iterations=1", ""},
+		{http.MethodGet, "/api/v1/weather/analytics/heavy?size=1000&iterations=1&warmup=1", ""},
+		{http.MethodGet, "/api/v1/weather/analytics/medium?size=1000&iterations=1", ""},
+		{http.MethodGet, "/api/v1/weather/analytics/stream?size=1000&iterations=1", ""},
+		{http.MethodPost, "/api/v1/weather/analytics/batch", `[{"size":1000,"iterations":1}]`},
+		{http.MethodGet, "/api/v1/analytics/compare?size=1000&iterations=1", ""},
+	} {
+		if w := apitest.Serve(handler, route.method, route.target, route.body); w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s %s: status %d with no free slot, want 503: %s", route.method, route.target, w.Code, w.Body)
+		}
+	}
+}
+
+func TestBaselineHeadersOnEveryRoute(t *testing.T) {
+	resetDB(t)
+	apitest.CheckBaselineHeaders(t, testEngine(t), "gin")
+}
+
+// TestShutdownOrderOnSIGTERM sends the process a real SIGTERM while a request
+// is in flight and records each shutdown step as it happens.
+func TestShutdownOrderOnSIGTERM(t *testing.T) {
+	resetDB(t)
+	initDB(config.DBConfig{Driver: config.DriverSQLite})
+	jobQueue = compute.NewJobQueue(1, 1)
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}
+	// whileDBOpen records event, flagging it if the DB is already closed
+	whileDBOpen := func(event string) {
+		if err := db.Ping(); err != nil {
+			event += " after db closed"
+		}
+		record(event)
+	}
+
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		whileDBOpen("flush metrics")
+	}))
+	defer gateway.Close()
+
+	started := make(chan struct{})
+	engine := gin.New()
+	engine.GET("/slow", func(c *gin.Context) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		record("drain request")
+		c.String(http.StatusOK, "done")
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: engine}
+	go srv.Serve(ln)
+	addr := ln.Addr().String()
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	<-started
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	cfg := config.Config{ShutdownTimeout: 5 * time.Second, PushgatewayURL: gateway.URL}
+	done := make(chan struct{})
+	go func() {
+		shutdown(quit, cfg, srv, func(context.Context) error {
+			whileDBOpen("flush traces")
+			return nil
+		}, func() {
+			whileDBOpen("stop stats")
+		})
+		close(done)
+	}()
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			record("stop accepting")
+			break
+		}
+		conn.Close()
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not return")
+	}
+	if err := db.Ping(); err != nil {
+		record("close db")
+	}
+
+	if code := <-status; code != http.StatusOK {
+		t.Errorf("in-flight request: status %d, want 200", code)
+	}
+	want := []string{"stop accepting", "drain request", "flush metrics", "flush traces", "stop stats", "close db"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("shutdown order %q, want %q", events, want)
+	}
+}
+
+func TestPanicRecoveredAsJSON(t *testing.T) {
+	engine := testEngine(t)
+	engine.GET("/panic", func(c *gin.Context) { panic("deliberate test panic") })
+
+	w := apitest.Serve(engine, http.MethodGet, "/panic", "")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want 500", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type %q, want application/json", ct)
+	}
+	var body struct{ Code, Message string }
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%v in %q", err, w.Body)
+	}
+	if body.Code != "internal_server_error" || body.Message != "internal server error" {
+		t.Errorf("body %+v, want internal_server_error/internal server error", body)
+	}
+}
+
+// endlessUsers replaces the users table with a view that recurses forever
+// without yielding a row, so a query on it runs until it is interrupted.
+const endlessUsers = `
+DROP TABLE users;
+CREATE VIEW users AS
+    WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n)
+    SELECT x AS id, 'name' AS name, 'email' AS email, CURRENT_TIMESTAMP AS created_at
+    FROM n WHERE x < 0;
+`
+
+func TestUsersQueryCancelledWithRequest(t *testing.T) {
+	resetDB(t)
+	initDB(config.DBConfig{Driver: config.DriverSQLite})
+	if _, err := db.Exec(endlessUsers); err != nil {
+		t.Fatal(err)
+	}
+	engine := gin.New()
+	engine.GET("/api/v1/db/users", timeoutMiddleware(100*time.Millisecond), getUsers)
+
+	answered := make(chan *httptest.ResponseRecorder, 1)
+	go func() { answered <- apitest.Serve(engine, http.MethodGet, "/api/v1/db/users", "") }()
+	var w *httptest.ResponseRecorder
+	select {
+	case w = <-answered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no answer 5s after a 100ms timeout: the query ignored the request context")
+	}
+	if w.Code == http.StatusOK {
+		t.Fatalf("status 200 from a query that never finishes: %s", w.Body)
+	}
+
+	// The pool holds a single connection, so it is only free again if the
+	// query on it was interrupted rather than left running
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var one int
+	if err := db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		t.Errorf("connection still busy after the request timed out: %v", err)
+	}
+}
+
+func TestConditionalCompute(t *testing.T) {
+	apitest.CheckConditional(t, testEngine(t))
+}
+
+func TestUnroutedRequestsAnswerJSON(t *testing.T) {
+	apitest.CheckUnrouted(t, testEngine(t))
+}
+
+func TestMarshalFailureAnswersJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	respondJSON(c, http.StatusOK, gin.H{"x": math.NaN()})
+	apitest.CheckError(t, w, http.StatusInternalServerError, "internal_server_error")
+}
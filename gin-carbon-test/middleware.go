@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"carbon-bench/api"
+	"carbon-bench/carbon"
+	"carbon-bench/clientstats"
+	"carbon-bench/config"
+	"carbon-bench/cpuacct"
+	"carbon-bench/faultinject"
+	"carbon-bench/idempotency"
+	"carbon-bench/jwtauth"
+	"carbon-bench/params"
+	"carbon-bench/ratelimit"
+	"carbon-bench/tracing"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// clientStatsMiddleware counts every request against its client IP.
+func clientStatsMiddleware(counter *clientstats.Counter, trustProxy bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		counter.Record(clientstats.ClientIP(c.Request, trustProxy))
+		c.Next()
+	}
+}
+
+// inflightMiddleware counts the request as in flight until every later
+// handler has returned.
+func inflightMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requests.Start()
+		defer requests.Done()
+		c.Next()
+	}
+}
+
+// headersMiddleware sets the baseline response headers shared by every
+// framework.
+func headersMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		api.SetBaselineHeaders(c.Writer.Header(), "gin")
+		c.Next()
+	}
+}
+
+// recoveryMiddleware turns a handler panic into the JSON 500 every framework
+// answers with. gin.CustomRecovery logs the panic and stack trace first.
+func recoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: "internal server error"})
+		c.Abort()
+	})
+}
+
+// goroutineMiddleware logs requests that finish with a different goroutine
+// count than they started with, to catch handlers or middleware that leak
+// goroutines. The count is process-wide, so concurrent requests show up as
+// noise; hunt leaks at concurrency 1.
+func goroutineMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		before := runtime.NumGoroutine()
+		c.Next()
+		if delta := runtime.NumGoroutine() - before; delta != 0 {
+			log.Printf("⚠️  Goroutine delta %+d after %s %s", delta, c.Request.Method, c.FullPath())
+		}
+	}
+}
+
+// timeoutMiddleware bounds the request context so long-running compute and
+// database queries observe the deadline and bail out early.
+func timeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// maxBodyMiddleware caps the request body at limit bytes. Reads past the
+// limit fail with *http.MaxBytesError, which respondBodyError turns into 413.
+func maxBodyMiddleware(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// rateLimitMiddleware applies a per-client-IP token bucket and answers 429
+// with Retry-After once a client exceeds its rate.
+func rateLimitMiddleware(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ok, retryAfter := limiter.Allow(ratelimit.RemoteIP(c.Request)); !ok {
+			c.Header("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(retryAfter)))
+			writeError(c, http.StatusTooManyRequests, api.ErrorResponse{Message: "rate limit exceeded"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// authMiddleware answers 401 to requests without a valid bearer token,
+// except those jwtauth.Exempt lets through.
+func authMiddleware(verifier *jwtauth.Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if jwtauth.Exempt(unprefixed(c.Request.URL.Path)) {
+			c.Next()
+			return
+		}
+		if err := verifier.Check(c.GetHeader("Authorization")); err != nil {
+			c.Header("WWW-Authenticate", jwtauth.Challenge)
+			writeError(c, http.StatusUnauthorized, api.ErrorResponse{Message: err.Error()})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// traceIDMiddleware returns the trace ID of the otelgin server span in
+// X-Trace-Id so clients can look up the trace for a response.
+func traceIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tracing.SetTraceIDHeader(c.Writer, c.Request.Context())
+		c.Next()
+	}
+}
+
+// faultMiddleware delays and fails requests as the injector decides. The
+// delay ends early if the client goes away.
+func faultMiddleware(injector *faultinject.Injector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		delay, fail := injector.Next()
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				c.Abort()
+				return
+			}
+		}
+		if fail {
+			writeError(c, http.StatusInternalServerError, api.ErrorResponse{Message: "injected fault"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// corsMaxAge is how long browsers may cache a preflight response.
+const corsMaxAge = 10 * time.Minute
+
+// corsMiddleware answers preflight requests for every route, including ones
+// with no OPTIONS handler, and adds the CORS headers to allowed origins.
+func corsMiddleware(c config.CORSConfig) gin.HandlerFunc {
+	cc := cors.Config{
+		AllowMethods: c.AllowedMethods,
+		AllowHeaders: c.AllowedHeaders,
+		MaxAge:       corsMaxAge,
+	}
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			cc.AllowAllOrigins = true
+		}
+	}
+	if !cc.AllowAllOrigins {
+		cc.AllowOrigins = c.AllowedOrigins
+	}
+	return cors.New(cc)
+}
+
+// cpuTimeMiddleware pins the request to its OS thread and reports the thread
+// CPU time consumed before the response header is sent as X-CPU-Ms. This
+// separates CPU cost from wall time, which includes sleeps and I/O waits.
+// The thread stays pinned through those waits too, so under load there is
+// an OS thread per in-flight request; ENABLE_CPU_ACCOUNTING=true opts in.
+func cpuTimeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		c.Writer = &cpuTimeWriter{ResponseWriter: c.Writer, start: carbon.ThreadCPUSeconds()}
+		c.Next()
+	}
+}
+
+// cpuTimeWriter sets the X-CPU-Ms header the first time gin commits the
+// status or writes the body.
+type cpuTimeWriter struct {
+	gin.ResponseWriter
+	start       float64
+	wroteHeader bool
+}
+
+func (w *cpuTimeWriter) setHeader() {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.Header().Set(cpuacct.Header, cpuacct.Format(carbon.ThreadCPUSeconds()-w.start))
+}
+
+func (w *cpuTimeWriter) WriteHeader(code int) {
+	w.setHeader()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cpuTimeWriter) WriteHeaderNow() {
+	w.setHeader()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *cpuTimeWriter) Write(b []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cpuTimeWriter) WriteString(s string) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *cpuTimeWriter) Flush() {
+	w.setHeader()
+	w.ResponseWriter.Flush()
+}
+
+// idempotencyMiddleware replays the recorded response to a repeat of a
+// request whose Idempotency-Key was already used with the same body, instead
+// of running the handler again, and answers 409 when the key comes back with
+// a different body. Requests without the header, or any request when store
+// is nil, pass straight through.
+func idempotencyMiddleware(store *idempotency.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotency.Header)
+		if key == "" || store == nil {
+			c.Next()
+			return
+		}
+		if len(key) > idempotency.MaxKeyLength {
+			writeError(c, http.StatusBadRequest, api.ErrorResponse{
+				Message: fmt.Sprintf("%s exceeds %d characters", idempotency.Header, idempotency.MaxKeyLength),
+			})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondBodyError(c, err)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Scope keys to the route so one key can't replay another endpoint
+		key = c.FullPath() + " " + key
+		resp, owned, err := store.Claim(c.Request.Context(), key, body)
+		switch {
+		case errors.Is(err, idempotency.ErrMismatch):
+			writeError(c, http.StatusConflict, api.ErrorResponse{Message: err.Error()})
+			c.Abort()
+			return
+		case err != nil:
+			// The client went away waiting for the original request
+			c.Abort()
+			return
+		case resp != nil:
+			c.Header(idempotency.ReplayedHeader, "true")
+			c.Data(resp.Status, resp.ContentType, resp.Body)
+			c.Abort()
+			return
+		case !owned:
+			c.Next()
+			return
+		}
+
+		completed := false
+		defer func() {
+			// A panicking handler records nothing, so a retry runs again
+			if !completed {
+				store.Release(key)
+			}
+		}()
+
+		w := &idempotencyWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		store.Complete(key, &idempotency.Response{
+			Status:      w.Status(),
+			ContentType: w.Header().Get("Content-Type"),
+			Body:        w.body.Bytes(),
+		})
+		completed = true
+	}
+}
+
+// idempotencyWriter keeps a copy of the response body for the idempotency
+// store.
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// strictParamsMiddleware answers 400 with every offending parameter when a
+// request carries query parameters its endpoint doesn't read or values that
+// don't parse, where the handlers would fall back to their defaults.
+func strictParamsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if problems := params.CheckQuery(unprefixed(c.Request.URL.Path), c.Request.URL.Query()); len(problems) > 0 {
+			writeError(c, http.StatusBadRequest, api.ErrorResponse{Message: "invalid query parameters", Details: map[string]interface{}{"params": problems}})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// unprefixed strips API_PREFIX from a request path, giving the path the
+// route was declared with.
+func unprefixed(path string) string {
+	path = strings.TrimPrefix(path, apiPrefix)
+	if path == "" {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,1587 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"carbon-bench/api"
+	"carbon-bench/carbon"
+	"carbon-bench/compute"
+	"carbon-bench/computepb"
+	"carbon-bench/config"
+	"carbon-bench/csvstream"
+	"carbon-bench/diskio"
+	"carbon-bench/health"
+	"carbon-bench/inflight"
+	"carbon-bench/jsonenc"
+	"carbon-bench/jwtauth"
+	"carbon-bench/negotiate"
+	"carbon-bench/padding"
+	"carbon-bench/params"
+	"carbon-bench/selfcheck"
+	"carbon-bench/sse"
+	"carbon-bench/statslog"
+	"carbon-bench/store"
+	"carbon-bench/trailer"
+	"carbon-bench/weather"
+	"carbon-bench/wsecho"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	_ "github.com/lib/pq"
+)
+
+var (
+	startTime time.Time
+	db        *sql.DB
+	dbReady   bool
+
+	// Prepared once at startup unless DB_PREPARED_STATEMENTS=false
+	selectUsersStmt *sql.Stmt
+	insertUserStmt  *sql.Stmt
+	encoder         jsonenc.Encoder
+
+	// weatherUpstream is set when WEATHER_UPSTREAM_URL is configured
+	weatherUpstream *weather.Upstream
+	weatherFetcher  *weather.OpenMeteo
+
+	wsServer *wsecho.Server
+
+	// requests counts in-flight requests and their peak for /api/v1/metrics
+	requests inflight.Gauge
+
+	// enableTrailers is ENABLE_TRAILERS: streams end with timing trailers
+	enableTrailers bool
+
+	// filler pads analytics responses by pad_bytes, up to MAX_PAD_BYTES
+	filler *padding.Filler
+
+	// healthDegradedAfter is HEALTH_DEGRADED_MS, the dependency latency
+	// /api/v1/health/detailed reports as degraded
+	healthDegradedAfter = health.DefaultDegradedAfter
+
+	// authVerifier is set when JWT_SECRET is, and mints and checks tokens
+	authVerifier *jwtauth.Verifier
+)
+
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// createdUser is the createUser response: the user's fields plus whether the
+// prepared-statement path served the insert.
+type createdUser struct {
+	User
+	Prepared bool `json:"prepared"`
+}
+
+func main() {
+	startTime = time.Now()
+
+	// Response JSON encoder (JSON_ENCODER=stdlib|jsoniter)
+	var err error
+	encoder, err = jsonenc.New(getEnv("JSON_ENCODER", jsonenc.Stdlib))
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	log.Printf("✓ JSON encoder: %s", encoder.Name())
+
+	// Listen port (PORT, default 8000)
+	port, err := config.Port()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	maxProcs, err := config.MaxProcs()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	config.SetMaxProcs(maxProcs)
+	addr := fmt.Sprintf(":%d", port)
+
+	// HTTPS when TLS_CERT_FILE and TLS_KEY_FILE are set (ENABLE_TLS overrides)
+	tlsCfg, err := config.TLS()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	// Connection timeouts, shared by every framework
+	timeouts, err := config.Timeouts()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	// Bearer tokens signed with JWT_SECRET, required when REQUIRE_AUTH=true
+	authCfg, err := config.Auth()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	authVerifier = authCfg.Verifier()
+
+	// Initialize database
+	initDB()
+
+	// Resource snapshots in the log every STATS_INTERVAL_SECONDS
+	statsInterval := time.Duration(getEnvInt("STATS_INTERVAL_SECONDS", 0)) * time.Second
+	stopStats := statslog.Start(statsInterval, db)
+	if statsInterval > 0 {
+		log.Printf("✓ Stats logging every %s", statsInterval)
+	}
+
+	// Weather upstreams: Open-Meteo for fetch, optional real upstream for external
+	upstreamTimeout := time.Duration(getEnvInt("WEATHER_UPSTREAM_TIMEOUT_SECONDS", 5)) * time.Second
+	cacheTTL := time.Duration(getEnvInt("WEATHER_CACHE_TTL_SECONDS", 300)) * time.Second
+	weatherFetcher = weather.NewOpenMeteo(cacheTTL, upstreamTimeout)
+	if upstreamURL := getEnv("WEATHER_UPSTREAM_URL", ""); upstreamURL != "" {
+		weatherUpstream = weather.NewUpstream(upstreamURL, upstreamTimeout)
+		log.Printf("✓ Weather upstream: %s (timeout %s)", upstreamURL, upstreamTimeout)
+	}
+
+	// WebSocket echo server for the streaming workload
+	wsServer = wsecho.New(getEnvInt("WS_MAX_CONNECTIONS", 1000))
+
+	healthDegradedAfter = time.Duration(getEnvInt("HEALTH_DEGRADED_MS", int(health.DefaultDegradedAfter.Milliseconds()))) * time.Millisecond
+
+	// Filler for pad_bytes, generated once up to MAX_PAD_BYTES
+	filler = padding.New(getEnvInt("MAX_PAD_BYTES", padding.DefaultMax))
+
+	// Timing trailers on SSE streams (ENABLE_TRAILERS=true)
+	enableTrailers = getEnv("ENABLE_TRAILERS", "false") == "true"
+	if enableTrailers {
+		log.Printf("✓ Stream trailers: %s", trailer.Declaration)
+	}
+
+	// Carbon estimation model
+	gridIntensity, err := config.GridIntensity()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	carbon.Configure(getEnvFloat("CPU_WATTS_PER_CORE", carbon.DefaultWattsPerCore), gridIntensity.Static)
+	if gridIntensity.Live() {
+		carbon.SetProvider(gridIntensity.Provider())
+		log.Printf("✓ Grid intensity: %s", gridIntensity)
+	}
+
+	s := newServer(addr, authCfg)
+
+	s.SetReadTimeout(timeouts.Read)
+	s.SetWriteTimeout(timeouts.Write)
+	s.SetIdleTimeout(timeouts.Idle)
+	log.Printf("✓ Server timeouts: %s", timeouts)
+
+	// With HTTPS enabled GoFrame moves the listen address over to TLS
+	if tlsCfg.Enabled {
+		s.EnableHTTPS(tlsCfg.CertFile, tlsCfg.KeyFile)
+	}
+
+	log.Printf("🚀 GoFrame server starting on %s (%s)", addr, tlsCfg.Mode())
+	if err := s.Start(); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+
+	// SELF_CHECK requests every GET endpoint against the live server and
+	// exits nonzero if any of them doesn't answer 200
+	if getEnv("SELF_CHECK", "false") == "true" {
+		go func() {
+			target := selfcheck.Target{Network: "tcp", Address: addr, TLS: tlsCfg.Enabled}
+			if authCfg.Required {
+				token, err := authVerifier.Mint(jwtauth.DefaultSubject, time.Hour)
+				if err != nil {
+					log.Fatalf("Self-check token: %v", err)
+				}
+				target.Token = token.AccessToken
+			}
+			if err := selfcheck.Run(target); err != nil {
+				log.Printf("✗ Self-check failed: %v", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// Wait for SIGINT/SIGTERM, then drain in-flight requests before closing the DB
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	shutdownTimeout := time.Duration(getEnvInt("SHUTDOWN_TIMEOUT_SECONDS", 30)) * time.Second
+	log.Printf("Shutting down server (timeout %s)...", shutdownTimeout)
+
+	s.SetGracefulShutdownTimeout(int(shutdownTimeout.Seconds()))
+	if err := s.Shutdown(); err != nil {
+		log.Printf("⚠️  Server shutdown warning: %v", err)
+	}
+
+	// Stop logging snapshots before the pool they read is closed
+	stopStats()
+
+	if db != nil {
+		db.Close()
+	}
+	log.Println("✓ Server stopped")
+}
+
+// newServer sets up the default server to listen on addr: the middleware
+// stack, then every route.
+func newServer(addr string, authCfg config.AuthConfig) *ghttp.Server {
+	s := g.Server()
+	s.SetAddr(addr)
+	s.SetDumpRouterMap(false)
+
+	// BENCHMARK_MODE=true drops per-request access logs; errors are still logged
+	benchmarkMode := getEnv("BENCHMARK_MODE", "false") == "true"
+	if benchmarkMode {
+		log.Println("✓ Benchmark mode: request logging disabled")
+	}
+
+	// Middleware: GoFrame recovers and logs panics itself, answered in the
+	// error envelope by internalError; access logging is opt-in
+	s.SetAccessLogEnabled(!benchmarkMode)
+
+	s.Use(inflightMiddleware)
+	s.Use(headersMiddleware)
+
+	// Unknown paths and wrong methods answer in the error envelope too,
+	// rather than goframe's empty 404
+	s.BindStatusHandler(http.StatusNotFound, unroutedHandler(s))
+	s.BindStatusHandler(http.StatusInternalServerError, internalError)
+	if authCfg.Required {
+		s.Use(authMiddleware)
+		log.Println("✓ Authentication required: HS256 bearer tokens signed with JWT_SECRET")
+	}
+
+	s.Group("/", func(group *ghttp.RouterGroup) {
+		// Root endpoint
+		group.GET("/", rootHandler)
+
+		// Liveness and readiness probes
+		group.GET("/api/v1/health", healthHandler)
+		group.GET("/api/v1/health/detailed", healthDetailedHandler)
+		group.GET("/api/v1/version", versionHandler)
+		group.GET("/api/v1/routes", routesHandler(s))
+		group.GET("/api/v1/ready", readyHandler)
+
+		// Test tokens for REQUIRE_AUTH=true, whenever JWT_SECRET is set
+		if authVerifier != nil {
+			group.GET(jwtauth.TokenPath, authToken)
+		}
+
+		// Process resource metrics
+		group.GET("/api/v1/metrics", metricsHandler)
+
+		// Analytics endpoints
+		requestTimeout := time.Duration(getEnvInt("REQUEST_TIMEOUT_SECONDS", 10)) * time.Second
+		group.Group("/api/v1/weather/analytics", func(group *ghttp.RouterGroup) {
+			group.Middleware(timeoutMiddleware(requestTimeout))
+			group.GET("/heavy", analyticsHeavy)
+			group.POST("/heavy", analyticsHeavy)
+			group.GET("/light", analyticsLight)
+			group.GET("/medium", analyticsMedium)
+			group.GET("/memory", analyticsMemory)
+			group.GET("/fanout", analyticsFanout)
+			group.POST("/batch", analyticsBatch)
+			group.GET("/stream", analyticsStream)
+		})
+		group.Group("/api/v1/analytics", func(group *ghttp.RouterGroup) {
+			group.Middleware(timeoutMiddleware(requestTimeout))
+			group.GET("/compare", analyticsCompare)
+		})
+
+		// Heavy compute timing summary; DELETE reads and resets it
+		group.GET("/api/v1/compute/stats", computeStats)
+		group.DELETE("/api/v1/compute/stats", resetComputeStats)
+
+		// I/O endpoints
+		group.GET("/api/v1/weather/external", weatherExternal)
+		group.GET("/api/v1/weather/fetch", weatherFetch)
+		group.GET("/api/v1/io/file", fileIO)
+
+		// Serialization endpoints
+		group.GET("/api/v1/bench/json", benchJSON)
+
+		// Database endpoints, under the request timeout so a query is
+		// cancelled along with its request
+		group.Group("/api/v1/db", func(group *ghttp.RouterGroup) {
+			group.Middleware(timeoutMiddleware(requestTimeout))
+			group.GET("/users", getUsers)
+			group.POST("/users", createUser)
+			group.POST("/users/bulk", bulkCreateUsers)
+			group.GET("/stats", dbStats)
+		})
+
+		// Streaming endpoints
+		group.GET("/api/v1/ws", func(r *ghttp.Request) {
+			wsServer.ServeHTTP(r.Response.RawWriter(), r.Request)
+		})
+	})
+
+	return s
+}
+
+func initDB() {
+	dbHost := getEnv("DB_HOST", "localhost")
+	dbPort := getEnv("DB_PORT", "5432")
+	dbName := getEnv("DB_NAME", "mydb")
+	dbUser := getEnv("DB_USER", "postgres")
+	dbPassword := getEnv("DB_PASSWORD", "1234")
+
+	ssl, err := config.DBSSL()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s %s",
+		dbHost, dbPort, dbUser, dbPassword, dbName, ssl.DSNParams())
+
+	db, err = sql.Open("postgres", connStr)
+	if err != nil {
+		log.Printf("⚠️  Database connection warning: %v", err)
+		return
+	}
+
+	maxOpenConns := getEnvInt("DB_MAX_OPEN_CONNS", 10)
+	maxIdleConns := getEnvInt("DB_MAX_IDLE_CONNS", 2)
+	connMaxLifetime := time.Duration(getEnvInt("DB_CONN_MAX_LIFETIME_SECONDS", 30)) * time.Second
+
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetConnMaxLifetime(connMaxLifetime)
+	log.Printf("✓ DB pool: max_open=%d max_idle=%d max_lifetime=%s", maxOpenConns, maxIdleConns, connMaxLifetime)
+
+	if err = db.Ping(); err != nil {
+		log.Printf("⚠️  Database ping warning: %v", err)
+	} else {
+		dbReady = true
+		log.Printf("✓ Database connected (sslmode=%s)", ssl.Mode)
+	}
+
+	if dbReady && getEnv("DB_PREPARED_STATEMENTS", "true") == "true" {
+		if err := prepareStatements(); err != nil {
+			log.Printf("⚠️  Prepared statement warning: %v", err)
+		} else {
+			log.Println("✓ Prepared statements ready")
+		}
+	}
+}
+
+// prepareStatements parses the user queries once so handlers skip the
+// per-request parse on the server side. On failure the handlers fall back
+// to unprepared queries.
+func prepareStatements() error {
+	selectStmt, err := db.Prepare(store.SelectUsersQuery)
+	if err != nil {
+		return err
+	}
+	insertStmt, err := db.Prepare(store.InsertUserQuery)
+	if err != nil {
+		selectStmt.Close()
+		return err
+	}
+
+	selectUsersStmt, insertUserStmt = selectStmt, insertStmt
+	return nil
+}
+
+// timeoutMiddleware bounds the request context so long-running compute and
+// database queries observe the deadline and bail out early.
+func timeoutMiddleware(timeout time.Duration) ghttp.HandlerFunc {
+	return func(r *ghttp.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		r.SetCtx(ctx)
+		r.Middleware.Next()
+	}
+}
+
+// inflightMiddleware counts the request as in flight until the handlers
+// after it have returned. It is bound globally like headersMiddleware.
+func inflightMiddleware(r *ghttp.Request) {
+	requests.Start()
+	defer requests.Done()
+	r.Middleware.Next()
+}
+
+// headersMiddleware sets the baseline response headers shared by every
+// framework. It is bound globally so unmatched routes get them too.
+func headersMiddleware(r *ghttp.Request) {
+	api.SetBaselineHeaders(r.Response.Header(), "goframe")
+	r.Middleware.Next()
+}
+
+// authMiddleware answers 401 to requests without a valid bearer token,
+// except those jwtauth.Exempt lets through. It is bound globally after
+// headersMiddleware, so unmatched routes need a token too.
+func authMiddleware(r *ghttp.Request) {
+	if jwtauth.Exempt(r.URL.Path) {
+		r.Middleware.Next()
+		return
+	}
+	if err := authVerifier.Check(r.Header.Get("Authorization")); err != nil {
+		r.Response.Header().Set("WWW-Authenticate", jwtauth.Challenge)
+		writeError(r, http.StatusUnauthorized, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	r.Middleware.Next()
+}
+
+func rootHandler(r *ghttp.Request) {
+	respondJSON(r, http.StatusOK, api.RootResponse{
+		Service:       "Weather Analytics Service",
+		Framework:     "GoFrame",
+		Version:       "1.0.0",
+		Status:        "running",
+		UptimeSeconds: int(time.Since(startTime).Seconds()),
+	})
+}
+
+func healthHandler(r *ghttp.Request) {
+	uptimeMs := time.Since(startTime).Milliseconds()
+	respondJSON(r, http.StatusOK, api.HealthResponse{
+		Status:        "healthy",
+		Framework:     "goframe",
+		Gomaxprocs:    runtime.GOMAXPROCS(0),
+		NumCPU:        runtime.NumCPU(),
+		UptimeSeconds: uptimeMs / 1000,
+		UptimeMs:      uptimeMs,
+		Timestamp:     time.Now().UnixMilli(),
+	})
+}
+
+// healthDetailedHandler pings the database and, when configured, the weather
+// upstream, reporting each one's latency and an overall status. It answers
+// 503 when the database is down.
+func healthDetailedHandler(r *ghttp.Request) {
+	probes := []health.Probe{health.Database(db, dbReady)}
+	if weatherUpstream != nil {
+		probes = append(probes, health.Upstream(weatherUpstream))
+	}
+
+	report := health.Run(r.Context(), healthDegradedAfter, probes...)
+	report.Framework = "goframe"
+	respondJSON(r, report.HTTPStatus(), report)
+}
+
+// versionHandler reports the build metadata so benchmark results can be
+// traced back to the exact binary.
+func versionHandler(r *ghttp.Request) {
+	respondJSON(r, http.StatusOK, api.NewVersionResponse("goframe"))
+}
+
+// authToken mints a token signed with JWT_SECRET for the sub query
+// parameter, valid for ttl_seconds, so load generators can authenticate when
+// REQUIRE_AUTH=true. It is a benchmark helper: anyone who can reach it can
+// get a token.
+func authToken(r *ghttp.Request) {
+	ttl, err := clampedIntParam(r, "ttl_seconds", jwtauth.DefaultTTLSeconds, 1, jwtauth.MaxTTLSeconds)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	subject := r.GetQuery("sub").String()
+	if subject == "" {
+		subject = jwtauth.DefaultSubject
+	}
+	token, err := authVerifier.Mint(subject, time.Duration(ttl)*time.Second)
+	if err != nil {
+		writeError(r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	respondJSON(r, http.StatusOK, token)
+}
+
+// routesHandler lists every method and path registered on the server, so the
+// harness can check that all frameworks expose the same API before a run.
+func routesHandler(s *ghttp.Server) ghttp.HandlerFunc {
+	return func(r *ghttp.Request) {
+		var routes []api.Route
+		for _, item := range s.GetRoutes() {
+			if item.Type == ghttp.HandlerTypeMiddleware || item.Type == ghttp.HandlerTypeHook {
+				continue
+			}
+			routes = append(routes, api.Route{Method: item.Method, Path: item.Route})
+		}
+		respondJSON(r, http.StatusOK, api.NewRoutesResponse("goframe", routes))
+	}
+}
+
+// unroutedHandler answers the 404 goframe gives a request no route serves:
+// 405 when the path is routed for other methods, since goframe doesn't tell
+// the two apart, and 404 otherwise. A handler's own 404 body is left alone.
+func unroutedHandler(s *ghttp.Server) ghttp.HandlerFunc {
+	var (
+		once   sync.Once
+		routed map[string]bool
+	)
+	return func(r *ghttp.Request) {
+		if r.Response.BufferLength() > 0 {
+			return
+		}
+		// Routes are bound when the server starts, so collect them on first use
+		once.Do(func() {
+			routed = make(map[string]bool)
+			for _, item := range s.GetRoutes() {
+				if item.Type != ghttp.HandlerTypeMiddleware && item.Type != ghttp.HandlerTypeHook {
+					routed[item.Route] = true
+				}
+			}
+		})
+		if routed[r.URL.Path] {
+			writeError(r, http.StatusMethodNotAllowed, api.ErrorResponse{Message: "method not allowed"})
+			return
+		}
+		writeError(r, http.StatusNotFound, api.ErrorResponse{Message: "not found"})
+	}
+}
+
+// internalError answers a panic GoFrame recovered, replacing the panic
+// value GoFrame wrote as the body. A 500 a handler wrote itself leaves no
+// request error and is kept as written.
+func internalError(r *ghttp.Request) {
+	if r.GetError() == nil {
+		return
+	}
+	r.Response.ClearBuffer()
+	writeError(r, http.StatusInternalServerError, api.ErrorResponse{Message: "internal server error"})
+}
+
+// readyHandler is the readiness probe. Unlike healthHandler, which only
+// reports that the process is alive, it fails while the database can't be
+// reached so orchestrators stop routing traffic to this instance.
+func readyHandler(r *ghttp.Request) {
+	pingCtx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	if db == nil {
+		respondJSON(r, http.StatusServiceUnavailable, g.Map{
+			"status":            "not_ready",
+			"framework":         "goframe",
+			"failed_dependency": "database",
+			"error":             "database not initialized",
+		})
+		return
+	}
+
+	if err := db.PingContext(pingCtx); err != nil {
+		respondJSON(r, http.StatusServiceUnavailable, g.Map{
+			"status":            "not_ready",
+			"framework":         "goframe",
+			"failed_dependency": "database",
+			"error":             err.Error(),
+		})
+		return
+	}
+
+	respondJSON(r, http.StatusOK, g.Map{
+		"status":       "ready",
+		"framework":    "goframe",
+		"dependencies": g.Map{"database": "ok"},
+	})
+}
+
+// metricsHandler reports process-level resource usage so throughput can be
+// correlated with memory pressure and CPU time without an external agent.
+func metricsHandler(r *ghttp.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	var usage syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &usage)
+	userSeconds := float64(usage.Utime.Nano()) / 1e9
+	systemSeconds := float64(usage.Stime.Nano()) / 1e9
+
+	respondJSON(r, http.StatusOK, g.Map{
+		"framework":        "goframe",
+		"uptime_seconds":   int(time.Since(startTime).Seconds()),
+		"goroutines":       runtime.NumGoroutine(),
+		"gomaxprocs":       runtime.GOMAXPROCS(0),
+		"num_cpu":          runtime.NumCPU(),
+		"websockets":       wsServer.Active(),
+		"current_inflight": requests.Current(),
+		"peak_inflight":    requests.Peak(),
+		"memory": g.Map{
+			"alloc_bytes":       mem.Alloc,
+			"total_alloc_bytes": mem.TotalAlloc,
+			"sys_bytes":         mem.Sys,
+			"num_gc":            mem.NumGC,
+			"pause_total_ns":    mem.PauseTotalNs,
+		},
+		"cpu": g.Map{
+			"user_seconds":   userSeconds,
+			"system_seconds": systemSeconds,
+			"total_seconds":  userSeconds + systemSeconds,
+		},
+	})
+}
+
+func analyticsHeavy(r *ghttp.Request) {
+	p, err := heavyParams(r)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	warmupRuns, err := clampedIntParam(r, "warmup", 0, 0, compute.MaxWarmup)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	pad, err := padParam(r)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// A client already holding the result for these parameters needs
+	// nothing recomputed or resent
+	if etag, ok := compute.KnownETag(p); ok && notModified(r, etag) {
+		return
+	}
+
+	warmup, err := compute.Warmup(r.Context(), p, warmupRuns)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(r, status, api.ErrorResponse{Message: message})
+		return
+	}
+	measurement := compute.StartHeapMeasurement(boolParam(r, "gc"))
+	result, err := compute.HeavyCompute(r.Context(), p)
+	heapDelta := measurement.Done()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(r, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	if notModified(r, compute.ETag(result.ResultHash)) {
+		return
+	}
+	resp := g.Map{
+		"endpoint":    "heavy_analytics",
+		"framework":   "goframe",
+		"result_hash": result.ResultHash,
+		"total_sum":   result.TotalSum,
+		"matrix_size": result.MatrixSize,
+		"iterations":  result.Iterations,
+		"kernel":      result.Kernel,
+		"elapsed_ms":  result.ElapsedMs,
+
+		"estimated_joules":    result.EstimatedJoules,
+		"estimated_co2_grams": result.EstimatedCO2Grams,
+	}
+	if result.Allocations != nil {
+		resp["allocations"] = result.Allocations
+	}
+	msg := computepb.FromResult("heavy_analytics", "goframe", result, pad)
+	if heapDelta != nil {
+		resp["heap"] = heapDelta
+	}
+	if warmup != nil {
+		resp["warmup"] = warmup
+	}
+	if pad != "" {
+		resp["padding"] = pad
+	}
+	respondCompute(r, resp, msg)
+}
+
+func analyticsLight(r *ghttp.Request) {
+	pad, err := padParam(r)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	start := time.Now()
+
+	var result int64
+	for i := 0; i < 1000; i++ {
+		result += int64(i * i)
+	}
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(r, http.StatusOK, api.LightAnalyticsResponse{
+		Endpoint:  "light_analytics",
+		Framework: "goframe",
+		Result:    result,
+		ElapsedMs: elapsedMs,
+		Padding:   pad,
+	})
+}
+
+func analyticsMedium(r *ghttp.Request) {
+	p, err := computeParams(r, 2000, 3)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	warmupRuns, err := clampedIntParam(r, "warmup", 0, 0, compute.MaxWarmup)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	pad, err := padParam(r)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// A client already holding the result for these parameters needs
+	// nothing recomputed or resent
+	if etag, ok := compute.KnownETag(p); ok && notModified(r, etag) {
+		return
+	}
+
+	warmup, err := compute.Warmup(r.Context(), p, warmupRuns)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(r, status, api.ErrorResponse{Message: message})
+		return
+	}
+	measurement := compute.StartHeapMeasurement(boolParam(r, "gc"))
+	result, err := compute.HeavyCompute(r.Context(), p)
+	heapDelta := measurement.Done()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(r, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	if notModified(r, compute.ETag(result.ResultHash)) {
+		return
+	}
+	resp := g.Map{
+		"endpoint":    "medium_analytics",
+		"framework":   "goframe",
+		"result_hash": result.ResultHash,
+		"total_sum":   result.TotalSum,
+		"matrix_size": result.MatrixSize,
+		"iterations":  result.Iterations,
+		"kernel":      result.Kernel,
+		"elapsed_ms":  result.ElapsedMs,
+
+		"estimated_joules":    result.EstimatedJoules,
+		"estimated_co2_grams": result.EstimatedCO2Grams,
+	}
+	if result.Allocations != nil {
+		resp["allocations"] = result.Allocations
+	}
+	msg := computepb.FromResult("medium_analytics", "goframe", result, pad)
+	if heapDelta != nil {
+		resp["heap"] = heapDelta
+	}
+	if warmup != nil {
+		resp["warmup"] = warmup
+	}
+	if pad != "" {
+		resp["padding"] = pad
+	}
+	respondCompute(r, resp, msg)
+}
+
+// analyticsMemory allocates many short-lived objects to keep the garbage
+// collector busy, reporting the allocations and GC pauses incurred.
+func analyticsMemory(r *ghttp.Request) {
+	p, err := memoryParams(r)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	measurement := compute.StartHeapMeasurement(boolParam(r, "gc"))
+	result, err := compute.MemoryPressure(r.Context(), p)
+	heapDelta := measurement.Done()
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(r, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	resp := g.Map{
+		"endpoint":    "memory_analytics",
+		"framework":   "goframe",
+		"objects":     result.Objects,
+		"object_size": result.ObjectSize,
+		"checksum":    result.Checksum,
+		"elapsed_ms":  result.ElapsedMs,
+		"allocations": result.Allocations,
+	}
+	if heapDelta != nil {
+		resp["heap"] = heapDelta
+	}
+	respondJSON(r, http.StatusOK, resp)
+}
+
+// analyticsFanout starts many goroutines that each do almost no work, to
+// measure goroutine creation and scheduling overhead as its own workload.
+func analyticsFanout(r *ghttp.Request) {
+	tasks, err := clampedIntParam(r, "tasks", 1000, 1, compute.MaxFanoutTasks)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	result, err := compute.Fanout(r.Context(), tasks)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(r, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(r, http.StatusOK, g.Map{
+		"endpoint":        "fanout_analytics",
+		"framework":       "goframe",
+		"tasks":           result.Tasks,
+		"sum":             result.Sum,
+		"elapsed_ms":      result.ElapsedMs,
+		"ns_per_task":     result.NsPerTask,
+		"peak_goroutines": result.PeakGoroutines,
+	})
+}
+
+// analyticsCompare runs every compute kernel at the same size and
+// iterations and reports their timings relative to each other, to check
+// that each kernel scales with its complexity.
+func analyticsCompare(r *ghttp.Request) {
+	size, err := clampedIntParam(r, "size", 200, 1, compute.MaxMatmulSize)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	iterations, err := clampedIntParam(r, "iterations", 5, 1, compute.MaxIterations)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := compute.CheckCompare(size, iterations); err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	comparison, err := compute.Compare(r.Context(), size, iterations)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(r, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(r, http.StatusOK, g.Map{
+		"endpoint":   "compare_analytics",
+		"framework":  "goframe",
+		"size":       comparison.Size,
+		"iterations": comparison.Iterations,
+		"total_ops":  comparison.TotalOps,
+		"kernels":    comparison.Kernels,
+	})
+}
+
+// computeStats summarizes the wall times of every heavy computation this
+// process has run since startup or the last reset.
+func computeStats(r *ghttp.Request) {
+	respondJSON(r, http.StatusOK, g.Map{
+		"endpoint":  "compute_stats",
+		"framework": "goframe",
+		"heavy":     compute.HeavyStats(),
+	})
+}
+
+// resetComputeStats returns the summary and clears it, so one call closes a
+// benchmark phase and starts the next.
+func resetComputeStats(r *ghttp.Request) {
+	respondJSON(r, http.StatusOK, g.Map{
+		"endpoint":  "compute_stats",
+		"framework": "goframe",
+		"heavy":     compute.ResetHeavyStats(),
+		"reset":     true,
+	})
+}
+
+// analyticsBatch runs a list of compute jobs with bounded concurrency and
+// returns their results in request order.
+func analyticsBatch(r *ghttp.Request) {
+	var jobs []compute.Params
+	if err := json.NewDecoder(r.Body).Decode(&jobs); err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	if len(jobs) == 0 || len(jobs) > compute.MaxBatchJobs {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{
+			Message: fmt.Sprintf("batch must contain between 1 and %d jobs", compute.MaxBatchJobs),
+		})
+		return
+	}
+
+	for i := range jobs {
+		if err := jobs[i].Validate(); err != nil {
+			writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error(), Details: map[string]int{"index": i}})
+			return
+		}
+	}
+
+	results, err := compute.RunBatch(r.Context(), jobs, runtime.NumCPU(), nil)
+	if err != nil {
+		status, message := compute.ErrorStatus(err)
+		writeError(r, status, api.ErrorResponse{Message: message})
+		return
+	}
+
+	respondJSON(r, http.StatusOK, results)
+}
+
+// analyticsStream runs the heavy workload and streams an SSE "progress" event
+// after every iteration, finishing with a "result" event carrying the
+// ComputeResult.
+func analyticsStream(r *ghttp.Request) {
+	p, err := computeParams(r, 5000, 5)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// BufferWriter.Flush pushes each event out of GoFrame's response buffer
+	w := r.Response.BufferWriter
+	sse.SetHeaders(w)
+	if enableTrailers {
+		defer trailer.Declare(w).Finish()
+	}
+	w.WriteHeader(http.StatusOK)
+
+	result, err := compute.HeavyComputeWithProgress(r.Context(), p, func(progress compute.Progress) {
+		sse.WriteEvent(w, "progress", progress)
+	})
+	if err != nil {
+		// A cancelled context means the client went away; nobody is listening
+		if !errors.Is(err, context.Canceled) {
+			sse.WriteEvent(w, "error", api.ErrorResponse{Message: "compute timeout"}.ForStatus(http.StatusServiceUnavailable))
+		}
+		return
+	}
+
+	sse.WriteEvent(w, "result", result)
+}
+
+func weatherExternal(r *ghttp.Request) {
+	if weatherUpstream != nil {
+		weatherExternalUpstream(r)
+		return
+	}
+
+	delayMs := parseIntParam(r, "delay_ms", 100)
+	start := time.Now()
+
+	// Stop waiting as soon as the client goes away, so abandoned requests
+	// don't hold a goroutine for the full delay
+	timer := time.NewTimer(time.Duration(delayMs) * time.Millisecond)
+	select {
+	case <-r.Context().Done():
+		timer.Stop()
+		writeError(r, compute.StatusClientClosedRequest, api.ErrorResponse{Message: "client closed request"})
+		return
+	case <-timer.C:
+	}
+
+	weatherData := g.Map{
+		"temperature": 25.5,
+		"humidity":    65,
+		"wind_speed":  12.3,
+		"conditions":  "Partly Cloudy",
+		"location":    "Colombo, LK",
+	}
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(r, http.StatusOK, g.Map{
+		"endpoint":           "external_api",
+		"framework":          "goframe",
+		"data":               weatherData,
+		"simulated_delay_ms": delayMs,
+		"elapsed_ms":         elapsedMs,
+	})
+}
+
+// weatherExternalUpstream proxies the configured upstream's JSON through,
+// exercising a real HTTP client instead of the simulated delay.
+func weatherExternalUpstream(r *ghttp.Request) {
+	start := time.Now()
+
+	data, err := weatherUpstream.Fetch(r.Context())
+	if err != nil {
+		writeError(r, http.StatusBadGateway, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(r, http.StatusOK, g.Map{
+		"endpoint":     "external_api",
+		"framework":    "goframe",
+		"data":         data,
+		"upstream_url": weatherUpstream.URL,
+		"elapsed_ms":   elapsedMs,
+	})
+}
+
+func weatherFetch(r *ghttp.Request) {
+	if list := r.GetQuery("cities").String(); list != "" {
+		weatherFetchMany(r, list)
+		return
+	}
+
+	city := r.GetQuery("city").String()
+	if city == "" {
+		city = "Colombo"
+	}
+	start := time.Now()
+
+	weatherData, source, coalesced := weatherFetcher.Fetch(r.Context(), city)
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(r, http.StatusOK, g.Map{
+		"endpoint":   "weather_fetch",
+		"framework":  "goframe",
+		"city":       city,
+		"data":       weatherData,
+		"source":     source,
+		"coalesced":  coalesced,
+		"elapsed_ms": elapsedMs,
+	})
+}
+
+// weatherFetchMany looks up every city of a comma-separated list
+// concurrently, at most parallelism at a time, and reports each city next to
+// the total elapsed time.
+func weatherFetchMany(r *ghttp.Request, list string) {
+	cities, err := weather.ParseCities(list)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	parallelism, err := clampedIntParam(r, "parallelism", weather.DefaultParallelism, 1, weather.MaxCities)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	start := time.Now()
+
+	reports := weatherFetcher.FetchMany(r.Context(), cities, parallelism)
+
+	elapsedMs := time.Since(start).Milliseconds()
+
+	respondJSON(r, http.StatusOK, g.Map{
+		"endpoint":    "weather_fetch",
+		"framework":   "goframe",
+		"cities":      reports,
+		"count":       len(reports),
+		"parallelism": parallelism,
+		"elapsed_ms":  elapsedMs,
+	})
+}
+
+// fileIO writes a temp file, fsyncs it and reads it back, the disk
+// counterpart to the HTTP and database I/O endpoints.
+func fileIO(r *ghttp.Request) {
+	size, err := clampedIntParam(r, "bytes", diskio.DefaultBytes, 1, diskio.MaxBytes)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	result, err := diskio.RoundTrip(r.Context(), size)
+	if err != nil {
+		writeError(r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(r, http.StatusOK, g.Map{
+		"endpoint":      "file_io",
+		"framework":     "goframe",
+		"bytes_written": result.BytesWritten,
+		"bytes_read":    result.BytesRead,
+		"sha256":        result.SHA256,
+		"elapsed_ms":    result.ElapsedMs,
+		"write_us":      result.WriteUs,
+		"fsync_us":      result.FsyncUs,
+		"read_us":       result.ReadUs,
+	})
+}
+
+// benchJSON builds a nested payload of the requested depth and width and
+// serializes it with the configured encoder, reporting the encoded size and
+// marshalling time. Only the summary is sent, so the measurement is pure
+// serialization with no network write of the payload itself.
+func benchJSON(r *ghttp.Request) {
+	depth, err := clampedIntParam(r, "depth", 3, 0, jsonenc.MaxPayloadDepth)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	width, err := clampedIntParam(r, "width", 10, 1, jsonenc.MaxPayloadWidth)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	shape := r.GetQuery("shape").String()
+	if shape == "" {
+		shape = jsonenc.ShapeStruct
+	}
+
+	payload, nodes, err := jsonenc.BuildPayload(depth, width, shape)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	result, err := jsonenc.MarshalTimed(encoder, payload)
+	if err != nil {
+		writeError(r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(r, http.StatusOK, g.Map{
+		"endpoint":   "json_bench",
+		"framework":  "goframe",
+		"encoder":    encoder.Name(),
+		"shape":      shape,
+		"depth":      depth,
+		"width":      width,
+		"nodes":      nodes,
+		"bytes":      result.Bytes,
+		"marshal_us": result.MarshalUs,
+	})
+}
+
+func getUsers(r *ghttp.Request) {
+	if !requireDB(r) {
+		return
+	}
+
+	limit, err := clampedIntParam(r, "limit", 100, 1, 1000)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	offset, err := clampedIntParam(r, "offset", 0, 0, math.MaxInt32)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	repeat, err := clampedIntParam(r, "repeat", 1, 1, store.MaxQueryRepeat)
+	if err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	// The extra runs go first, so the page served is the last one read
+	start := time.Now()
+	if err := repeatUsers(r.Context(), limit, offset, repeat-1); err != nil {
+		writeError(r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	reqCtx := r.Context()
+	var rows *sql.Rows
+	if selectUsersStmt != nil {
+		rows, err = selectUsersStmt.QueryContext(reqCtx, limit, offset)
+	} else {
+		rows, err = db.QueryContext(reqCtx, store.SelectUsersQuery, limit, offset)
+	}
+	if err != nil {
+		writeError(r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	if csvstream.Requested(r.URL.Query().Get("format"), r.Header.Get("Accept")) {
+		writeUsersCSV(r.Response.BufferWriter, rows)
+		return
+	}
+
+	users := make([]User, 0, limit)
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			continue
+		}
+		users = append(users, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		writeError(r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	queryMs := float64(time.Since(start).Nanoseconds()) / 1e6
+
+	resp := g.Map{
+		"users":    users,
+		"count":    len(users),
+		"limit":    limit,
+		"offset":   offset,
+		"prepared": selectUsersStmt != nil,
+	}
+	if repeat > 1 {
+		resp["repeat"] = repeat
+		resp["query_ms"] = queryMs
+	}
+	respondJSON(r, http.StatusOK, resp)
+}
+
+// repeatUsers reads the same page of users times more and discards it, so
+// ?repeat= can weight a request towards the database.
+func repeatUsers(ctx context.Context, limit, offset, times int) error {
+	for i := 0; i < times; i++ {
+		var rows *sql.Rows
+		var err error
+		if selectUsersStmt != nil {
+			rows, err = selectUsersStmt.QueryContext(ctx, limit, offset)
+		} else {
+			rows, err = db.QueryContext(ctx, store.SelectUsersQuery, limit, offset)
+		}
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var u User
+			rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeUsersCSV streams rows to w as a CSV attachment, writing each user as
+// it is read instead of collecting the page first. The status line goes out
+// before the rows, so a query failing mid-stream leaves the export truncated.
+func writeUsersCSV(w http.ResponseWriter, rows *sql.Rows) {
+	csvstream.SetHeaders(w.Header(), csvstream.UsersFilename)
+	w.WriteHeader(http.StatusOK)
+	out, err := csvstream.NewUsers(w)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			continue
+		}
+		if err := out.Write(u.ID, u.Name, u.Email, u.CreatedAt); err != nil {
+			// The client went away; stop reading rows nobody will receive
+			return
+		}
+	}
+	out.Flush()
+}
+
+func createUser(r *ghttp.Request) {
+	if !requireDB(r) {
+		return
+	}
+
+	var input struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+
+	// Decoded with encoding/json rather than r.Parse so malformed bodies
+	// produce the same error messages as the other frameworks
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	reqCtx := r.Context()
+	var row *sql.Row
+	if insertUserStmt != nil {
+		row = insertUserStmt.QueryRowContext(reqCtx, input.Name, input.Email)
+	} else {
+		row = db.QueryRowContext(reqCtx, store.InsertUserQuery, input.Name, input.Email)
+	}
+
+	var user User
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+
+	if err != nil {
+		writeError(r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(r, http.StatusCreated, createdUser{User: user, Prepared: insertUserStmt != nil})
+}
+
+// bulkCreateUsers inserts up to store.MaxBulkUsers users with a single
+// multi-row statement, rolling back the whole batch on any constraint
+// violation.
+func bulkCreateUsers(r *ghttp.Request) {
+	if !requireDB(r) {
+		return
+	}
+
+	var input []store.NewUser
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	if len(input) == 0 || len(input) > store.MaxBulkUsers {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{
+			Message: fmt.Sprintf("bulk insert must contain between 1 and %d users", store.MaxBulkUsers),
+		})
+		return
+	}
+
+	if i := store.DuplicateEmailIndex(input); i >= 0 {
+		writeError(r, http.StatusBadRequest, api.ErrorResponse{Message: "duplicate email in request", Details: map[string]int{"index": i}})
+		return
+	}
+
+	users, err := insertUsers(r.Context(), input)
+	if err != nil {
+		if index, ok := store.ConstraintViolation(err, input); ok {
+			resp := api.ErrorResponse{Message: err.Error()}
+			if index >= 0 {
+				resp.Details = map[string]int{"index": index}
+			}
+			writeError(r, http.StatusBadRequest, resp)
+			return
+		}
+		writeError(r, http.StatusInternalServerError, api.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	respondJSON(r, http.StatusCreated, g.Map{
+		"users": users,
+		"count": len(users),
+	})
+}
+
+// dbStats reports the connection pool's state and cumulative counters, to
+// show whether requests are waiting on the pool during the DB benchmark.
+func dbStats(r *ghttp.Request) {
+	if !requireDB(r) {
+		return
+	}
+	respondJSON(r, http.StatusOK, g.Map{
+		"endpoint":  "db_stats",
+		"framework": "goframe",
+		"pool":      store.Pool(db),
+	})
+}
+
+// insertUsers runs the bulk INSERT inside a transaction and returns the
+// created rows. Any error rolls the transaction back.
+func insertUsers(ctx context.Context, input []store.NewUser) ([]User, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query, args := store.BulkInsertUsersQuery(input)
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]User, 0, len(input))
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// requireDB writes a 503 and returns false when the database never connected.
+func requireDB(r *ghttp.Request) bool {
+	if !dbReady {
+		writeError(r, http.StatusServiceUnavailable, api.ErrorResponse{Message: "database unavailable"})
+		return false
+	}
+	return true
+}
+
+func parseIntParam(r *ghttp.Request, param string, defaultValue int) int {
+	if val := r.GetQuery(param).String(); val != "" {
+		if intVal, err := strconv.Atoi(val); err == nil {
+			return intVal
+		}
+	}
+	return defaultValue
+}
+
+// computeParams reads kernel, size, iterations, goroutines and seed from the
+// query string, applying the endpoint's defaults and the kernel's bounds.
+func computeParams(r *ghttp.Request, defaultSize, defaultIterations int) (compute.Params, error) {
+	kernel, err := compute.ParseKernel(r.GetQuery("kernel").String())
+	if err != nil {
+		return compute.Params{}, err
+	}
+
+	size, err := clampedIntParam(r, "size", kernel.DefaultSize(defaultSize), 1, kernel.MaxSize())
+	if err != nil {
+		return compute.Params{}, err
+	}
+	iterations, err := clampedIntParam(r, "iterations", defaultIterations, 1, compute.MaxIterations)
+	if err != nil {
+		return compute.Params{}, err
+	}
+	goroutines, err := clampedIntParam(r, "goroutines", 1, 1, compute.MaxGoroutines)
+	if err != nil {
+		return compute.Params{}, err
+	}
+	seed, err := clampedIntParam(r, "seed", 0, 0, compute.MaxSeed)
+	if err != nil {
+		return compute.Params{}, err
+	}
+
+	return compute.Params{Kernel: kernel, Size: size, Iterations: iterations, Goroutines: goroutines, Seed: seed}, nil
+}
+
+// memoryParams reads the object count and size for analyticsMemory.
+func memoryParams(r *ghttp.Request) (compute.MemoryParams, error) {
+	objects, err := clampedIntParam(r, "objects", 10000, 1, compute.MaxMemoryObjects)
+	if err != nil {
+		return compute.MemoryParams{}, err
+	}
+	size, err := clampedIntParam(r, "size", 1024, 1, compute.MaxMemoryObjectSize)
+	if err != nil {
+		return compute.MemoryParams{}, err
+	}
+
+	p := compute.MemoryParams{Objects: objects, Size: size}
+	if err := p.Validate(); err != nil {
+		return compute.MemoryParams{}, err
+	}
+	return p, nil
+}
+
+// heavyParams reads the heavy job from a JSON body on POST requests sent as
+// application/json, and from the query string otherwise. Both paths share
+// the defaults and bounds.
+func heavyParams(r *ghttp.Request) (compute.Params, error) {
+	if r.Method != http.MethodPost || !params.IsJSON(r.Header.Get("Content-Type")) {
+		return computeParams(r, 5000, 5)
+	}
+	return compute.DecodeParams(r.Body, compute.Params{Size: 5000, Iterations: 5})
+}
+
+// clampedIntParam is parseIntParam with bounds: out-of-range values are
+// rejected rather than handed to the workload.
+func clampedIntParam(r *ghttp.Request, param string, defaultValue, minValue, maxValue int) (int, error) {
+	return params.ClampedInt(param, r.GetQuery(param).String(), defaultValue, minValue, maxValue)
+}
+
+// padParam reads pad_bytes and returns that much filler for the response's
+// padding field, or "" for none.
+func padParam(r *ghttp.Request) (string, error) {
+	n, err := clampedIntParam(r, "pad_bytes", 0, 0, filler.Max())
+	if err != nil {
+		return "", err
+	}
+	return filler.Pad(n), nil
+}
+
+// boolParam reads a boolean query flag; see params.Bool.
+func boolParam(r *ghttp.Request, param string) bool {
+	return params.Bool(r.GetQuery(param).String())
+}
+
+// notModified tags the response with etag and, when the request's
+// If-None-Match already names that tag, answers 304 with no body and returns
+// true. The 304 varies on Accept like the response it stands in for.
+func notModified(r *ghttp.Request, etag string) bool {
+	api.SetETag(r.Response.Header(), etag)
+	if !compute.MatchesETag(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	r.Response.Header().Add("Vary", "Accept")
+	r.Response.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// writeError sends an error in the api.ErrorResponse envelope shared by
+// every framework, its code defaulting to one derived from status.
+func writeError(r *ghttp.Request, status int, e api.ErrorResponse) {
+	respondJSON(r, status, e.ForStatus(status))
+}
+
+// respondJSON writes through the configured encoder rather than
+// r.Response.WriteJson so GoFrame responses use the same marshaller as the
+// other frameworks, or through MessagePack when the Accept header prefers
+// it; a 406 answers an Accept that rules out both. The body lands in
+// GoFrame's response buffer, which is flushed once the handler returns.
+func respondJSON(r *ghttp.Request, status int, data interface{}) {
+	mediaType, ok := negotiate.Select(r.Header.Get("Accept"), negotiate.Formats...)
+	if !ok {
+		status, mediaType, data = http.StatusNotAcceptable, negotiate.JSON, negotiate.NotAcceptable()
+	}
+	r.Response.Header().Add("Vary", "Accept")
+	r.Response.Header().Set("Content-Type", mediaType)
+	r.Response.WriteHeader(status)
+	negotiate.Encoder(mediaType, encoder).Marshal(r.Response.BufferWriter, data)
+}
+
+// respondCompute sends a heavy or medium result like respondJSON, or as its
+// protobuf message msg when the Accept header prefers Protocol Buffers.
+func respondCompute(r *ghttp.Request, resp g.Map, msg *computepb.ComputeResult) {
+	mediaType, _ := negotiate.Select(r.Header.Get("Accept"), negotiate.ComputeFormats...)
+	if mediaType != negotiate.Protobuf {
+		respondJSON(r, http.StatusOK, resp)
+		return
+	}
+	r.Response.Header().Add("Vary", "Accept")
+	r.Response.Header().Set("Content-Type", mediaType)
+	r.Response.WriteHeader(http.StatusOK)
+	negotiate.Proto.Marshal(r.Response.BufferWriter, msg)
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value := os.Getenv(key); value != "" {
+		if intVal, err := strconv.Atoi(value); err == nil {
+			return intVal
+		}
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"carbon-bench/apitest"
+	"carbon-bench/config"
+	"carbon-bench/jsonenc"
+	"carbon-bench/padding"
+	"carbon-bench/weather"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+func TestMain(m *testing.M) {
+	var err error
+	if encoder, err = jsonenc.New(jsonenc.Stdlib); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+var (
+	testServerOnce sync.Once
+	testServerErr  error
+	testSrv        *ghttp.Server
+)
+
+// testServer starts the server main would with authentication off, on a
+// free loopback port, with the handlers' dependencies set up and nothing
+// reaching the network. The database is never opened, so its routes answer
+// 503. g.Server() is a singleton that binds its routes on Start, so every
+// test shares the one instance.
+func testServer(t *testing.T) *ghttp.Server {
+	t.Helper()
+	testServerOnce.Do(func() {
+		os.Setenv("BENCHMARK_MODE", "true")
+		filler = padding.New(0)
+		weatherFetcher = weather.NewOpenMeteo(0, time.Second)
+		weatherFetcher.GeocodeURL = "http://127.0.0.1:1/search"
+		weatherFetcher.ForecastURL = "http://127.0.0.1:1/forecast"
+		testSrv = newServer("127.0.0.1:0", config.AuthConfig{})
+		testSrv.SetLogStdout(false)
+		testServerErr = testSrv.Start()
+	})
+	if testServerErr != nil {
+		t.Fatal(testServerErr)
+	}
+	return testSrv
+}
+
+// overWire forwards each request to s over loopback, so a check sees the
+// response a client would. GoFrame writes the status text into bodiless
+// responses such as 304, which net/http then drops from the wire.
+func overWire(t *testing.T, s *ghttp.Server) http.Handler {
+	t.Helper()
+	target, err := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", s.GetListenedPort()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httputil.NewSingleHostReverseProxy(target)
+}
+
+func TestBaselineHeadersOnEveryRoute(t *testing.T) {
+	apitest.CheckBaselineHeaders(t, testServer(t), "goframe")
+}
+
+func TestConditionalCompute(t *testing.T) {
+	apitest.CheckConditional(t, overWire(t, testServer(t)))
+}
+
+func TestUnroutedRequestsAnswerJSON(t *testing.T) {
+	apitest.CheckUnrouted(t, testServer(t))
+}
+
+func TestPanicRecoveredAsJSON(t *testing.T) {
+	handler := testServer(t)
+	// Without its fetcher the weather route dereferences nil, standing in
+	// for any handler bug. The server is shared, so the fetcher is restored.
+	fetcher := weatherFetcher
+	weatherFetcher = nil
+	t.Cleanup(func() { weatherFetcher = fetcher })
+	w := apitest.Serve(handler, http.MethodGet, "/api/v1/weather/fetch?city=Colombo", "")
+	apitest.CheckError(t, w, http.StatusInternalServerError, "internal_server_error")
+}
@@ -0,0 +1,136 @@
+// Package health measures how quickly the apps' dependencies answer, for
+// /api/v1/health/detailed, so slowness in the database or the weather
+// upstream can be told apart from slowness in the framework itself.
+// /api/v1/health stays a dependency-free liveness check.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"carbon-bench/weather"
+)
+
+// Statuses of a check and of a Report, from best to worst.
+const (
+	Healthy   = "healthy"
+	Degraded  = "degraded"
+	Unhealthy = "unhealthy"
+)
+
+// DefaultDegradedAfter is the latency beyond which a dependency that does
+// answer is reported degraded, unless HEALTH_DEGRADED_MS is set.
+const DefaultDegradedAfter = 100 * time.Millisecond
+
+// ProbeTimeout bounds each probe, so a hung dependency reports unhealthy
+// rather than holding the request.
+const ProbeTimeout = 2 * time.Second
+
+// Probe checks one dependency. A failed Required probe makes the whole
+// Report unhealthy; any other failure only degrades it.
+type Probe struct {
+	Name     string
+	Required bool
+	Run      func(ctx context.Context) error
+}
+
+// Result is the outcome of one Probe.
+type Result struct {
+	Status    string  `json:"status"`
+	Required  bool    `json:"required"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the body of /api/v1/health/detailed. The app fills in
+// Framework.
+type Report struct {
+	Status    string            `json:"status"`
+	Framework string            `json:"framework"`
+	Checks    map[string]Result `json:"checks"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// HTTPStatus is 503 for an unhealthy report and 200 otherwise, so load
+// balancers keep routing to a degraded instance.
+func (r Report) HTTPStatus() int {
+	if r.Status == Unhealthy {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// Run runs every probe concurrently and rates each by its latency against
+// degradedAfter.
+func Run(ctx context.Context, degradedAfter time.Duration, probes ...Probe) Report {
+	results := make([]Result, len(probes))
+	var wg sync.WaitGroup
+	for i, p := range probes {
+		wg.Add(1)
+		go func(i int, p Probe) {
+			defer wg.Done()
+			results[i] = run(ctx, p, degradedAfter)
+		}(i, p)
+	}
+	wg.Wait()
+
+	report := Report{Status: Healthy, Checks: make(map[string]Result, len(probes)), Timestamp: time.Now().UnixMilli()}
+	for i, p := range probes {
+		r := results[i]
+		report.Checks[p.Name] = r
+		switch {
+		case r.Status == Unhealthy && p.Required:
+			report.Status = Unhealthy
+		case r.Status != Healthy && report.Status == Healthy:
+			report.Status = Degraded
+		}
+	}
+	return report
+}
+
+func run(ctx context.Context, p Probe, degradedAfter time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := p.Run(ctx)
+	latency := time.Since(start)
+
+	r := Result{Status: Healthy, Required: p.Required, LatencyMs: float64(latency.Microseconds()) / 1000}
+	switch {
+	case err != nil:
+		r.Status, r.Error = Unhealthy, err.Error()
+	case latency > degradedAfter:
+		r.Status = Degraded
+	}
+	return r
+}
+
+// errDatabaseUnavailable matches the 503 the database endpoints answer
+// when the app started without a database.
+var errDatabaseUnavailable = errors.New("database unavailable")
+
+// Database probes db with a ping. ready is false when the app started
+// without a database, which then fails without a ping, since the database
+// endpoints refuse requests regardless.
+func Database(db *sql.DB, ready bool) Probe {
+	return Probe{Name: "database", Required: true, Run: func(ctx context.Context) error {
+		if !ready || db == nil {
+			return errDatabaseUnavailable
+		}
+		return db.PingContext(ctx)
+	}}
+}
+
+// Upstream probes the weather upstream with the same request the external
+// endpoint makes.
+func Upstream(u *weather.Upstream) Probe {
+	return Probe{Name: "weather_upstream", Run: func(ctx context.Context) error {
+		_, err := u.Fetch(ctx)
+		return err
+	}}
+}
@@ -0,0 +1,162 @@
+// Package idempotency remembers the responses to requests carrying an
+// Idempotency-Key header, so the framework apps can replay them to a client
+// that retries instead of performing the write again.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Header is the request header naming an idempotency key, and ReplayedHeader
+// is set to "true" on responses replayed from the store.
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// MaxKeyLength bounds the keys a client may send.
+const MaxKeyLength = 255
+
+// maxEntries caps the keys held at once, so a run sending a fresh key with
+// every request can't grow the store without bound. Once it's full, requests
+// with new keys are processed without being recorded.
+const maxEntries = 100000
+
+const sweepInterval = time.Minute
+
+// ErrMismatch is returned by Claim when a key is reused with a different
+// request body.
+var ErrMismatch = errors.New("idempotency key reused with a different request body")
+
+// Response is a recorded response, replayed to repeats of its request.
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+type entry struct {
+	bodyHash [sha256.Size]byte
+	// done is closed once the first request has completed or been released
+	done    chan struct{}
+	resp    *Response
+	expires time.Time
+}
+
+// Store holds keys and their responses for a fixed time after the original
+// request completed.
+type Store struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+// New returns a store keeping responses for ttl. It returns nil when ttl is
+// not positive; callers skip the middleware for a nil *Store.
+func New(ttl time.Duration) *Store {
+	if ttl <= 0 {
+		return nil
+	}
+	return &Store{
+		ttl:       ttl,
+		entries:   make(map[string]*entry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Claim looks key up for a request with the given body. It returns the
+// recorded response for a completed repeat, or ErrMismatch if key was first
+// used with a different body. A repeat that arrives while the original is
+// still running waits for it, or until ctx ends. When Claim returns neither a
+// response nor an error, the caller owns key and must call Complete or
+// Release once it's done; ok is false if the store is full, in which case
+// the caller processes the request without recording it.
+func (s *Store) Claim(ctx context.Context, key string, body []byte) (resp *Response, ok bool, err error) {
+	hash := sha256.Sum256(body)
+	for {
+		s.mu.Lock()
+		now := time.Now()
+		s.sweep(now)
+
+		e, found := s.entries[key]
+		if found && e.resp != nil && now.After(e.expires) {
+			delete(s.entries, key)
+			found = false
+		}
+		if !found {
+			if len(s.entries) >= maxEntries {
+				s.mu.Unlock()
+				return nil, false, nil
+			}
+			s.entries[key] = &entry{bodyHash: hash, done: make(chan struct{})}
+			s.mu.Unlock()
+			return nil, true, nil
+		}
+		if e.bodyHash != hash {
+			s.mu.Unlock()
+			return nil, false, ErrMismatch
+		}
+		if e.resp != nil {
+			s.mu.Unlock()
+			return e.resp, false, nil
+		}
+		done := e.done
+		s.mu.Unlock()
+
+		// The original is in flight; once it finishes the loop either
+		// replays its response or, if it was released, claims the key
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
+// Complete records resp as the response for a key claimed with Claim. Server
+// errors aren't recorded, so a client retrying after one gets a fresh attempt.
+func (s *Store) Complete(key string, resp *Response) {
+	if resp.Status >= http.StatusInternalServerError {
+		s.Release(key)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok && e.resp == nil {
+		e.resp = resp
+		e.expires = time.Now().Add(s.ttl)
+		close(e.done)
+	}
+}
+
+// Release gives up a key claimed with Claim without recording a response.
+func (s *Store) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok && e.resp == nil {
+		delete(s.entries, key)
+		close(e.done)
+	}
+}
+
+func (s *Store) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) <= sweepInterval {
+		return
+	}
+	for k, e := range s.entries {
+		if e.resp != nil && now.After(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.lastSweep = now
+}
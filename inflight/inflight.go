@@ -0,0 +1,41 @@
+// Package inflight counts the requests a server is handling at once and
+// remembers the most it has seen, to check that a load generator reached
+// the concurrency it was configured for and to relate latency to the
+// contention the server actually saw.
+package inflight
+
+import "sync/atomic"
+
+// Gauge is a count of in-flight requests with its high-water mark. The
+// zero value is ready to use.
+type Gauge struct {
+	current atomic.Int64
+	peak    atomic.Int64
+}
+
+// Start counts a request as in flight, raising the peak when it is the
+// most seen at once.
+func (g *Gauge) Start() {
+	n := g.current.Add(1)
+	for {
+		peak := g.peak.Load()
+		if n <= peak || g.peak.CompareAndSwap(peak, n) {
+			return
+		}
+	}
+}
+
+// Done counts a request started with Start as finished.
+func (g *Gauge) Done() {
+	g.current.Add(-1)
+}
+
+// Current returns the requests in flight now.
+func (g *Gauge) Current() int64 {
+	return g.current.Load()
+}
+
+// Peak returns the most requests that were in flight at once since startup.
+func (g *Gauge) Peak() int64 {
+	return g.peak.Load()
+}
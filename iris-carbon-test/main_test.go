@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"carbon-bench/apitest"
+	"carbon-bench/config"
+	"carbon-bench/jsonenc"
+	"carbon-bench/padding"
+	"carbon-bench/weather"
+	"github.com/kataras/iris/v12"
+)
+
+func TestMain(m *testing.M) {
+	var err error
+	if encoder, err = jsonenc.New(jsonenc.Stdlib); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// testApp builds the application main would with authentication off, with
+// the handlers' dependencies set up and nothing reaching the network. The
+// database is never opened, so its routes answer 503.
+func testApp(t *testing.T) *iris.Application {
+	t.Helper()
+	t.Setenv("BENCHMARK_MODE", "true")
+	filler = padding.New(0)
+	weatherFetcher = weather.NewOpenMeteo(0, time.Second)
+	weatherFetcher.GeocodeURL = "http://127.0.0.1:1/search"
+	weatherFetcher.ForecastURL = "http://127.0.0.1:1/forecast"
+	app := newApp(config.AuthConfig{})
+	app.Logger().SetLevel("disable")
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func TestBaselineHeadersOnEveryRoute(t *testing.T) {
+	apitest.CheckBaselineHeaders(t, testApp(t), "iris")
+}
+
+func TestConditionalCompute(t *testing.T) {
+	apitest.CheckConditional(t, testApp(t))
+}
+
+func TestUnroutedRequestsAnswerJSON(t *testing.T) {
+	apitest.CheckUnrouted(t, testApp(t))
+}
+
+func TestPanicRecoveredAsJSON(t *testing.T) {
+	handler := testApp(t)
+	// Without its fetcher the weather route dereferences nil, standing in
+	// for any handler bug
+	weatherFetcher = nil
+	w := apitest.Serve(handler, http.MethodGet, "/api/v1/weather/fetch?city=Colombo", "")
+	apitest.CheckError(t, w, http.StatusInternalServerError, "internal_server_error")
+}
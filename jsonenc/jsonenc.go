@@ -0,0 +1,52 @@
+// Package jsonenc abstracts the JSON marshaller used for responses so the
+// benchmark can compare encoders without touching handler code.
+package jsonenc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Encoder writes v to w as a single newline-terminated JSON document.
+type Encoder interface {
+	Marshal(w io.Writer, v interface{}) error
+	Name() string
+}
+
+const (
+	Stdlib   = "stdlib"
+	Jsoniter = "jsoniter"
+)
+
+// New returns the encoder registered under name. An empty name selects the
+// standard library encoder.
+func New(name string) (Encoder, error) {
+	switch name {
+	case "", Stdlib:
+		return stdlibEncoder{}, nil
+	case Jsoniter:
+		return jsoniterEncoder{api: jsoniter.ConfigCompatibleWithStandardLibrary}, nil
+	}
+	return nil, fmt.Errorf("unknown JSON encoder %q (want %s or %s)", name, Stdlib, Jsoniter)
+}
+
+type stdlibEncoder struct{}
+
+func (stdlibEncoder) Marshal(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (stdlibEncoder) Name() string { return Stdlib }
+
+type jsoniterEncoder struct {
+	api jsoniter.API
+}
+
+func (e jsoniterEncoder) Marshal(w io.Writer, v interface{}) error {
+	return e.api.NewEncoder(w).Encode(v)
+}
+
+func (jsoniterEncoder) Name() string { return Jsoniter }
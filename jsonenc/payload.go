@@ -0,0 +1,119 @@
+package jsonenc
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Bounds for the JSON serialization workload.
+const (
+	MaxPayloadDepth = 10
+	MaxPayloadWidth = 100
+	MaxPayloadNodes = 200000
+)
+
+// Payload shapes: typed structs, or the map[string]interface{} values
+// (gin.H, iris.Map, g.Map) that handlers commonly respond with.
+const (
+	ShapeStruct = "struct"
+	ShapeMap    = "map"
+)
+
+type payloadNode struct {
+	ID       int            `json:"id"`
+	Name     string         `json:"name"`
+	Value    float64        `json:"value"`
+	Active   bool           `json:"active"`
+	Tags     []string       `json:"tags"`
+	Children []*payloadNode `json:"children,omitempty"`
+}
+
+// PayloadNodes returns how many nodes BuildPayload creates for depth and
+// width: a root plus width children per node for depth levels. The count
+// stops growing once it passes MaxPayloadNodes, so it can't overflow.
+func PayloadNodes(depth, width int) int {
+	total, level := 1, 1
+	for d := 0; d < depth && total <= MaxPayloadNodes; d++ {
+		level *= width
+		total += level
+	}
+	return total
+}
+
+// BuildPayload returns a tree depth levels deep below its root, with width
+// children per node, built from structs or maps according to shape ("" is
+// ShapeStruct). The content is fixed, so every framework serializes
+// byte-identical documents for the same parameters.
+func BuildPayload(depth, width int, shape string) (v interface{}, nodes int, err error) {
+	nodes = PayloadNodes(depth, width)
+	if nodes > MaxPayloadNodes {
+		return nil, 0, fmt.Errorf("depth %d and width %d make more than %d nodes", depth, width, MaxPayloadNodes)
+	}
+
+	next := 0
+	switch shape {
+	case "", ShapeStruct:
+		return buildStruct(depth, width, &next), nodes, nil
+	case ShapeMap:
+		return buildMap(depth, width, &next), nodes, nil
+	}
+	return nil, 0, fmt.Errorf("unknown shape %q (want %s or %s)", shape, ShapeStruct, ShapeMap)
+}
+
+func buildStruct(depth, width int, next *int) *payloadNode {
+	id := *next
+	*next++
+	n := &payloadNode{
+		ID:     id,
+		Name:   "node-" + strconv.Itoa(id),
+		Value:  float64(id) * 1.5,
+		Active: id%2 == 0,
+		Tags:   []string{"alpha", "beta", "gamma"},
+	}
+	if depth > 0 {
+		n.Children = make([]*payloadNode, width)
+		for i := range n.Children {
+			n.Children[i] = buildStruct(depth-1, width, next)
+		}
+	}
+	return n
+}
+
+func buildMap(depth, width int, next *int) map[string]interface{} {
+	id := *next
+	*next++
+	n := map[string]interface{}{
+		"id":     id,
+		"name":   "node-" + strconv.Itoa(id),
+		"value":  float64(id) * 1.5,
+		"active": id%2 == 0,
+		"tags":   []string{"alpha", "beta", "gamma"},
+	}
+	if depth > 0 {
+		children := make([]map[string]interface{}, width)
+		for i := range children {
+			children[i] = buildMap(depth-1, width, next)
+		}
+		n["children"] = children
+	}
+	return n
+}
+
+// MarshalResult is the outcome of MarshalTimed.
+type MarshalResult struct {
+	Bytes     int   `json:"bytes"`
+	MarshalUs int64 `json:"marshal_us"`
+}
+
+// MarshalTimed encodes v with enc into memory and reports the encoded size
+// and how long encoding took, excluding any network write.
+func MarshalTimed(enc Encoder, v interface{}) (MarshalResult, error) {
+	var buf bytes.Buffer
+	start := time.Now()
+	if err := enc.Marshal(&buf, v); err != nil {
+		return MarshalResult{}, err
+	}
+	return MarshalResult{Bytes: buf.Len(), MarshalUs: time.Since(start).Microseconds()}, nil
+}
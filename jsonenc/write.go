@@ -0,0 +1,37 @@
+package jsonenc
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// maxPooledBuffer caps the buffers kept for reuse, so one large response
+// doesn't pin its memory in the pool.
+const maxPooledBuffer = 64 << 10
+
+var buffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// Write marshals v with enc into a pooled buffer, then sends it with an
+// explicit Content-Length in a single write, so the response isn't framed
+// with chunked transfer encoding. Content-Type and any other headers must
+// already be set. A marshalling error is returned before anything has been
+// written, leaving the caller free to answer with another status.
+func Write(w http.ResponseWriter, status int, enc Encoder, v interface{}) error {
+	buf := buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			buffers.Put(buf)
+		}
+	}()
+
+	if err := enc.Marshal(buf, v); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+	return nil
+}
@@ -0,0 +1,166 @@
+// Package jwtauth verifies HS256 JSON Web Tokens, so the CPU a real API
+// spends checking a bearer token on every request can be part of a framework
+// comparison. It signs and verifies with crypto/hmac directly and accepts no
+// other algorithm, which rules out alg=none and key-confusion tokens.
+package jwtauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// TokenPath is the endpoint minting test tokens. It is exempt from
+// verification, as are the health probes.
+const TokenPath = "/api/v1/auth/token"
+
+// Defaults and bounds of the token endpoint's sub and ttl_seconds.
+const (
+	DefaultSubject    = "carbon-bench"
+	DefaultTTLSeconds = 3600
+	MaxTTLSeconds     = 86400
+)
+
+// Challenge is the WWW-Authenticate value sent with a 401.
+const Challenge = `Bearer realm="carbon-bench"`
+
+// Reasons a token is rejected, used as the 401 message.
+var (
+	ErrMissing   = errors.New("missing bearer token")
+	ErrMalformed = errors.New("malformed token")
+	ErrAlgorithm = errors.New("token algorithm is not HS256")
+	ErrSignature = errors.New("invalid token signature")
+	ErrExpired   = errors.New("token expired")
+	ErrNotYet    = errors.New("token not valid yet")
+)
+
+// header is the encoded JOSE header of every minted token.
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Claims are the registered claims a token carries; times are Unix seconds
+// and zero when absent.
+type Claims struct {
+	Subject   string `json:"sub,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+// TokenResponse is the body of GET /api/v1/auth/token, shaped like an
+// OAuth 2 token response.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+// Verifier signs and verifies tokens with one shared secret.
+type Verifier struct {
+	secret []byte
+}
+
+// New returns a Verifier for secret, which must not be empty.
+func New(secret string) *Verifier {
+	return &Verifier{secret: []byte(secret)}
+}
+
+// Mint returns a token for subject that expires after ttl.
+func (v *Verifier) Mint(subject string, ttl time.Duration) (TokenResponse, error) {
+	now := time.Now()
+	payload, err := json.Marshal(Claims{Subject: subject, IssuedAt: now.Unix(), ExpiresAt: now.Add(ttl).Unix()})
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return TokenResponse{
+		AccessToken: signed + "." + base64.RawURLEncoding.EncodeToString(v.sign(signed)),
+		ExpiresIn:   int64(ttl / time.Second),
+		TokenType:   "Bearer",
+	}, nil
+}
+
+// Check verifies the bearer token in an Authorization header value.
+func (v *Verifier) Check(authorization string) error {
+	token, ok := bearer(authorization)
+	if !ok {
+		return ErrMissing
+	}
+	_, err := v.Verify(token)
+	return err
+}
+
+// Verify checks token's header, signature and validity period, in that
+// order, and returns its claims.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	rawHeader, rest, ok := strings.Cut(token, ".")
+	rawPayload, rawSig, ok2 := strings.Cut(rest, ".")
+	if !ok || !ok2 || strings.Contains(rawSig, ".") {
+		return Claims{}, ErrMalformed
+	}
+
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if err := decode(rawHeader, &h); err != nil {
+		return Claims{}, ErrMalformed
+	}
+	if h.Alg != "HS256" {
+		return Claims{}, ErrAlgorithm
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(rawSig)
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	if !hmac.Equal(sig, v.sign(token[:len(rawHeader)+1+len(rawPayload)])) {
+		return Claims{}, ErrSignature
+	}
+
+	var c Claims
+	if err := decode(rawPayload, &c); err != nil {
+		return Claims{}, ErrMalformed
+	}
+	now := time.Now().Unix()
+	switch {
+	case c.ExpiresAt != 0 && now >= c.ExpiresAt:
+		return Claims{}, ErrExpired
+	case c.NotBefore != 0 && now < c.NotBefore:
+		return Claims{}, ErrNotYet
+	}
+	return c, nil
+}
+
+// Exempt reports whether path, without any API_PREFIX, is served without a
+// token: the health probes, so orchestrators keep working, and TokenPath.
+func Exempt(path string) bool {
+	return path == TokenPath || path == "/api/v1/health" || strings.HasPrefix(path, "/api/v1/health/")
+}
+
+func (v *Verifier) sign(signed string) []byte {
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+// bearer extracts the token from a "Bearer <token>" header value; the
+// scheme is case-insensitive.
+func bearer(authorization string) (string, bool) {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func decode(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
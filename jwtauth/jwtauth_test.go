@@ -0,0 +1,116 @@
+package jwtauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+// forge builds a token from a raw JOSE header and payload, signed with
+// secret using HS256 whatever the header claims.
+func forge(secret, header, payload string) string {
+	signed := b64(header) + "." + b64(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func b64(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// flip changes the first character of s to another valid base64url one.
+// The first character carries six whole bits, where the last may carry
+// only padding that decoding ignores.
+func flip(s string) string {
+	if s[0] == 'A' {
+		return "B" + s[1:]
+	}
+	return "A" + s[1:]
+}
+
+func TestVerifyRejects(t *testing.T) {
+	hs256 := `{"alg":"HS256","typ":"JWT"}`
+	good := forge(testSecret, hs256, `{"sub":"alice"}`)
+	parts := strings.Split(good, ".")
+	past := time.Now().Add(-time.Minute).Unix()
+	future := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"alg none", b64(`{"alg":"none","typ":"JWT"}`) + "." + parts[1] + ".", ErrAlgorithm},
+		{"alg HS512", forge(testSecret, `{"alg":"HS512","typ":"JWT"}`, `{"sub":"alice"}`), ErrAlgorithm},
+		{"tampered payload", parts[0] + "." + b64(`{"sub":"mallory"}`) + "." + parts[2], ErrSignature},
+		{"tampered signature", parts[0] + "." + parts[1] + "." + flip(parts[2]), ErrSignature},
+		{"wrong secret", forge("other-secret", hs256, `{"sub":"alice"}`), ErrSignature},
+		{"extra segment", good + "." + parts[2], ErrMalformed},
+		{"two segments", parts[0] + "." + parts[1], ErrMalformed},
+		{"bad base64 header", "!!!." + parts[1] + "." + parts[2], ErrMalformed},
+		{"bad base64 signature", parts[0] + "." + parts[1] + ".***", ErrMalformed},
+		{"expired", forge(testSecret, hs256, `{"sub":"alice","exp":`+strconv.FormatInt(past, 10)+`}`), ErrExpired},
+		{"not yet valid", forge(testSecret, hs256, `{"sub":"alice","nbf":`+strconv.FormatInt(future, 10)+`}`), ErrNotYet},
+	}
+	v := New(testSecret)
+	for _, tt := range tests {
+		if _, err := v.Verify(tt.token); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if c, err := v.Verify(good); err != nil || c.Subject != "alice" {
+		t.Errorf("untouched token: claims %+v, err %v", c, err)
+	}
+}
+
+func TestMintRoundTrips(t *testing.T) {
+	v := New(testSecret)
+	resp, err := v.Mint("bench", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TokenType != "Bearer" || resp.ExpiresIn != 3600 {
+		t.Errorf("token response %+v, want Bearer expiring in 3600s", resp)
+	}
+
+	c, err := v.Verify(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("Verify(minted): %v", err)
+	}
+	if c.Subject != "bench" || c.ExpiresAt-c.IssuedAt != 3600 {
+		t.Errorf("claims %+v, want sub bench valid for 3600s", c)
+	}
+	if err := v.Check("bearer " + resp.AccessToken); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+	if _, err := New("other-secret").Verify(resp.AccessToken); !errors.Is(err, ErrSignature) {
+		t.Errorf("Verify under another secret = %v, want %v", err, ErrSignature)
+	}
+}
+
+func TestExempt(t *testing.T) {
+	for path, want := range map[string]bool{
+		TokenPath:                true,
+		"/api/v1/health":         true,
+		"/api/v1/health/ready":   true,
+		"/api/v1/healthz":        false,
+		"/api/v1/auth/tokens":    false,
+		"/api/v1/auth/token/x":   false,
+		"/v2/api/v1/health":      false,
+		"/api/v1/db/users":       false,
+		"/api/v1/weather/health": false,
+	} {
+		if got := Exempt(path); got != want {
+			t.Errorf("Exempt(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
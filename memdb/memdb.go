@@ -0,0 +1,47 @@
+// Package memdb opens the in-memory SQLite database the framework apps fall
+// back to when PostgreSQL isn't available, so the DB endpoints work in local
+// development and CI without external infrastructure.
+package memdb
+
+import (
+	"database/sql"
+
+	_ "modernc.org/sqlite"
+)
+
+// schema mirrors init.sql in SQLite's dialect, including its sample rows.
+const schema = `
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO users (name, email) VALUES
+    ('Alice Johnson', 'alice@example.com'),
+    ('Bob Smith', 'bob@example.com'),
+    ('Charlie Brown', 'charlie@example.com')
+ON CONFLICT (email) DO NOTHING;
+`
+
+// Open creates a fresh in-memory database holding the users table. An
+// in-memory database lives only as long as its connection, so the pool is
+// pinned to one connection that never expires; SQLite serializes writes
+// anyway, so little concurrency is lost.
+func Open() (*sql.DB, error) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"carbon-bench/apitest"
+	"carbon-bench/config"
+	"carbon-bench/jsonenc"
+	"carbon-bench/padding"
+	"carbon-bench/weather"
+)
+
+func TestMain(m *testing.M) {
+	var err error
+	if encoder, err = jsonenc.New(jsonenc.Stdlib); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// testHandler builds the handler main would with authentication off, with
+// the handlers' dependencies set up and nothing reaching the network. The
+// database is never opened, so its routes answer 503.
+func testHandler(t *testing.T) http.Handler {
+	t.Helper()
+	t.Setenv("BENCHMARK_MODE", "true")
+	filler = padding.New(0)
+	weatherFetcher = weather.NewOpenMeteo(0, time.Second)
+	weatherFetcher.GeocodeURL = "http://127.0.0.1:1/search"
+	weatherFetcher.ForecastURL = "http://127.0.0.1:1/forecast"
+	return newHandler(config.AuthConfig{})
+}
+
+func TestBaselineHeadersOnEveryRoute(t *testing.T) {
+	apitest.CheckBaselineHeaders(t, testHandler(t), "mux")
+}
+
+func TestConditionalCompute(t *testing.T) {
+	apitest.CheckConditional(t, testHandler(t))
+}
+
+func TestUnroutedRequestsAnswerJSON(t *testing.T) {
+	apitest.CheckUnrouted(t, testHandler(t))
+}
+
+func TestPanicRecoveredAsJSON(t *testing.T) {
+	handler := testHandler(t)
+	// Without its fetcher the weather route dereferences nil, standing in
+	// for any handler bug
+	weatherFetcher = nil
+	w := apitest.Serve(handler, http.MethodGet, "/api/v1/weather/fetch?city=Colombo", "")
+	apitest.CheckError(t, w, http.StatusInternalServerError, "internal_server_error")
+}
+
+func TestMarshalFailureAnswersJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	respondJSON(w, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, map[string]float64{"x": math.NaN()})
+	apitest.CheckError(t, w, http.StatusInternalServerError, "internal_server_error")
+}
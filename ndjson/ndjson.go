@@ -0,0 +1,54 @@
+// Package ndjson streams newline-delimited JSON, one object per line, so
+// large result sets can be written as they are read instead of being
+// materialized into a single array first.
+package ndjson
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ContentType is the media type of an NDJSON response.
+const ContentType = "application/x-ndjson"
+
+// Writer encodes values straight onto a ResponseWriter and flushes them to
+// the client every flushEvery values.
+type Writer struct {
+	enc        *json.Encoder
+	flusher    http.Flusher
+	flushEvery int
+	pending    int
+}
+
+// NewWriter sets the NDJSON Content-Type on w and returns a Writer for it.
+// Call it before the status line is written. A flushEvery below 1 flushes
+// after every value.
+func NewWriter(w http.ResponseWriter, flushEvery int) *Writer {
+	w.Header().Set("Content-Type", ContentType)
+	flusher, _ := w.(http.Flusher)
+	if flushEvery < 1 {
+		flushEvery = 1
+	}
+	return &Writer{enc: json.NewEncoder(w), flusher: flusher, flushEvery: flushEvery}
+}
+
+// Write encodes v as one line, flushing when flushEvery values are pending.
+func (s *Writer) Write(v interface{}) error {
+	if err := s.enc.Encode(v); err != nil {
+		return err
+	}
+	s.pending++
+	if s.pending >= s.flushEvery {
+		s.Flush()
+	}
+	return nil
+}
+
+// Flush sends pending lines to the client when the ResponseWriter supports
+// flushing.
+func (s *Writer) Flush() {
+	if s.flusher != nil && s.pending > 0 {
+		s.flusher.Flush()
+	}
+	s.pending = 0
+}
@@ -0,0 +1,27 @@
+package negotiate
+
+import (
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Msgpack encodes MessagePack using the json struct tags, so field names
+// and omitempty match the JSON responses exactly and the two formats carry
+// the same document.
+var Msgpack msgpackEncoder
+
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) Marshal(w io.Writer, v interface{}) error {
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+
+	enc.Reset(w)
+	enc.SetCustomStructTag("json")
+	// Integers as small as they fit, like JSON's digits
+	enc.UseCompactInts(true)
+	return enc.Encode(v)
+}
+
+func (msgpackEncoder) Name() string { return "msgpack" }
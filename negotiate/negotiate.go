@@ -0,0 +1,120 @@
+// Package negotiate picks a response format from the Accept header, so the
+// same payloads can be served as JSON, MessagePack or, for compute results,
+// Protocol Buffers and the serialization cost of each compared.
+package negotiate
+
+import (
+	"strconv"
+	"strings"
+
+	"carbon-bench/api"
+	"carbon-bench/jsonenc"
+)
+
+// Media types the apps can respond with.
+const (
+	JSON        = "application/json"
+	MessagePack = "application/x-msgpack"
+	Protobuf    = "application/x-protobuf"
+)
+
+// Formats are the media types respondJSON offers, in order of preference
+// when the client has none.
+var Formats = []string{JSON, MessagePack}
+
+// ComputeFormats are the media types offered for heavy and medium
+// analytics results, which also have a protobuf message.
+var ComputeFormats = []string{JSON, MessagePack, Protobuf}
+
+// Encoder returns the encoder for a media type chosen by Select: Msgpack
+// for MessagePack, Proto for Protobuf, json otherwise.
+func Encoder(mediaType string, json jsonenc.Encoder) jsonenc.Encoder {
+	switch mediaType {
+	case MessagePack:
+		return Msgpack
+	case Protobuf:
+		return Proto
+	}
+	return json
+}
+
+// Select returns the offer the Accept header rates highest, the earliest
+// offer winning ties, and false when it accepts none of them. Each offer is
+// rated by the most specific media range matching it, per RFC 9110. An
+// empty header accepts anything.
+func Select(accept string, offers ...string) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+	// Fast path for what load generators and most clients send
+	if accept == "" || accept == "*/*" {
+		return offers[0], true
+	}
+
+	ranges := parse(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := rate(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parse(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(fields[0])), "/")
+		if !ok {
+			continue
+		}
+		r := mediaRange{typ: strings.TrimSpace(typ), subtype: strings.TrimSpace(subtype), q: 1}
+		for _, param := range fields[1:] {
+			name, value, _ := strings.Cut(param, "=")
+			if strings.EqualFold(strings.TrimSpace(name), "q") {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q >= 0 && q <= 1 {
+					r.q = q
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// rate returns the q of the most specific range matching offer, or 0.
+func rate(ranges []mediaRange, offer string) float64 {
+	typ, subtype, _ := strings.Cut(offer, "/")
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+// NotAcceptable is the JSON body of the 406 answered when the Accept header
+// rules out every format, listing the supported ones.
+func NotAcceptable() api.ErrorResponse {
+	return api.ErrorResponse{
+		Code:    api.CodeNotAcceptable,
+		Message: "not acceptable",
+		Details: map[string]interface{}{"supported": Formats},
+	}
+}
@@ -0,0 +1,30 @@
+package negotiate
+
+import (
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Proto encodes Protocol Buffers. Only generated messages can be encoded,
+// so it is offered for the endpoints that have one and never by
+// respondJSON.
+var Proto protoEncoder
+
+type protoEncoder struct{}
+
+func (protoEncoder) Marshal(w io.Writer, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("negotiate: %T is not a protobuf message", v)
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (protoEncoder) Name() string { return "protobuf" }
@@ -0,0 +1,51 @@
+// Package padding supplies the filler behind the analytics endpoints'
+// pad_bytes parameter, so response size can be swept independently of the
+// compute size that produces it.
+package padding
+
+import "encoding/base64"
+
+// DefaultMax is the largest pad_bytes accepted unless MAX_PAD_BYTES is set.
+const DefaultMax = 1 << 20
+
+// Limit bounds MAX_PAD_BYTES, since the filler is held in memory.
+const Limit = 64 << 20
+
+// Filler hands out prefixes of one base64 string generated at startup, so a
+// padded response pays for serializing and sending the padding but not for
+// producing it.
+type Filler struct {
+	text string
+}
+
+// New returns a Filler for up to max bytes, clamped to [0, Limit].
+func New(max int) *Filler {
+	if max < 0 {
+		max = 0
+	}
+	if max > Limit {
+		max = Limit
+	}
+
+	// Any byte pattern will do; varying it keeps the text from compressing
+	// to nothing should a proxy gzip the response
+	raw := make([]byte, base64.StdEncoding.DecodedLen(max)+3)
+	x := uint32(2463534242)
+	for i := range raw {
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		raw[i] = byte(x)
+	}
+	return &Filler{text: base64.StdEncoding.EncodeToString(raw)[:max]}
+}
+
+// Max is the largest n Pad accepts.
+func (f *Filler) Max() int {
+	return len(f.text)
+}
+
+// Pad returns n bytes of filler. n must be within [0, Max].
+func (f *Filler) Pad(n int) string {
+	return f.text[:n]
+}
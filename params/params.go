@@ -0,0 +1,62 @@
+// Package params holds the query-parameter parsing rules shared by all
+// framework apps, so every implementation accepts and rejects the same input.
+package params
+
+import (
+	"fmt"
+	"mime"
+	"strconv"
+)
+
+// RangeError reports an integer parameter outside its allowed bounds.
+type RangeError struct {
+	Param string
+	Value int
+	Min   int
+	Max   int
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("%s out of range", e.Param)
+}
+
+// ClampedInt parses raw as an integer bounded to [minValue, maxValue]. A
+// missing value yields defaultValue, which is trusted and not range-checked;
+// a non-numeric value is an error, and a numeric value outside the bounds
+// yields a *RangeError instead of being passed on to the workload.
+func ClampedInt(param, raw string, defaultValue, minValue, maxValue int) (int, error) {
+	if raw == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", param)
+	}
+	if value < minValue || value > maxValue {
+		return 0, &RangeError{Param: param, Value: value, Min: minValue, Max: maxValue}
+	}
+	return value, nil
+}
+
+// Bool parses raw as a boolean flag. Anything strconv.ParseBool rejects,
+// including a missing value, is false.
+func Bool(raw string) bool {
+	b, _ := strconv.ParseBool(raw)
+	return b
+}
+
+// IsJSON reports whether a Content-Type header value names a JSON body,
+// ignoring parameters such as charset.
+func IsJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+// ID parses a path parameter holding a positive integer primary key.
+func ID(param, raw string) (int64, error) {
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid %s", param)
+	}
+	return id, nil
+}
@@ -0,0 +1,62 @@
+package params
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClampedInt(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		want      int
+		wantRange bool
+		wantErr   bool
+	}{
+		{name: "missing", raw: "", want: 5000},
+		{name: "in range", raw: "200", want: 200},
+		{name: "min", raw: "1", want: 1},
+		{name: "max", raw: "10000", want: 10000},
+		{name: "negative", raw: "-5", wantRange: true, wantErr: true},
+		{name: "zero", raw: "0", wantRange: true, wantErr: true},
+		{name: "over max", raw: "10001", wantRange: true, wantErr: true},
+		{name: "non-numeric", raw: "abc", wantErr: true},
+		{name: "float", raw: "1.5", wantErr: true},
+		{name: "overflow", raw: "99999999999999999999", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ClampedInt("size", tt.raw, 5000, 1, 10000)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ClampedInt(%q) = %d, want an error", tt.raw, got)
+				}
+				var rangeErr *RangeError
+				if errors.As(err, &rangeErr) != tt.wantRange {
+					t.Errorf("ClampedInt(%q) error %v: RangeError = %t, want %t", tt.raw, err, !tt.wantRange, tt.wantRange)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ClampedInt(%q): %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("ClampedInt(%q) = %d, want %d", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClampedIntDefaultNotRangeChecked(t *testing.T) {
+	got, err := ClampedInt("size", "", 5000, 1, 2000)
+	if err != nil || got != 5000 {
+		t.Errorf("ClampedInt with default above max = %d, %v; want 5000, nil", got, err)
+	}
+}
+
+func TestRangeErrorMessage(t *testing.T) {
+	_, err := ClampedInt("size", "-5", 5000, 1, 10000)
+	if err == nil || err.Error() != "size out of range" {
+		t.Errorf("error = %v, want size out of range", err)
+	}
+}
@@ -0,0 +1,118 @@
+package params
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// Kind is how a query parameter's value must parse in strict mode.
+type Kind int
+
+const (
+	KindString Kind = iota
+	KindInt
+	KindBool
+)
+
+// Spec lists the query parameters an endpoint reads.
+type Spec map[string]Kind
+
+// Problem is one offending query parameter in a strict-mode 400.
+type Problem struct {
+	Param string `json:"param"`
+	Value string `json:"value,omitempty"`
+	Error string `json:"error"`
+}
+
+// computeSpec is what computeParams reads.
+var computeSpec = Spec{"kernel": KindString, "size": KindInt, "iterations": KindInt, "goroutines": KindInt, "seed": KindInt}
+
+// Specs maps each static path the Go apps serve to the query parameters its
+// handlers read, whatever the method. Paths with path parameters, and those
+// not listed such as /metrics and /debug/pprof/, aren't checked.
+var Specs = map[string]Spec{
+	"/":                       {},
+	"/api/v1/health":          {},
+	"/api/v1/health/detailed": {},
+	"/api/v1/version":         {},
+	"/api/v1/routes":          {},
+	"/api/v1/ready":           {},
+	"/api/v1/auth/token":      {"sub": KindString, "ttl_seconds": KindInt},
+	"/api/v1/metrics":         {},
+	"/api/v1/clients":         {"limit": KindInt},
+	"/api/v1/compute/stats":   {},
+
+	"/api/v1/weather/analytics/light":  {"pad_bytes": KindInt},
+	"/api/v1/weather/analytics/medium": merge(computeSpec, Spec{"warmup": KindInt, "gc": KindBool, "pad_bytes": KindInt}),
+	"/api/v1/weather/analytics/heavy":  merge(computeSpec, Spec{"warmup": KindInt, "gc": KindBool, "pad_bytes": KindInt}),
+	"/api/v1/weather/analytics/memory": {"objects": KindInt, "size": KindInt, "gc": KindBool},
+	"/api/v1/weather/analytics/fanout": {"tasks": KindInt},
+	"/api/v1/analytics/compare":        {"size": KindInt, "iterations": KindInt},
+	"/api/v1/weather/analytics/batch":  {},
+	"/api/v1/weather/analytics/stream": computeSpec,
+	"/api/v1/compute/async":            computeSpec,
+
+	"/api/v1/weather/external": {"delay_ms": KindInt},
+	"/api/v1/weather/fetch":    {"city": KindString, "cities": KindString, "parallelism": KindInt},
+	"/api/v1/io/file":          {"bytes": KindInt},
+	"/api/v1/bench/json":       {"depth": KindInt, "width": KindInt, "shape": KindString},
+
+	"/api/v1/db/users":            {"limit": KindInt, "offset": KindInt, "format": KindString, "repeat": KindInt},
+	"/api/v1/db/users/bulk":       {},
+	"/api/v1/db/stress":           {"workers": KindInt, "ops": KindInt},
+	"/api/v1/db/acquire":          {"n": KindInt},
+	"/api/v1/db/stats":            {},
+	"/api/v1/compute/stream-json": {"limit": KindInt, "offset": KindInt, "flush_every": KindInt},
+}
+
+func merge(specs ...Spec) Spec {
+	merged := Spec{}
+	for _, s := range specs {
+		for name, kind := range s {
+			merged[name] = kind
+		}
+	}
+	return merged
+}
+
+// CheckQuery returns the problems strict mode rejects in query for path:
+// parameters the endpoint doesn't read, and values that don't parse as the
+// parameter's kind, sorted by name. Range checks stay with the handlers. It
+// returns nil for a path without a Spec.
+func CheckQuery(path string, query url.Values) []Problem {
+	spec, ok := Specs[path]
+	if !ok {
+		return nil
+	}
+
+	var problems []Problem
+	for name, values := range query {
+		kind, known := spec[name]
+		if !known {
+			problems = append(problems, Problem{Param: name, Error: "unknown parameter"})
+			continue
+		}
+		for _, v := range values {
+			if msg := checkValue(kind, v); msg != "" {
+				problems = append(problems, Problem{Param: name, Value: v, Error: msg})
+			}
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Param < problems[j].Param })
+	return problems
+}
+
+func checkValue(kind Kind, v string) string {
+	switch kind {
+	case KindInt:
+		if _, err := strconv.Atoi(v); err != nil {
+			return "not an integer"
+		}
+	case KindBool:
+		if _, err := strconv.ParseBool(v); err != nil {
+			return "not a boolean"
+		}
+	}
+	return ""
+}
@@ -15,6 +15,10 @@ FRAMEWORKS = {
     "micronaut": 8003,
     "gin": 8004,
     "chi": 8005,
+    "iris": 8006,
+    "goframe": 8007,
+    "mux": 8008,
+    "fasthttp": 8009,
 }
 
 def print_usage():
@@ -25,6 +25,10 @@ FRAMEWORKS = {
     "micronaut": {"port": 8003, "name": "Micronaut", "folder": "micronaut-carbon-test"},
     "gin": {"port": 8004, "name": "Gin", "folder": "gin-carbon-test"},
     "chi": {"port": 8005, "name": "Chi", "folder": "chi-carbon-test"},
+    "iris": {"port": 8006, "name": "Iris", "folder": "iris-carbon-test"},
+    "goframe": {"port": 8007, "name": "GoFrame", "folder": "goframe-carbon-test"},
+    "mux": {"port": 8008, "name": "Mux", "folder": "mux-carbon-test"},
+    "fasthttp": {"port": 8009, "name": "fasthttp", "folder": "fasthttp-carbon-test"},
 }
 
 # Test configurations
@@ -1,7 +1,7 @@
 #!/usr/bin/env python3
 """
 Universal Carbon Footprint Test Script - Windows Compatible
-Works with ANY framework: Django, FastAPI, Spring Boot, Micronaut, Gin, Chi
+Works with ANY framework: Django, FastAPI, Spring Boot, Micronaut, Gin, Chi, Iris, GoFrame, Mux, fasthttp
 """
 
 import subprocess
@@ -379,7 +379,7 @@ Examples:
     )
     
     parser.add_argument("--framework", required=True,
-                       choices=["fastapi", "django", "springboot", "micronaut", "gin", "chi"],
+                       choices=["fastapi", "django", "springboot", "micronaut", "gin", "chi", "iris", "goframe", "mux", "fasthttp"],
                        help="Framework being tested")
     parser.add_argument("--test", default="light_load",
                        help="Test name (e.g., light_load, moderate_load, heavy_load)")
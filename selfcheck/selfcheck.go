@@ -0,0 +1,182 @@
+// Package selfcheck requests every parameterless GET endpoint of a freshly
+// started framework app and reports which don't answer 200, so a route
+// broken by a refactor is caught before a benchmark run instead of in its
+// results.
+package selfcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"carbon-bench/api"
+)
+
+const (
+	// startupTimeout is how long Run waits for the server to accept
+	// connections.
+	startupTimeout = 10 * time.Second
+	// requestTimeout bounds each checked request.
+	requestTimeout = 30 * time.Second
+)
+
+// skipped are path prefixes a plain GET can't exercise: the WebSocket
+// endpoint needs an upgrade, and the pprof profiles block for seconds.
+var skipped = []string{"/api/v1/ws", "/debug/"}
+
+// Target is where the app is listening, as passed to net.Dial, and the
+// API_PREFIX its routes are served under. Token, when set, is sent as a
+// bearer token with every request, for apps running with REQUIRE_AUTH=true.
+type Target struct {
+	Network string
+	Address string
+	TLS     bool
+	Prefix  string
+	Token   string
+}
+
+// Run waits for the app at t to come up, fetches its /api/v1/routes and
+// requests each GET route without path parameters, logging one line per
+// endpoint. It returns an error naming how many failed.
+func Run(t Target) error {
+	client, base := t.client()
+
+	if err := waitForServer(client, base+t.Prefix); err != nil {
+		return err
+	}
+
+	routes, err := fetchRoutes(client, base+t.Prefix)
+	if err != nil {
+		return err
+	}
+
+	var checked, failed int
+	for _, r := range routes {
+		if !checkable(r, t.Prefix) {
+			continue
+		}
+		checked++
+		start := time.Now()
+		status, err := get(client, base+r.Path)
+		elapsed := time.Since(start).Round(time.Millisecond)
+		switch {
+		case err != nil:
+			failed++
+			log.Printf("✗ Self-check GET %s: %v", r.Path, err)
+		case status != http.StatusOK:
+			failed++
+			log.Printf("✗ Self-check GET %s: %d (%s)", r.Path, status, elapsed)
+		default:
+			log.Printf("✓ Self-check GET %s: %d (%s)", r.Path, status, elapsed)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d endpoints failed", failed, checked)
+	}
+	log.Printf("✓ Self-check passed: %d endpoints", checked)
+	return nil
+}
+
+// client returns an HTTP client that always dials t, and the base URL to
+// request. Certificates aren't verified: the app is checking itself.
+func (t Target) client() (*http.Client, string) {
+	dialer := &net.Dialer{}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, t.Network, t.Address)
+		},
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}
+	base := "http://localhost"
+	if t.TLS {
+		base = "https://localhost"
+	}
+	if t.Token != "" {
+		return &http.Client{Transport: bearer{transport, t.Token}, Timeout: requestTimeout}, base
+	}
+	return &http.Client{Transport: transport, Timeout: requestTimeout}, base
+}
+
+// bearer adds an Authorization header to every request it sends.
+type bearer struct {
+	next  http.RoundTripper
+	token string
+}
+
+func (b bearer) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	return b.next.RoundTrip(req)
+}
+
+func waitForServer(client *http.Client, base string) error {
+	deadline := time.Now().Add(startupTimeout)
+	for {
+		_, err := get(client, base+"/api/v1/health")
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("server not reachable after %s: %w", startupTimeout, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func fetchRoutes(client *http.Client, base string) ([]api.Route, error) {
+	resp, err := client.Get(base + "/api/v1/routes")
+	if err != nil {
+		return nil, fmt.Errorf("listing routes: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing routes: status %d", resp.StatusCode)
+	}
+	var routes api.RoutesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&routes); err != nil {
+		return nil, fmt.Errorf("listing routes: %w", err)
+	}
+	return routes.Routes, nil
+}
+
+// checkable reports whether r, served under prefix, can be requested as a
+// bare GET.
+func checkable(r api.Route, prefix string) bool {
+	if r.Method != http.MethodGet && r.Method != api.AnyMethod {
+		return false
+	}
+	if strings.Contains(r.Path, "{") {
+		return false
+	}
+	for _, skip := range skipped {
+		if strings.HasPrefix(strings.TrimPrefix(r.Path, prefix), skip) {
+			return false
+		}
+	}
+	return true
+}
+
+// get requests url and drains the body, so streaming endpoints are checked
+// to completion.
+func get(client *http.Client, url string) (status int, err error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,33 @@
+// Package sse writes Server-Sent Events in the format shared by every
+// framework app, so streaming benchmark clients work against any backend.
+package sse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SetHeaders marks the response as an event stream. Call it before the
+// status line is written.
+func SetHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+}
+
+// WriteEvent writes one named event whose data line is v encoded as JSON,
+// then flushes it to the client when w supports flushing.
+func WriteEvent(w http.ResponseWriter, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
@@ -10,7 +10,11 @@ $frameworks = @(
     @{Name="Spring Boot"; Path="springboot-carbon-test"; Port=8002},
     @{Name="Micronaut"; Path="micronaut-carbon-test"; Port=8003},
     @{Name="Gin"; Path="gin-carbon-test"; Port=8004},
-    @{Name="Chi"; Path="chi-carbon-test"; Port=8005}
+    @{Name="Chi"; Path="chi-carbon-test"; Port=8005},
+    @{Name="Iris"; Path="iris-carbon-test"; Port=8006},
+    @{Name="GoFrame"; Path="goframe-carbon-test"; Port=8007},
+    @{Name="Mux"; Path="mux-carbon-test"; Port=8008},
+    @{Name="fasthttp"; Path="fasthttp-carbon-test"; Port=8009}
 )
 
 foreach ($framework in $frameworks) {
@@ -0,0 +1,87 @@
+// Package statslog logs a snapshot of the process's resources at a fixed
+// interval, so goroutine, heap or connection pool drift over a multi-hour
+// run shows up in the log without anything scraping /metrics.
+package statslog
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Snapshot is the process state at one tick. DB is nil when the app has no
+// database.
+type Snapshot struct {
+	Goroutines  int
+	HeapAlloc   uint64
+	HeapObjects uint64
+	NumGC       uint32
+	DB          *sql.DBStats
+}
+
+// Take reads the current snapshot. db may be nil.
+func Take(db *sql.DB) Snapshot {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	s := Snapshot{
+		Goroutines:  runtime.NumGoroutine(),
+		HeapAlloc:   mem.HeapAlloc,
+		HeapObjects: mem.HeapObjects,
+		NumGC:       mem.NumGC,
+	}
+	if db != nil {
+		stats := db.Stats()
+		s.DB = &stats
+	}
+	return s
+}
+
+// String formats s as logfmt key=value pairs, one line per snapshot, so the
+// log can be filtered and plotted with standard tools.
+func (s Snapshot) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "goroutines=%d heap_alloc_bytes=%d heap_objects=%d num_gc=%d",
+		s.Goroutines, s.HeapAlloc, s.HeapObjects, s.NumGC)
+	if s.DB != nil {
+		fmt.Fprintf(&b, " db_open=%d db_in_use=%d db_idle=%d db_wait_count=%d db_wait_ms=%d",
+			s.DB.OpenConnections, s.DB.InUse, s.DB.Idle, s.DB.WaitCount, s.DB.WaitDuration.Milliseconds())
+	}
+	return b.String()
+}
+
+// Start logs a snapshot every interval until the returned stop function is
+// called; stop waits for the logging goroutine to exit and is safe to call
+// more than once. A zero or negative interval logs nothing.
+func Start(interval time.Duration, db *sql.DB) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				log.Printf("📊 Stats: %s", Take(db))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		wg.Wait()
+	}
+}
@@ -9,7 +9,11 @@ $frameworks = @(
     "springboot-carbon-test",
     "micronaut-carbon-test",
     "gin-carbon-test",
-    "chi-carbon-test"
+    "chi-carbon-test",
+    "iris-carbon-test",
+    "goframe-carbon-test",
+    "mux-carbon-test",
+    "fasthttp-carbon-test"
 )
 
 foreach ($framework in $frameworks) {
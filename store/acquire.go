@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// MaxAcquires bounds the n of one Acquire run.
+const MaxAcquires = 10000
+
+// AcquireResult summarizes an Acquire run. Latencies are in microseconds.
+// WaitCount and WaitDurationMs are the pool's waits during the run, taken
+// from sql.DBStats, so they include other requests waiting meanwhile.
+type AcquireResult struct {
+	Acquisitions   int     `json:"acquisitions"`
+	ElapsedMs      float64 `json:"elapsed_ms"`
+	MinUs          float64 `json:"min_us"`
+	MeanUs         float64 `json:"mean_us"`
+	P50Us          float64 `json:"p50_us"`
+	P90Us          float64 `json:"p90_us"`
+	P99Us          float64 `json:"p99_us"`
+	MaxUs          float64 `json:"max_us"`
+	WaitCount      int64   `json:"wait_count"`
+	WaitDurationMs float64 `json:"wait_duration_ms"`
+}
+
+// Acquire checks n connections out of db's pool one after another, timing
+// each db.Conn call and returning the connection straight away, so the cost
+// of the pool's locking and waiting shows apart from any query. Acquiring
+// may dial when no connection is idle, and waits whenever other requests
+// hold all MaxOpenConns. It stops at the first error, which it returns.
+func Acquire(ctx context.Context, db *sql.DB, n int) (AcquireResult, error) {
+	before := db.Stats()
+	latencies := make([]time.Duration, n)
+
+	start := time.Now()
+	for i := range latencies {
+		t := time.Now()
+		conn, err := db.Conn(ctx)
+		latencies[i] = time.Since(t)
+		if err != nil {
+			return AcquireResult{}, err
+		}
+		conn.Close()
+	}
+	elapsed := time.Since(start)
+	after := db.Stats()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, d := range latencies {
+		total += d
+	}
+
+	return AcquireResult{
+		Acquisitions:   n,
+		ElapsedMs:      float64(elapsed.Microseconds()) / 1000,
+		MinUs:          micros(latencies[0]),
+		MeanUs:         micros(total / time.Duration(n)),
+		P50Us:          micros(percentile(latencies, 50)),
+		P90Us:          micros(percentile(latencies, 90)),
+		P99Us:          micros(percentile(latencies, 99)),
+		MaxUs:          micros(latencies[n-1]),
+		WaitCount:      after.WaitCount - before.WaitCount,
+		WaitDurationMs: float64((after.WaitDuration - before.WaitDuration).Microseconds()) / 1000,
+	}, nil
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func micros(d time.Duration) float64 {
+	return float64(d.Nanoseconds()) / 1000
+}
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Dialect is the SQL for the users table in one database's syntax, so the
+// handlers issue the same logical statements whichever backend is under
+// test. The PostgreSQL dialect also runs unchanged on the SQLite fallback.
+type Dialect struct {
+	// Name is the database/sql driver name
+	Name string
+
+	SelectUsers string
+	InsertUser  string
+	SelectUser  string
+	UpdateUser  string
+	DeleteUser  string
+
+	// TruncateUsers empties the table and restarts its ids at 1. SQLite
+	// has no TRUNCATE, so it isn't run against the fallback.
+	TruncateUsers string
+
+	// Returning reports whether InsertUser and BulkInsertUsers yield the
+	// created rows. Without it the caller reads them back: by LastInsertId
+	// after InsertUser, with SelectInsertedUsers after a bulk insert.
+	Returning bool
+
+	placeholder func(n int) string
+	createTable string
+	tableExists string
+}
+
+// Postgres is the dialect of lib/pq, and of the in-memory SQLite database.
+var Postgres = Dialect{
+	Name:        "postgres",
+	SelectUsers: SelectUsersQuery,
+	InsertUser:  InsertUserQuery,
+	SelectUser:  SelectUserQuery,
+	UpdateUser:  UpdateUserQuery,
+	DeleteUser:  DeleteUserQuery,
+	Returning:   true,
+
+	TruncateUsers: "TRUNCATE users RESTART IDENTITY",
+
+	placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+	createTable: createUsersTableQuery,
+	// to_regclass resolves the name through search_path like the queries do
+	tableExists: "SELECT to_regclass('users') IS NOT NULL",
+}
+
+// MySQL is the dialect of go-sql-driver/mysql. MySQL has no RETURNING, so
+// inserted rows are read back in a second statement.
+var MySQL = Dialect{
+	Name:        "mysql",
+	SelectUsers: "SELECT id, name, email, created_at FROM users ORDER BY id LIMIT ? OFFSET ?",
+	InsertUser:  "INSERT INTO users (name, email) VALUES (?, ?)",
+	SelectUser:  "SELECT id, name, email, created_at FROM users WHERE id = ?",
+	UpdateUser:  "UPDATE users SET name = ?, email = ? WHERE id = ?",
+	DeleteUser:  "DELETE FROM users WHERE id = ?",
+
+	// Commits implicitly, so it can't be rolled back with the transaction
+	// it runs in
+	TruncateUsers: "TRUNCATE TABLE users",
+
+	placeholder: func(int) string { return "?" },
+	createTable: `CREATE TABLE IF NOT EXISTS users (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`,
+	tableExists: "SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'users'",
+}
+
+// DialectFor returns the dialect of a database/sql driver name, defaulting
+// to Postgres.
+func DialectFor(driver string) Dialect {
+	if driver == MySQL.Name {
+		return MySQL
+	}
+	return Postgres
+}
+
+// MigrateUsers creates the users table when it doesn't exist yet and
+// reports whether it had to.
+func (d Dialect) MigrateUsers(ctx context.Context, db *sql.DB) (created bool, err error) {
+	var exists bool
+	if err := db.QueryRowContext(ctx, d.tableExists).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	if _, err := db.ExecContext(ctx, d.createTable); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// BulkInsertUsers builds one parameterized multi-row INSERT for users and
+// returns it with its flattened arguments. With Returning, rows come back
+// in insertion order.
+func (d Dialect) BulkInsertUsers(users []NewUser) (string, []interface{}) {
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO users (name, email) VALUES ")
+
+	args := make([]interface{}, 0, len(users)*2)
+	for i, u := range users {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "(%s, %s)", d.placeholder(i*2+1), d.placeholder(i*2+2))
+		args = append(args, u.Name, u.Email)
+	}
+	if d.Returning {
+		sb.WriteString(" RETURNING id, name, email, created_at")
+	}
+
+	return sb.String(), args
+}
+
+// SelectInsertedUsers builds the query that reads back the rows of a bulk
+// insert by their unique emails. Run in the inserting transaction, it
+// returns them in insertion order, since ids increase within a statement.
+func (d Dialect) SelectInsertedUsers(users []NewUser) (string, []interface{}) {
+	var sb strings.Builder
+	sb.WriteString("SELECT id, name, email, created_at FROM users WHERE email IN (")
+
+	args := make([]interface{}, 0, len(users))
+	for i, u := range users {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(d.placeholder(i + 1))
+		args = append(args, u.Email)
+	}
+	sb.WriteString(") ORDER BY id")
+
+	return sb.String(), args
+}
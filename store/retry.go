@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// MaxRetries is how many times Retry repeats an operation after a transient
+// failure before giving up.
+const MaxRetries = 2
+
+// retryBackoff is the wait before the first retry; it doubles for each
+// subsequent one.
+const retryBackoff = 25 * time.Millisecond
+
+// Transient reports whether err is a connection-level failure that a fresh
+// attempt on another pooled connection may not hit: a broken or reset
+// connection, a MySQL connection the driver found unusable, or a PostgreSQL
+// connection exception (SQLSTATE class 08) or shutdown (57P01-57P03).
+// Query errors such as constraint violations and a cancelled request
+// context are never transient. Several of these can strike after the
+// server ran the statement, so only reads may be retried on them; see
+// TransientWrite.
+func Transient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03":
+			return true
+		}
+		return pqErr.Code.Class() == "08"
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// TransientWrite reports whether err shows that a write never reached the
+// server, so running it again cannot apply it twice: the driver answered
+// driver.ErrBadConn, which it may only do before sending the statement, or
+// the connection was refused. A reset connection or an EOF can come after
+// the server committed, and a retry would then insert a duplicate or
+// repeat an update.
+func TransientWrite(err error) bool {
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// Retry runs the read op and, while it fails with a Transient error, runs it
+// again up to MaxRetries times with exponential backoff. It returns the
+// number of retries made alongside op's last error.
+func Retry(ctx context.Context, op func() error) (retries int, err error) {
+	return retry(ctx, op, Transient)
+}
+
+// RetryWrite is Retry for an INSERT, UPDATE or DELETE, repeating it only on
+// a TransientWrite error.
+func RetryWrite(ctx context.Context, op func() error) (retries int, err error) {
+	return retry(ctx, op, TransientWrite)
+}
+
+func retry(ctx context.Context, op func() error, transient func(error) bool) (retries int, err error) {
+	for {
+		err = op()
+		if err == nil || retries == MaxRetries || !transient(err) {
+			return retries, err
+		}
+		timer := time.NewTimer(retryBackoff << retries)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return retries, err
+		case <-timer.C:
+		}
+		retries++
+	}
+}
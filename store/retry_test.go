@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// opError wraps errno the way a failed dial or read surfaces from a driver.
+func opError(op string, errno syscall.Errno) error {
+	return &net.OpError{Op: op, Net: "tcp", Err: os.NewSyscallError(op, errno)}
+}
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		read, write bool
+	}{
+		{"nil", nil, false, false},
+		{"bad conn", driver.ErrBadConn, true, true},
+		{"wrapped bad conn", fmt.Errorf("query: %w", driver.ErrBadConn), true, true},
+		{"connection refused", opError("dial", syscall.ECONNREFUSED), true, true},
+		{"connection reset", opError("read", syscall.ECONNRESET), true, false},
+		{"broken pipe", opError("write", syscall.EPIPE), true, false},
+		{"EOF", io.EOF, true, false},
+		{"unexpected EOF", io.ErrUnexpectedEOF, true, false},
+		{"postgres admin shutdown", &pq.Error{Code: "57P01"}, true, false},
+		{"postgres connection failure", &pq.Error{Code: "08006"}, true, false},
+		{"unique violation", &pq.Error{Code: "23505"}, false, false},
+		{"cancelled", context.Canceled, false, false},
+		{"deadline", context.DeadlineExceeded, false, false},
+		{"other", errors.New("syntax error"), false, false},
+	}
+	for _, tt := range tests {
+		if got := Transient(tt.err); got != tt.read {
+			t.Errorf("Transient(%s) = %v, want %v", tt.name, got, tt.read)
+		}
+		if got := TransientWrite(tt.err); got != tt.write {
+			t.Errorf("TransientWrite(%s) = %v, want %v", tt.name, got, tt.write)
+		}
+	}
+}
+
+func TestRetryWriteStopsOnConnectionReset(t *testing.T) {
+	reset := opError("read", syscall.ECONNRESET)
+	attempts := 0
+	retries, err := RetryWrite(context.Background(), func() error {
+		attempts++
+		return reset
+	})
+	if attempts != 1 || retries != 0 || !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("RetryWrite: %d attempts, %d retries, err %v; want 1, 0, ECONNRESET", attempts, retries, err)
+	}
+
+	attempts = 0
+	retries, err = Retry(context.Background(), func() error {
+		attempts++
+		return reset
+	})
+	if attempts != MaxRetries+1 || retries != MaxRetries || err == nil {
+		t.Errorf("Retry: %d attempts, %d retries, err %v; want %d, %d, ECONNRESET", attempts, retries, err, MaxRetries+1, MaxRetries)
+	}
+}
+
+func TestRetryWriteRetriesBadConn(t *testing.T) {
+	attempts := 0
+	retries, err := RetryWrite(context.Background(), func() error {
+		attempts++
+		if attempts == 1 {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+	if err != nil || retries != 1 {
+		t.Errorf("RetryWrite: %d retries, err %v; want 1, nil", retries, err)
+	}
+}
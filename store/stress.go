@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Bounds for the DB stress workload's query parameters.
+const (
+	MaxStressWorkers = 64
+	MaxStressOps     = 1000
+)
+
+// stressRuns numbers Stress calls so concurrent runs insert distinct emails
+// and never wait on each other's uncommitted rows in the unique index.
+var stressRuns atomic.Uint64
+
+// StressResult aggregates a Stress run. An operation is one INSERT of a
+// user followed by a SELECT of it by primary key.
+type StressResult struct {
+	Workers      int     `json:"workers"`
+	OpsPerWorker int     `json:"ops_per_worker"`
+	Operations   int64   `json:"operations"`
+	Errors       int64   `json:"errors"`
+	ElapsedMs    int64   `json:"elapsed_ms"`
+	OpsPerSecond float64 `json:"ops_per_second"`
+	AvgOpUs      int64   `json:"avg_op_us"`
+	MaxOpUs      int64   `json:"max_op_us"`
+}
+
+// Stress runs workers goroutines against db, each performing ops operations
+// inside its own transaction, and reports their aggregate timing. Every
+// transaction is rolled back, so the users table is left as it was. A
+// worker whose operation fails counts the error, rolls back and carries on
+// in a fresh transaction, since PostgreSQL aborts a transaction on any
+// error. It returns ctx.Err() if the context ends before the run completes.
+func Stress(ctx context.Context, db *sql.DB, d Dialect, workers, ops int) (StressResult, error) {
+	run := stressRuns.Add(1)
+	var stats stressStats
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			stressWorker(ctx, db, d, run, worker, ops, &stats)
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	if err := ctx.Err(); err != nil {
+		return StressResult{}, err
+	}
+
+	result := StressResult{
+		Workers:      workers,
+		OpsPerWorker: ops,
+		Operations:   stats.ops,
+		Errors:       stats.errors,
+		ElapsedMs:    elapsed.Milliseconds(),
+		MaxOpUs:      stats.max.Microseconds(),
+	}
+	if stats.ops > 0 {
+		result.AvgOpUs = (stats.total / time.Duration(stats.ops)).Microseconds()
+		result.OpsPerSecond = float64(stats.ops) / elapsed.Seconds()
+	}
+	return result, nil
+}
+
+type stressStats struct {
+	mu     sync.Mutex
+	ops    int64
+	errors int64
+	total  time.Duration
+	max    time.Duration
+}
+
+func (s *stressStats) record(d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.errors++
+		return
+	}
+	s.ops++
+	s.total += d
+	if d > s.max {
+		s.max = d
+	}
+}
+
+func stressWorker(ctx context.Context, db *sql.DB, d Dialect, run uint64, worker, ops int, stats *stressStats) {
+	var tx *sql.Tx
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for i := 0; i < ops && ctx.Err() == nil; i++ {
+		if tx == nil {
+			var err error
+			if tx, err = db.BeginTx(ctx, nil); err != nil {
+				stats.record(0, err)
+				tx = nil
+				continue
+			}
+		}
+
+		start := time.Now()
+		err := stressOp(ctx, tx, d, fmt.Sprintf("stress-r%d-w%d-op%d@stress.invalid", run, worker, i))
+		stats.record(time.Since(start), err)
+		if err != nil {
+			tx.Rollback()
+			tx = nil
+		}
+	}
+}
+
+func stressOp(ctx context.Context, tx *sql.Tx, d Dialect, email string) error {
+	var id int64
+	var name, storedEmail string
+	var createdAt interface{}
+	if d.Returning {
+		err := tx.QueryRowContext(ctx, d.InsertUser, "Stress Test", email).Scan(&id, &name, &storedEmail, &createdAt)
+		if err != nil {
+			return err
+		}
+	} else {
+		result, err := tx.ExecContext(ctx, d.InsertUser, "Stress Test", email)
+		if err != nil {
+			return err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return err
+		}
+	}
+	return tx.QueryRowContext(ctx, d.SelectUser, id).Scan(&id, &name, &storedEmail, &createdAt)
+}
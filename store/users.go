@@ -0,0 +1,148 @@
+// Package store holds the SQL helpers for the users table shared by the
+// framework apps, so every implementation issues identical statements.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// Statements for the single-row user endpoints in PostgreSQL syntax; see
+// Dialect for other backends. They are used both directly and as prepared
+// statements so the two paths can be compared.
+const (
+	SelectUsersQuery = "SELECT id, name, email, created_at FROM users ORDER BY id LIMIT $1 OFFSET $2"
+	InsertUserQuery  = "INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id, name, email, created_at"
+	SelectUserQuery  = "SELECT id, name, email, created_at FROM users WHERE id = $1"
+	UpdateUserQuery  = "UPDATE users SET name = $1, email = $2 WHERE id = $3"
+	DeleteUserQuery  = "DELETE FROM users WHERE id = $1"
+)
+
+// createUsersTableQuery matches the users table in init.sql.
+const createUsersTableQuery = `CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`
+
+// MaxBulkUsers caps the rows accepted by a single bulk insert.
+const MaxBulkUsers = 1000
+
+// MaxQueryRepeat caps how many times one users list request may run its
+// SELECT with ?repeat=, at most 100 pages of 1000 rows.
+const MaxQueryRepeat = 100
+
+// NewUser is the request shape for creating a user.
+type NewUser struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// maxFieldLength matches the VARCHAR(255) columns of the users table.
+const maxFieldLength = 255
+
+// emailPattern is deliberately loose: something@something.tld with no
+// whitespace. The UNIQUE constraint still guards against duplicates.
+var emailPattern = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+
+// FieldError reports which field of a request failed validation.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// Validate checks u before it reaches the database: name must be non-blank
+// and email must look like an address, both at most 255 characters.
+func (u NewUser) Validate() *FieldError {
+	switch {
+	case strings.TrimSpace(u.Name) == "":
+		return &FieldError{Field: "name", Message: "name is required"}
+	case utf8.RuneCountInString(u.Name) > maxFieldLength:
+		return &FieldError{Field: "name", Message: fmt.Sprintf("name must be at most %d characters", maxFieldLength)}
+	case utf8.RuneCountInString(u.Email) > maxFieldLength:
+		return &FieldError{Field: "email", Message: fmt.Sprintf("email must be at most %d characters", maxFieldLength)}
+	case !emailPattern.MatchString(u.Email):
+		return &FieldError{Field: "email", Message: "email is not a valid address"}
+	}
+	return nil
+}
+
+// BulkInsertUsersQuery builds the multi-row INSERT for users in the
+// PostgreSQL dialect; see Dialect.BulkInsertUsers.
+func BulkInsertUsersQuery(users []NewUser) (string, []interface{}) {
+	return Postgres.BulkInsertUsers(users)
+}
+
+// DuplicateEmailIndex returns the index of the first user whose email already
+// appeared earlier in users, or -1 when all emails are distinct.
+func DuplicateEmailIndex(users []NewUser) int {
+	seen := make(map[string]struct{}, len(users))
+	for i, u := range users {
+		if _, ok := seen[u.Email]; ok {
+			return i
+		}
+		seen[u.Email] = struct{}{}
+	}
+	return -1
+}
+
+var (
+	uniqueKeyDetail      = regexp.MustCompile(`^Key \(email\)=\((.*)\) already exists`)
+	mysqlDuplicateDetail = regexp.MustCompile(`^Duplicate entry '(.*)' for key '(?:users\.)?email'`)
+)
+
+// mysqlDuplicateEntry is ER_DUP_ENTRY, a unique key violation.
+const mysqlDuplicateEntry = 1062
+
+// sqliteConstraint is SQLite's primary result code for constraint failures.
+const sqliteConstraint = 19
+
+// ConstraintViolation reports whether err is an integrity constraint
+// violation (SQLSTATE class 23, or SQLITE_CONSTRAINT on the in-memory
+// fallback). When the offending row can be identified from the PostgreSQL
+// or MySQL error detail, index is its position in users; otherwise -1.
+func ConstraintViolation(err error, users []NewUser) (index int, ok bool) {
+	// Matched by method so this package doesn't link the SQLite driver
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		return -1, sqliteErr.Code()&0xff == sqliteConstraint
+	}
+
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		if myErr.Number != mysqlDuplicateEntry && string(myErr.SQLState[:2]) != "23" {
+			return -1, false
+		}
+		return duplicateIndex(mysqlDuplicateDetail, myErr.Message, users), true
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code.Class() != "23" {
+		return -1, false
+	}
+	return duplicateIndex(uniqueKeyDetail, pqErr.Detail, users), true
+}
+
+// duplicateIndex returns the position in users of the email detail names,
+// or -1.
+func duplicateIndex(pattern *regexp.Regexp, detail string, users []NewUser) int {
+	if m := pattern.FindStringSubmatch(detail); m != nil {
+		for i, u := range users {
+			if u.Email == m[1] {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,139 @@
+// Package tracing sets up OpenTelemetry for the framework apps. Tracing is
+// opt-in: unless OTEL_EXPORTER_OTLP_ENDPOINT is set nothing is installed, so
+// default benchmark runs carry no instrumentation overhead.
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"carbon-bench/config"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderTraceID carries the request's trace ID back to the client so a
+// response can be matched to its trace.
+const HeaderTraceID = "X-Trace-Id"
+
+var (
+	enabled bool
+	tracer  = otel.Tracer("carbon-bench")
+)
+
+// Setup installs an OTLP/HTTP exporter when endpoint is non-empty. The
+// exporter itself reads OTEL_EXPORTER_OTLP_ENDPOINT and the other standard
+// OTEL_* variables. The returned function flushes pending spans and must be
+// called on shutdown; it is a no-op when tracing is disabled.
+func Setup(ctx context.Context, endpoint, serviceName string) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	tracer = provider.Tracer("carbon-bench")
+	enabled = true
+	return provider.Shutdown, nil
+}
+
+// Enabled reports whether Setup installed an exporter. Apps only add their
+// router middleware and client transports when it's true.
+func Enabled() bool {
+	return enabled
+}
+
+// Middleware wraps next in a server span for every request, continuing any
+// trace propagated by the client.
+func Middleware(next http.Handler, operation string) http.Handler {
+	return otelhttp.NewHandler(next, operation)
+}
+
+// SetSpanName renames the span in ctx, for routers that only know the
+// matched route after the span has started.
+func SetSpanName(ctx context.Context, name string) {
+	trace.SpanFromContext(ctx).SetName(name)
+}
+
+// Transport wraps base so outgoing requests get client spans and propagate
+// the trace context upstream.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
+
+// SetTraceIDHeader copies the trace ID of the span in ctx into the response
+// headers. It does nothing when there is no sampled span.
+func SetTraceIDHeader(w http.ResponseWriter, ctx context.Context) {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		w.Header().Set(HeaderTraceID, sc.TraceID().String())
+	}
+}
+
+// StartDB starts a child span for one database operation against driver,
+// one of the config.Driver* names. When tracing is disabled it returns ctx
+// unchanged and a non-recording span.
+func StartDB(ctx context.Context, driver, operation, statement string) (context.Context, trace.Span) {
+	if !enabled {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return tracer.Start(ctx, "db."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			dbSystem(driver),
+			semconv.DBOperation(operation),
+			attribute.String("db.statement", statement),
+		),
+	)
+}
+
+// dbSystem returns the db.system attribute for a config.Driver* name.
+func dbSystem(driver string) attribute.KeyValue {
+	switch driver {
+	case config.DriverPostgres:
+		return semconv.DBSystemPostgreSQL
+	case config.DriverMySQL:
+		return semconv.DBSystemMySQL
+	case config.DriverSQLite:
+		return semconv.DBSystemSqlite
+	}
+	return semconv.DBSystemOtherSQL
+}
+
+// End records err on span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,20 @@
+package tracing
+
+import (
+	"testing"
+
+	"carbon-bench/config"
+)
+
+func TestDBSystem(t *testing.T) {
+	for driver, want := range map[string]string{
+		config.DriverPostgres: "postgresql",
+		config.DriverMySQL:    "mysql",
+		config.DriverSQLite:   "sqlite",
+		"oracle":              "other_sql",
+	} {
+		if got := dbSystem(driver).Value.AsString(); got != want {
+			t.Errorf("dbSystem(%q) = %q, want %q", driver, got, want)
+		}
+	}
+}
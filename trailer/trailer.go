@@ -0,0 +1,63 @@
+// Package trailer reports the timing of a streamed response in HTTP
+// trailers, sent after the last chunk of the body, since an SSE or NDJSON
+// stream has no body field left to carry it once it is complete.
+package trailer
+
+import (
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"carbon-bench/carbon"
+)
+
+// Trailers carrying the wall and thread CPU milliseconds from the start of
+// the stream to its end. The CPU trailer is distinct from the X-CPU-Ms
+// header, which is fixed before the first byte of the body.
+const (
+	ElapsedMs = "X-Elapsed-Ms"
+	CPUMs     = "X-Stream-CPU-Ms"
+)
+
+// Declaration is the Trailer header value announcing both trailers. It
+// must be set before the status line is written.
+const Declaration = ElapsedMs + ", " + CPUMs
+
+// Timer measures a stream from Start until Finish.
+type Timer struct {
+	set      func(key, value string)
+	start    time.Time
+	cpuStart float64
+}
+
+// Start begins timing on the calling goroutine, which stays locked to its
+// OS thread until Finish so the CPU reading covers only this stream. Work
+// handed off to other goroutines isn't included. Finish hands the values to
+// set.
+func Start(set func(key, value string)) *Timer {
+	runtime.LockOSThread()
+	return &Timer{set: set, start: time.Now(), cpuStart: carbon.ThreadCPUSeconds()}
+}
+
+// Declare announces the trailers on w and starts a Timer that sets them in
+// w's header map, from which net/http sends declared trailers once the
+// handler returns. Call it before the status line is written.
+func Declare(w http.ResponseWriter) *Timer {
+	w.Header().Set("Trailer", Declaration)
+	return Start(w.Header().Set)
+}
+
+// Finish records the trailer values and unlocks the thread. Call it on the
+// goroutine that called Start, after the last write to the body.
+func (t *Timer) Finish() {
+	cpu := carbon.ThreadCPUSeconds() - t.cpuStart
+	runtime.UnlockOSThread()
+
+	t.set(ElapsedMs, formatMs(float64(time.Since(t.start).Nanoseconds())/1e6))
+	t.set(CPUMs, formatMs(cpu*1000))
+}
+
+func formatMs(ms float64) string {
+	return strconv.FormatFloat(ms, 'f', 3, 64)
+}
@@ -0,0 +1,32 @@
+// Package unixsock opens the Unix domain socket the net/http-based framework
+// apps listen on when LISTEN_UNIX_SOCKET is set, so a local reverse proxy can
+// reach them without TCP loopback overhead.
+package unixsock
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+)
+
+// Listen listens on a Unix domain socket at path, first removing a stale
+// socket left there by an unclean exit. Anything other than a socket at path
+// is an error rather than being deleted. Closing the listener, which
+// http.Server.Shutdown does, removes the socket file again.
+func Listen(path string) (net.Listener, error) {
+	info, err := os.Lstat(path)
+	switch {
+	case err == nil:
+		if info.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
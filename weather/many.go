@@ -0,0 +1,85 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxCities caps the cities one FetchMany call looks up.
+const MaxCities = 20
+
+// DefaultParallelism is how many lookups FetchMany runs at once unless the
+// caller asks for another bound.
+const DefaultParallelism = 4
+
+// CityReport is one city's outcome within FetchMany, with the time that
+// city's lookup took.
+type CityReport struct {
+	Data      Report `json:"data"`
+	Source    Source `json:"source"`
+	Coalesced bool   `json:"coalesced"`
+	ElapsedMs int64  `json:"elapsed_ms"`
+}
+
+// ParseCities splits a comma-separated list of cities, trimming each and
+// dropping blanks and repeats, which share a cache entry whatever their
+// case. It fails when no city is left or more than MaxCities are.
+func ParseCities(list string) ([]string, error) {
+	var cities []string
+	seen := make(map[string]bool)
+	for _, city := range strings.Split(list, ",") {
+		city = strings.TrimSpace(city)
+		key := strings.ToLower(city)
+		if city == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		cities = append(cities, city)
+	}
+	if len(cities) == 0 {
+		return nil, fmt.Errorf("cities must name at least one city")
+	}
+	if len(cities) > MaxCities {
+		return nil, fmt.Errorf("cities names %d cities, more than %d", len(cities), MaxCities)
+	}
+	return cities, nil
+}
+
+// FetchMany looks up every city through Fetch, so caching, coalescing and
+// the mock fallback apply to each, with at most parallelism lookups in
+// flight. The result is keyed by city as given. Like Fetch it never fails.
+func (o *OpenMeteo) FetchMany(ctx context.Context, cities []string, parallelism int) map[string]CityReport {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	reports := make([]CityReport, len(cities))
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, city := range cities {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, city string) {
+			defer func() { <-slots; wg.Done() }()
+
+			start := time.Now()
+			report, source, coalesced := o.Fetch(ctx, city)
+			reports[i] = CityReport{
+				Data:      report,
+				Source:    source,
+				Coalesced: coalesced,
+				ElapsedMs: time.Since(start).Milliseconds(),
+			}
+		}(i, city)
+	}
+	wg.Wait()
+
+	byCity := make(map[string]CityReport, len(cities))
+	for i, city := range cities {
+		byCity[city] = reports[i]
+	}
+	return byCity
+}
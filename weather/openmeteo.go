@@ -0,0 +1,247 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	DefaultGeocodeURL  = "https://geocoding-api.open-meteo.com/v1/search"
+	DefaultForecastURL = "https://api.open-meteo.com/v1/forecast"
+
+	// fallbackTTL bounds how long a failed lookup is cached, so an
+	// unreachable upstream doesn't stall every request on the client timeout
+	// but recovers soon after the upstream does.
+	fallbackTTL = 30 * time.Second
+
+	// maxCacheEntries bounds the cache. Inserting at the limit sweeps out
+	// expired entries, then evicts the one nearest expiry if none had.
+	maxCacheEntries = 1024
+
+	// ttlJitter is the largest fraction added to a TTL, so entries stored
+	// together don't all expire and miss together.
+	ttlJitter = 0.1
+)
+
+// Source says where a Report came from.
+type Source string
+
+const (
+	SourceLive     Source = "live"
+	SourceCache    Source = "cache"
+	SourceFallback Source = "fallback"
+)
+
+// Report is the current-weather payload returned by the fetch endpoint.
+type Report struct {
+	Temperature float64 `json:"temperature"`
+	Windspeed   float64 `json:"windspeed"`
+	Weathercode int     `json:"weathercode"`
+	Note        string  `json:"note,omitempty"`
+}
+
+// MockReport is served when the upstream can't be reached.
+var MockReport = Report{
+	Temperature: 28.0,
+	Windspeed:   10.5,
+	Weathercode: 1,
+	Note:        "Mock data",
+}
+
+type cacheEntry struct {
+	report   Report
+	fallback bool
+	expires  time.Time
+}
+
+// OpenMeteo looks up current weather for a city via the Open-Meteo geocoding
+// and forecast APIs, caching results per city for TTL plus a little jitter.
+// Concurrent cache misses for the same city share one upstream lookup.
+type OpenMeteo struct {
+	GeocodeURL  string
+	ForecastURL string
+	Client      *http.Client
+	TTL         time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+	group singleflight.Group
+}
+
+// fetched is what a coalesced lookup hands to every waiting caller.
+type fetched struct {
+	report Report
+	source Source
+}
+
+// NewOpenMeteo returns a client for the public Open-Meteo endpoints.
+func NewOpenMeteo(ttl, timeout time.Duration) *OpenMeteo {
+	return &OpenMeteo{
+		GeocodeURL:  DefaultGeocodeURL,
+		ForecastURL: DefaultForecastURL,
+		Client:      &http.Client{Timeout: timeout},
+		TTL:         ttl,
+		cache:       make(map[string]cacheEntry),
+	}
+}
+
+// Fetch returns the current weather for city. It never fails: when the
+// upstream is unavailable it returns MockReport with SourceFallback so load
+// tests don't error out on a third-party outage. coalesced reports that the
+// call waited on another request's in-flight lookup instead of making its
+// own.
+func (o *OpenMeteo) Fetch(ctx context.Context, city string) (report Report, source Source, coalesced bool) {
+	key := strings.ToLower(strings.TrimSpace(city))
+
+	if entry, ok := o.lookup(key); ok {
+		if entry.fallback {
+			return entry.report, SourceFallback, false
+		}
+		return entry.report, SourceCache, false
+	}
+
+	// The lookup outlives any one caller's cancellation so the others
+	// waiting on it still get a result; the client timeout bounds it.
+	leader := false
+	ch := o.group.DoChan(key, func() (interface{}, error) {
+		leader = true
+		report, source := o.refresh(context.WithoutCancel(ctx), key, city)
+		return fetched{report: report, source: source}, nil
+	})
+
+	select {
+	case res := <-ch:
+		f := res.Val.(fetched)
+		return f.report, f.source, !leader
+	case <-ctx.Done():
+		return MockReport, SourceFallback, false
+	}
+}
+
+// refresh performs the upstream lookup for city and caches the outcome.
+func (o *OpenMeteo) refresh(ctx context.Context, key, city string) (Report, Source) {
+	report, err := o.fetchLive(ctx, city)
+	if err != nil {
+		o.store(key, cacheEntry{report: MockReport, fallback: true, expires: expiry(minDuration(o.TTL, fallbackTTL))})
+		return MockReport, SourceFallback
+	}
+
+	o.store(key, cacheEntry{report: report, expires: expiry(o.TTL)})
+	return report, SourceLive
+}
+
+// expiry returns when an entry stored now for ttl expires, with up to
+// ttlJitter of ttl added at random.
+func expiry(ttl time.Duration) time.Time {
+	jitter := time.Duration(rand.Int63n(int64(float64(ttl)*ttlJitter) + 1))
+	return time.Now().Add(ttl + jitter)
+}
+
+func (o *OpenMeteo) lookup(key string) (cacheEntry, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry, ok := o.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (o *OpenMeteo) store(key string, entry cacheEntry) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.cache) >= maxCacheEntries {
+		now := time.Now()
+		var oldest string
+		found := false
+		for k, e := range o.cache {
+			if now.After(e.expires) {
+				delete(o.cache, k)
+				continue
+			}
+			if !found || e.expires.Before(o.cache[oldest].expires) {
+				oldest, found = k, true
+			}
+		}
+		// More distinct cities than fit within one TTL: drop the entry
+		// nearest expiry rather than grow without bound
+		if _, ok := o.cache[key]; !ok && len(o.cache) >= maxCacheEntries {
+			delete(o.cache, oldest)
+		}
+	}
+	o.cache[key] = entry
+}
+
+func (o *OpenMeteo) fetchLive(ctx context.Context, city string) (Report, error) {
+	var geo struct {
+		Results []struct {
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+		} `json:"results"`
+	}
+	geoURL := o.GeocodeURL + "?" + url.Values{"name": {city}, "count": {"1"}}.Encode()
+	if err := o.getJSON(ctx, geoURL, &geo); err != nil {
+		return Report{}, fmt.Errorf("geocode %q: %w", city, err)
+	}
+	if len(geo.Results) == 0 {
+		return Report{}, fmt.Errorf("geocode %q: no results", city)
+	}
+
+	var forecast struct {
+		CurrentWeather struct {
+			Temperature float64 `json:"temperature"`
+			Windspeed   float64 `json:"windspeed"`
+			Weathercode int     `json:"weathercode"`
+		} `json:"current_weather"`
+	}
+	forecastURL := o.ForecastURL + "?" + url.Values{
+		"latitude":        {fmt.Sprintf("%f", geo.Results[0].Latitude)},
+		"longitude":       {fmt.Sprintf("%f", geo.Results[0].Longitude)},
+		"current_weather": {"true"},
+	}.Encode()
+	if err := o.getJSON(ctx, forecastURL, &forecast); err != nil {
+		return Report{}, fmt.Errorf("forecast %q: %w", city, err)
+	}
+
+	return Report{
+		Temperature: forecast.CurrentWeather.Temperature,
+		Windspeed:   forecast.CurrentWeather.Windspeed,
+		Weathercode: forecast.CurrentWeather.Weathercode,
+	}, nil
+}
+
+func (o *OpenMeteo) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("upstream returned %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
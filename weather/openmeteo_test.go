@@ -0,0 +1,58 @@
+package weather
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fill stores maxCacheEntries live entries, the first expiring soonest.
+func fill(o *OpenMeteo) {
+	now := time.Now()
+	for i := 0; i < maxCacheEntries; i++ {
+		o.store(fmt.Sprintf("city-%d", i), cacheEntry{expires: now.Add(time.Hour + time.Duration(i)*time.Second)})
+	}
+}
+
+func TestStoreEvictsNearestExpiryWhenFull(t *testing.T) {
+	o := NewOpenMeteo(time.Hour, time.Second)
+	fill(o)
+
+	o.store("new", cacheEntry{expires: time.Now().Add(time.Hour)})
+	if len(o.cache) != maxCacheEntries {
+		t.Errorf("%d entries, want %d", len(o.cache), maxCacheEntries)
+	}
+	if _, ok := o.cache["city-0"]; ok {
+		t.Error("entry nearest expiry survived")
+	}
+	if _, ok := o.cache["new"]; !ok {
+		t.Error("new entry not stored")
+	}
+}
+
+func TestStoreSweepsExpiredBeforeEvicting(t *testing.T) {
+	o := NewOpenMeteo(time.Hour, time.Second)
+	fill(o)
+	o.cache["city-5"] = cacheEntry{expires: time.Now().Add(-time.Second)}
+
+	o.store("new", cacheEntry{expires: time.Now().Add(time.Hour)})
+	if _, ok := o.cache["city-5"]; ok {
+		t.Error("expired entry survived the sweep")
+	}
+	if _, ok := o.cache["city-0"]; !ok {
+		t.Error("live entry evicted although the sweep made room")
+	}
+}
+
+func TestStoreReplacesExistingKeyWhenFull(t *testing.T) {
+	o := NewOpenMeteo(time.Hour, time.Second)
+	fill(o)
+
+	o.store("city-7", cacheEntry{expires: time.Now().Add(2 * time.Hour)})
+	if len(o.cache) != maxCacheEntries {
+		t.Errorf("%d entries, want %d", len(o.cache), maxCacheEntries)
+	}
+	if _, ok := o.cache["city-0"]; !ok {
+		t.Error("entry evicted to make room for a key already present")
+	}
+}
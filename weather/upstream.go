@@ -0,0 +1,61 @@
+// Package weather contains the upstream weather clients shared by the
+// framework apps' I/O-bound endpoints.
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxUpstreamBody bounds how much of an upstream response is read.
+const maxUpstreamBody = 10 << 20
+
+// Upstream proxies JSON from a fixed upstream URL. It exists so the external
+// endpoint can exercise a real HTTP client (DNS, connection pooling, TLS)
+// instead of a simulated sleep.
+type Upstream struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewUpstream returns an Upstream with its own pooled client and the given
+// per-request timeout.
+func NewUpstream(url string, timeout time.Duration) *Upstream {
+	return &Upstream{
+		URL:    url,
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Fetch performs a GET against the upstream and returns the JSON body
+// unchanged. The request is bound to ctx so a cancelled client request
+// aborts the upstream call.
+func (u *Upstream) Fetch(ctx context.Context) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamBody))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("upstream returned %s", resp.Status)
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("upstream returned invalid JSON")
+	}
+	return json.RawMessage(body), nil
+}
@@ -0,0 +1,83 @@
+// Package wsecho implements the WebSocket echo workload shared by the
+// framework apps. It is a plain http.Handler so net/http routers mount it
+// directly and gin wraps it with gin.WrapH.
+package wsecho
+
+import (
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Echo is the reply sent for every text message received.
+type Echo struct {
+	Message         string `json:"message"`
+	ServerTimestamp int64  `json:"server_timestamp"`
+}
+
+// Server upgrades requests to WebSocket connections and echoes each message
+// back, refusing new connections once maxConns are open.
+type Server struct {
+	upgrader websocket.Upgrader
+	maxConns int64
+	active   atomic.Int64
+}
+
+// New returns a Server that allows at most maxConns concurrent connections.
+// A non-positive maxConns means no limit.
+func New(maxConns int) *Server {
+	return &Server{
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			// Benchmark clients connect from arbitrary origins
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		maxConns: int64(maxConns),
+	}
+}
+
+// Active returns the number of open connections.
+func (s *Server) Active() int64 {
+	return s.active.Load()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if n := s.active.Add(1); s.maxConns > 0 && n > s.maxConns {
+		s.active.Add(-1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"code":"service_unavailable","message":"too many websocket connections"}` + "\n"))
+		return
+	}
+	defer s.active.Add(-1)
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response
+		return
+	}
+	defer conn.Close()
+
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("⚠️  WebSocket read error: %v", err)
+			}
+			return
+		}
+
+		if msgType == websocket.TextMessage {
+			err = conn.WriteJSON(Echo{Message: string(data), ServerTimestamp: time.Now().UnixMilli()})
+		} else {
+			err = conn.WriteMessage(msgType, data)
+		}
+		if err != nil {
+			return
+		}
+	}
+}